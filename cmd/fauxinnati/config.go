@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// applyConfigFile sets flags of cmd from a YAML file mapping flag names to values. Lists set repeatable flags
+// once per item. Flags given on the command line are left as they are.
+func applyConfigFile(cmd *cobra.Command, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	flags := cmd.Flags()
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown flag %q", path, name)
+		}
+		if flags.Changed(name) {
+			continue
+		}
+		values, ok := config[name].([]any)
+		if !ok {
+			values = []any{config[name]}
+		}
+		for _, value := range values {
+			if err := flags.Set(name, fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("config file %s: invalid value %v for %q: %w", path, value, name, err)
+			}
+		}
+	}
+	return nil
+}
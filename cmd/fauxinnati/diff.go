@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+var diffFormat string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff OLD NEW",
+	Short: "Show what changed between two Cincinnati graphs",
+	Long: "diff compares two graphs in JSON or YAML, each read from a file (- for stdin) or fetched from a graph API URL, " +
+		"by version rather than by node index: added and removed nodes, changed payloads and metadata, added and removed " +
+		"edges, edges that became conditional or unconditional and changed risks of conditional edges.",
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffFormat != "text" && diffFormat != "json" {
+			return fmt.Errorf("unknown --format %q (one of text, json)", diffFormat)
+		}
+		if args[0] == "-" && args[1] == "-" {
+			return fmt.Errorf("only one graph can be read from stdin")
+		}
+		var graphs [2]fauxinnati.Graph
+		for i, source := range args {
+			data, err := readGraph(source)
+			if err != nil {
+				return err
+			}
+			if graphs[i], err = fauxinnati.DecodeGraph(data); err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+		}
+
+		diff := fauxinnati.DiffGraphs(graphs[0], graphs[1])
+		if diffFormat == "text" {
+			return diff.WriteText(cmd.OutOrStdout())
+		}
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		return err
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "Output format: text (one change per line) or json")
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -11,29 +16,280 @@ import (
 )
 
 var (
-	port int
+	port                    int
+	scenariosDir            string
+	scenariosReloadInterval time.Duration
+	prometheus              bool
+	prometheusSeriesFile    string
+	graphDataDir            string
+	graphDataTarball        string
+	payloadRepository       string
+	digestsFile             string
+	ociLayoutDir            string
+	registryTokenFile       string
+	registryPlainHTTP       bool
+	snapshots               []string
+	snapshotRewriteRepo     bool
+	proxyChannels           []string
+	requestHistory          int
+	tlsCert                 string
+	tlsKey                  string
+	tlsSelfSigned           bool
+	tlsHosts                []string
+	tlsCABundleFile         string
+	listenAddress           string
+	shutdownTimeout         time.Duration
+	configFile              string
+	httpTimeout             time.Duration
+	httpRetries             int
+	candidatesTTL           time.Duration
+	offline                 bool
+	logLevel                string
+	logFormat               string
+	strictChannels          bool
+	sessionIdleTimeout      time.Duration
+	adminTokenFile          string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "fauxinnati",
 	Short: "A mock Cincinnati update graph server",
 	Long:  "fauxinnati is a mock implementation of the Red Hat OpenShift Cincinnati update graph protocol",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if configFile != "" {
+			if err := applyConfigFile(cmd, configFile); err != nil {
+				return err
+			}
+		}
+		return configureLogging()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		server := fauxinnati.NewServer()
-		if err := server.Start(port); err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		opts, err := serverOptions()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error configuring the server: %v\n", err)
+			os.Exit(1)
+		}
+		server := fauxinnati.NewServer(opts...)
+		if scenariosDir != "" {
+			if err := server.LoadScenariosDir(scenariosDir); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error loading scenarios: %v\n", err)
+				os.Exit(1)
+			}
+			if scenariosReloadInterval > 0 {
+				go server.WatchScenariosDir(ctx, scenariosDir, scenariosReloadInterval)
+			}
+		}
+		for _, path := range snapshots {
+			snapshot, err := fauxinnati.LoadSnapshot(path)
+			if err == nil {
+				snapshot.RewriteRepository = snapshot.RewriteRepository || snapshotRewriteRepo
+				err = server.RegisterSnapshot(snapshot)
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error loading snapshot: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		for _, path := range proxyChannels {
+			proxy, err := fauxinnati.LoadProxyChannel(path)
+			if err == nil {
+				err = server.RegisterProxyChannel(proxy)
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error loading proxy channel: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if prometheus || prometheusSeriesFile != "" {
+			var series []fauxinnati.Sample
+			if prometheusSeriesFile != "" {
+				data, err := os.ReadFile(prometheusSeriesFile)
+				if err == nil {
+					series, err = fauxinnati.ParseSeries(string(data))
+				}
+				if err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "Error loading Prometheus series: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			server.EnablePrometheus(series)
+		}
+		if err := configureTLS(server); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error configuring TLS: %v\n", err)
+			os.Exit(1)
+		}
+		addr := listenAddress
+		if addr == "" {
+			addr = fmt.Sprintf(":%d", port)
+		}
+		if err := server.Run(ctx, addr); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error running server: %v\n", err)
 			os.Exit(1)
 		}
 	},
 }
 
 func init() {
+	rootCmd.Flags().StringVar(&configFile, "config", "", "YAML file with flag values keyed by flag name (lists for repeated flags); flags given on the command line take precedence")
 	rootCmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to run the server on")
+	rootCmd.Flags().StringVar(&listenAddress, "listen", "", "Address to listen on (e.g. 127.0.0.1:8080); overrides --port")
+	rootCmd.MarkFlagsMutuallyExclusive("port", "listen")
+	rootCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", fauxinnati.DefaultShutdownTimeout, "How long to wait for in-flight requests to finish on SIGTERM or SIGINT before closing their connections")
+	rootCmd.Flags().StringVar(&scenariosDir, "scenarios-dir", "", "Directory with additional scenario files (*.yaml, *.yml, *.json) served as channels")
+	rootCmd.Flags().DurationVar(&scenariosReloadInterval, "scenarios-reload-interval", 10*time.Second, "How often to check --scenarios-dir for changes and reload the scenarios (0 disables reloading)")
+	rootCmd.Flags().BoolVar(&prometheus, "prometheus", false, "Serve a Prometheus-compatible /api/v1/query endpoint for evaluating PromQL matching rules")
+	rootCmd.Flags().StringVar(&prometheusSeriesFile, "prometheus-series", "", "File with static series (one 'metric{label=\"value\"} value' per line) queried by /api/v1/query; implies --prometheus")
+	rootCmd.Flags().StringVar(&graphDataDir, "graph-data-dir", "", "Read candidate channels from a local checkout of the cincinnati-graph-data repository instead of GitHub")
+	rootCmd.Flags().StringVar(&graphDataTarball, "graph-data-tarball", "", "Read candidate channels from a (gzipped) tarball of the cincinnati-graph-data repository instead of GitHub")
+	rootCmd.MarkFlagsMutuallyExclusive("graph-data-dir", "graph-data-tarball")
+	rootCmd.Flags().StringVar(&payloadRepository, "payload-repository", fauxinnati.DefaultPayloadRepository, "Repository of release payloads; payload pullspecs point to it and digests are resolved from it")
+	rootCmd.Flags().StringVar(&digestsFile, "digests-file", "", "Resolve payload digests from a YAML file mapping tags (e.g. 4.18.5-x86_64) to digests instead of the registry")
+	rootCmd.Flags().StringVar(&ociLayoutDir, "oci-layout-dir", "", "Resolve payload digests from the index of an OCI image-layout directory instead of the registry")
+	rootCmd.Flags().StringVar(&registryTokenFile, "registry-token-file", "", "File with a bearer token used to authenticate to the payload registry")
+	rootCmd.Flags().BoolVar(&registryPlainHTTP, "registry-plain-http", false, "Access the payload registry over plain HTTP")
+	rootCmd.MarkFlagsMutuallyExclusive("digests-file", "oci-layout-dir")
+	rootCmd.Flags().StringArrayVar(&snapshots, "snapshot", nil, "Serve a graph snapshot (written by capture-snapshot, or a plain graph JSON file) as a channel; can be repeated")
+	rootCmd.Flags().BoolVar(&snapshotRewriteRepo, "snapshot-rewrite-repository", false, "Rewrite payload pullspecs of snapshots to --payload-repository instead of serving the recorded ones")
+	rootCmd.Flags().StringArrayVar(&proxyChannels, "proxy-channel", nil, "Serve an upstream Cincinnati channel with a graph overlay declared in a YAML file; can be repeated")
+	rootCmd.Flags().IntVar(&requestHistory, "request-history", fauxinnati.DefaultRequestHistory, "How many graph requests to keep for inspection at /api/debug/requests (0 disables recording)")
+	rootCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "Serve HTTPS with the PEM certificate (chain) from this file; requires --tls-key")
+	rootCmd.Flags().StringVar(&tlsKey, "tls-key", "", "PEM key of the --tls-cert certificate")
+	rootCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+	rootCmd.Flags().BoolVar(&tlsSelfSigned, "tls-self-signed", false, "Serve HTTPS with a certificate signed by an ephemeral CA generated at startup; the CA bundle is served at "+fauxinnati.CABundlePath)
+	rootCmd.MarkFlagsMutuallyExclusive("tls-cert", "tls-self-signed")
+	rootCmd.Flags().StringSliceVar(&tlsHosts, "tls-hosts", nil, "DNS names and IP addresses the --tls-self-signed certificate is valid for (default localhost, loopback addresses and the hostname)")
+	rootCmd.Flags().StringVar(&tlsCABundleFile, "tls-ca-bundle-file", "", "Write the CA bundle of the --tls-self-signed certificate to this file")
+	rootCmd.Flags().DurationVar(&httpTimeout, "http-timeout", fauxinnati.DefaultHTTPTimeout, "Timeout of a single attempt of an upstream request (GitHub, payload registry, upstream Cincinnati)")
+	rootCmd.Flags().IntVar(&httpRetries, "http-retries", fauxinnati.DefaultHTTPRetries, "How many times failed upstream requests are retried")
+	rootCmd.Flags().DurationVar(&candidatesTTL, "candidates-ttl", fauxinnati.DefaultCandidatesTTL, "How long candidate channels are cached")
+	rootCmd.Flags().BoolVar(&offline, "offline", false, "Never make upstream requests; use with --graph-data-dir or --graph-data-tarball and --digests-file or --oci-layout-dir")
+	rootCmd.Flags().BoolVar(&strictChannels, "strict-channels", false, "Reject graph requests for channels that are not served with a 400 invalid_params error instead of returning an empty graph")
+	rootCmd.Flags().DurationVar(&sessionIdleTimeout, "session-idle-timeout", fauxinnati.DefaultSessionIdleTimeout, "How long sessions of clusters that stopped polling are kept (see /api/debug/sessions)")
+	rootCmd.Flags().StringVar(&adminTokenFile, "admin-token-file", "", "Enable the admin API at /api/admin, authenticating requests with the bearer token from this file")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (trace, debug, info, warning, error, fatal or panic)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format (text or json)")
+	rootCmd.AddCommand(captureSnapshotCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(diffCmd)
+}
+
+func configureLogging() error {
+	level, err := logrus.ParseLevel(logLevel)
+	if err != nil {
+		return fmt.Errorf("invalid --log-level: %w", err)
+	}
+	logrus.SetLevel(level)
+	switch logFormat {
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid --log-format %q (text or json)", logFormat)
+	}
+	return nil
+}
+
+func serverOptions() ([]fauxinnati.Option, error) {
+	if sessionIdleTimeout <= 0 {
+		return nil, fmt.Errorf("--session-idle-timeout must be positive")
+	}
+	if requestHistory < 0 {
+		return nil, fmt.Errorf("--request-history must not be negative")
+	}
+	client := fauxinnati.NewRetryingClient(httpTimeout, httpRetries)
+	if offline {
+		client = fauxinnati.NewOfflineClient()
+		if graphDataDir == "" && graphDataTarball == "" {
+			logrus.Warning("Running offline without --graph-data-dir or --graph-data-tarball, channels built from candidate releases will fail")
+		}
+		if digestsFile == "" && ociLayoutDir == "" {
+			logrus.Warning("Running offline without --digests-file or --oci-layout-dir, payload digests will not be resolved")
+		}
+	}
+	opts := []fauxinnati.Option{
+		fauxinnati.WithHTTPClient(client),
+		fauxinnati.WithCandidatesTTL(candidatesTTL),
+		fauxinnati.WithSessionIdleTimeout(sessionIdleTimeout),
+		fauxinnati.WithRequestHistory(requestHistory),
+		fauxinnati.WithShutdownTimeout(shutdownTimeout),
+	}
+	if strictChannels {
+		opts = append(opts, fauxinnati.WithStrictChannels())
+	}
+	if adminTokenFile != "" {
+		data, err := os.ReadFile(adminTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the admin token: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return nil, fmt.Errorf("admin token file %s is empty", adminTokenFile)
+		}
+		opts = append(opts, fauxinnati.WithAdminToken(token))
+	}
+
+	switch {
+	case graphDataDir != "":
+		opts = append(opts, fauxinnati.WithCandidatesSource(fauxinnati.NewGraphDataDirCandidatesSource(graphDataDir)))
+	case graphDataTarball != "":
+		source, err := fauxinnati.NewGraphDataTarballCandidatesSource(graphDataTarball)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load graph data: %w", err)
+		}
+		opts = append(opts, fauxinnati.WithCandidatesSource(source))
+	}
+
+	resolver, err := digestResolver()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure digest resolution: %w", err)
+	}
+	return append(opts, fauxinnati.WithDigestResolver(resolver)), nil
+}
+
+func configureTLS(server *fauxinnati.Server) error {
+	switch {
+	case tlsCert != "":
+		return server.EnableTLS(tlsCert, tlsKey)
+	case tlsSelfSigned:
+		hosts := tlsHosts
+		if len(hosts) == 0 {
+			hosts = fauxinnati.DefaultTLSHosts()
+		}
+		if err := server.EnableSelfSignedTLS(hosts); err != nil {
+			return err
+		}
+		if tlsCABundleFile != "" {
+			return os.WriteFile(tlsCABundleFile, server.CABundle(), 0644)
+		}
+	case len(tlsHosts) > 0 || tlsCABundleFile != "":
+		return fmt.Errorf("--tls-hosts and --tls-ca-bundle-file require --tls-self-signed")
+	}
+	return nil
+}
+
+func digestResolver() (fauxinnati.DigestResolver, error) {
+	switch {
+	case digestsFile != "":
+		return fauxinnati.LoadStaticDigestResolver(payloadRepository, digestsFile)
+	case ociLayoutDir != "":
+		return fauxinnati.NewOCILayoutDigestResolver(payloadRepository, ociLayoutDir)
+	}
+	var token string
+	if registryTokenFile != "" {
+		data, err := os.ReadFile(registryTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	return fauxinnati.NewRegistryDigestResolver(payloadRepository, token, registryPlainHTTP), nil
 }
 
 func main() {
-	// TODO: get the level from an arg
-	logrus.SetLevel(logrus.DebugLevel)
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
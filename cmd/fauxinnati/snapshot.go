@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+var (
+	captureUpstream string
+	captureChannel  string
+	captureArches   []string
+	captureOutput   string
+)
+
+var captureSnapshotCmd = &cobra.Command{
+	Use:   "capture-snapshot",
+	Short: "Record graphs from an upstream Cincinnati into a snapshot file",
+	Long:  "capture-snapshot records the graphs an upstream Cincinnati serves for a channel, so they can be served with --snapshot",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := fauxinnati.NewRetryingClient(fauxinnati.DefaultHTTPTimeout, fauxinnati.DefaultHTTPRetries)
+		snapshot, err := fauxinnati.CaptureSnapshot(client, captureUpstream, captureChannel, captureArches)
+		if err != nil {
+			return fmt.Errorf("failed to capture snapshot: %w", err)
+		}
+		data, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if captureOutput == "" || captureOutput == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		return os.WriteFile(captureOutput, data, 0644)
+	},
+}
+
+func init() {
+	captureSnapshotCmd.Flags().StringVar(&captureUpstream, "upstream", fauxinnati.DefaultUpstream, "Graph API URL of the upstream Cincinnati")
+	captureSnapshotCmd.Flags().StringVar(&captureChannel, "channel", "", "Channel to capture")
+	captureSnapshotCmd.Flags().StringSliceVar(&captureArches, "arch", []string{"amd64"}, "Architectures to capture graphs for; can be repeated")
+	captureSnapshotCmd.Flags().StringVarP(&captureOutput, "output", "o", "", "File to write the snapshot to (default stdout)")
+	_ = captureSnapshotCmd.MarkFlagRequired("channel")
+}
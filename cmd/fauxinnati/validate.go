@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+var validateStrict bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate FILE|URL",
+	Short: "Check that a Cincinnati graph is consistent",
+	Long: "validate checks a graph in JSON or YAML read from a file (- for stdin) or fetched from a graph API URL, " +
+		"e.g. https://api.openshift.com/api/upgrades_info/graph?channel=stable-4.17. Problems are printed one per " +
+		"line; the command fails when the graph has errors, or warnings with --strict.",
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := readGraph(args[0])
+		if err != nil {
+			return err
+		}
+		graph, err := fauxinnati.DecodeGraph(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+
+		problems := fauxinnati.Validate(graph)
+		errors, warnings := 0, 0
+		for _, problem := range problems {
+			if problem.Severity == fauxinnati.SeverityError {
+				errors++
+			} else {
+				warnings++
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), problem)
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: %d nodes, %d edges, %d conditional edges: %d errors, %d warnings\n",
+			args[0], len(graph.Nodes), len(graph.Edges), len(graph.ConditionalEdges), errors, warnings)
+		if errors > 0 || (validateStrict && warnings > 0) {
+			return fmt.Errorf("%s is not a valid graph", args[0])
+		}
+		return nil
+	},
+}
+
+// readGraph reads the graph from a file, stdin or a graph API URL
+func readGraph(source string) ([]byte, error) {
+	switch {
+	case source == "-":
+		return io.ReadAll(os.Stdin)
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		req, err := http.NewRequest(http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", fauxinnati.MediaTypeJSON)
+		resp, err := fauxinnati.NewRetryingClient(fauxinnati.DefaultHTTPTimeout, fauxinnati.DefaultHTTPRetries).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", source, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", source, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch %s: %s: %s", source, resp.Status, strings.TrimSpace(string(data)))
+		}
+		return data, nil
+	default:
+		return os.ReadFile(source)
+	}
+}
+
+func init() {
+	validateCmd.Flags().BoolVar(&validateStrict, "strict", false, "Fail on warnings too")
+}
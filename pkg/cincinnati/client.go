@@ -0,0 +1,464 @@
+// Package cincinnati is a client for the Cincinnati update graph API served by fauxinnati and the
+// OpenShift Update Service. GetUpdates mirrors how the cluster-version operator (CVO) interprets
+// a graph, so tests can assert what a cluster would see without running one.
+package cincinnati
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/sirupsen/logrus"
+
+	"github.com/petr-muller/vibes/pkg/clusterconditions"
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+const (
+	// GraphMediaType is the media-type specified in the HTTP Accept header
+	// of requests sent to the Cincinnati-v1 Graph API.
+	GraphMediaType = "application/json"
+
+	// ArchitectureMulti is the architecture of heterogeneous (multi-arch) releases
+	ArchitectureMulti = "Multi"
+)
+
+// Reasons of Error, matching the reasons the CVO sets on the RetrievedUpdates condition
+const (
+	ReasonInvalidRequest  = "InvalidRequest"
+	ReasonRemoteFailed    = "RemoteFailed"
+	ReasonResponseFailed  = "ResponseFailed"
+	ReasonResponseInvalid = "ResponseInvalid"
+	ReasonVersionNotFound = "VersionNotFound"
+)
+
+// Client is a Cincinnati client which can be used to fetch update graphs from
+// an upstream Cincinnati stack.
+type Client struct {
+	uri        *url.URL
+	id         string
+	userAgent  string
+	httpClient *http.Client
+	// conditionRegistry prunes matching rules the CVO would not accept, if set
+	conditionRegistry *clusterconditions.Registry
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithID sets the cluster ID sent in the id query parameter. A random UUID is used by default.
+func WithID(id string) Option {
+	return func(c *Client) {
+		c.id = id
+	}
+}
+
+// WithUserAgent configures the User-Agent header of requests. If empty, the header is not populated.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests. http.DefaultClient is used by default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithConditionRegistry makes GetUpdates prune matching rules of unrecognized types or invalid rules,
+// and drop conditional updates with a risk that is left without rules, like the CVO does
+func WithConditionRegistry(registry *clusterconditions.Registry) Option {
+	return func(c *Client) {
+		c.conditionRegistry = registry
+	}
+}
+
+// NewClient creates a client for the graph API at uri (e.g. https://api.openshift.com/api/upgrades_info/graph)
+func NewClient(uri *url.URL, opts ...Option) *Client {
+	c := &Client{
+		uri:        uri,
+		id:         newUUID(),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned when are unable to get updates.
+type Error struct {
+	// Reason is the reason suggested for the ClusterOperator status condition.
+	Reason string
+
+	// Message is the message suggested for the ClusterOperator status condition.
+	Message string
+
+	// cause is the upstream error, if any, being wrapped by this error.
+	cause error
+}
+
+// Error serializes the error as a string, to satisfy the error interface.
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Reason, err.Message)
+}
+
+// Unwrap returns the upstream error, if any
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// Release is a release payload offered as an update, mirroring the CVO's configv1.Release
+type Release struct {
+	Version      string
+	Image        string
+	URL          string
+	Architecture string
+	Channels     []string
+}
+
+// ConditionalUpdate is an update with risks, mirroring the CVO's configv1.ConditionalUpdate
+type ConditionalUpdate struct {
+	Release Release
+	Risks   []fauxinnati.ConditionalUpdateRisk
+	// Conditions are only populated by Evaluate
+	Conditions []Condition
+}
+
+// GetGraph downloads the update graph for the channel, version and arch
+func (c *Client) GetGraph(ctx context.Context, channel, version, arch string) (*fauxinnati.Graph, error) {
+	body, err := c.fetch(ctx, channel, version, arch)
+	if err != nil {
+		return nil, err
+	}
+	var graph fauxinnati.Graph
+	if err := json.Unmarshal(body, &graph); err != nil {
+		return nil, &Error{Reason: ReasonResponseInvalid, Message: err.Error(), cause: err}
+	}
+	return &graph, nil
+}
+
+func (c *Client) fetch(ctx context.Context, channel, version, arch string) ([]byte, error) {
+	uri := *c.uri
+	queryParams := uri.Query()
+	queryParams.Add("arch", arch)
+	queryParams.Add("channel", channel)
+	queryParams.Add("id", c.id)
+	queryParams.Add("version", version)
+	uri.RawQuery = queryParams.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri.String(), nil)
+	if err != nil {
+		return nil, &Error{Reason: ReasonInvalidRequest, Message: err.Error(), cause: err}
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	req.Header.Set("Accept", GraphMediaType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &Error{Reason: ReasonRemoteFailed, Message: err.Error(), cause: err}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Reason: ReasonResponseFailed, Message: fmt.Sprintf("unexpected HTTP status: %s", resp.Status)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &Error{Reason: ReasonResponseFailed, Message: err.Error(), cause: err}
+	}
+	return body, nil
+}
+
+// GetUpdates fetches the current and next-applicable update payloads given the current version, desired
+// architecture, and channel, the same way the CVO does:
+//
+//  1. Downloads the update graph for the requested desired arch and channel.
+//  2. Finds the current version entry under .nodes.
+//  3. If a transition from single to multi architecture has been requested, the only valid
+//     version is the current version so it's returned.
+//  4. Finds recommended next-hop updates by searching .edges for updates from the current
+//     version. Returns a slice of target Releases with these unconditional recommendations.
+//  5. Finds conditionally recommended next-hop updates by searching .conditionalEdges for
+//     updates from the current version.  Returns a slice of ConditionalUpdates with these
+//     conditional recommendations.
+func (c *Client) GetUpdates(ctx context.Context, desiredArch, currentArch, channel string,
+	version semver.Version) (Release, []Release, []ConditionalUpdate, error) {
+
+	releaseArch := desiredArch
+	if desiredArch == ArchitectureMulti {
+		releaseArch = "multi"
+	}
+
+	body, err := c.fetch(ctx, channel, version.String(), releaseArch)
+	if err != nil {
+		return Release{}, nil, nil, err
+	}
+
+	var graph graph
+	if err = json.Unmarshal(body, &graph); err != nil {
+		return Release{}, nil, nil, &Error{Reason: ReasonResponseInvalid, Message: err.Error(), cause: err}
+	}
+
+	current, updates, conditionalUpdates, err := computeUpdates(graph, desiredArch, currentArch, version,
+		fmt.Sprintf("the %q channel", channel))
+	if err != nil || c.conditionRegistry == nil {
+		return current, updates, conditionalUpdates, err
+	}
+	return current, updates, pruneConditionalUpdates(ctx, conditionalUpdates, c.conditionRegistry), nil
+}
+
+// computeUpdates finds the current release and the updates from it in the graph. where describes the
+// graph in VersionNotFound messages.
+func computeUpdates(graph graph, desiredArch, currentArch string, version semver.Version,
+	where string) (Release, []Release, []ConditionalUpdate, error) {
+
+	var current Release
+	var err error
+
+	// Find the current version within the graph.
+	var currentIdx int
+	found := false
+	for i, node := range graph.Nodes {
+		if version.EQ(node.Version) {
+			currentIdx = i
+			found = true
+			current, err = convertRetrievedUpdateToRelease(graph.Nodes[i])
+			if err != nil {
+				return current, nil, nil, &Error{
+					Reason:  ReasonResponseInvalid,
+					Message: fmt.Sprintf("invalid current node: %s", err),
+				}
+			}
+
+			// Migrating from single to multi architecture. Only valid update for required heterogeneous graph
+			// is heterogeneous version of current version.
+			if desiredArch == ArchitectureMulti && currentArch != desiredArch {
+				return current, []Release{current}, nil, nil
+			}
+			break
+		}
+	}
+	if !found {
+		return current, nil, nil, &Error{
+			Reason:  ReasonVersionNotFound,
+			Message: fmt.Sprintf("currently reconciling cluster version %s not found in %s", version, where),
+		}
+	}
+
+	// Find the children of the current version.
+	var nextIdxs []int
+	for _, edge := range graph.Edges {
+		if edge.Origin == currentIdx {
+			nextIdxs = append(nextIdxs, edge.Destination)
+		}
+	}
+
+	var updates []Release
+	for _, i := range nextIdxs {
+		if i < 0 || i >= len(graph.Nodes) {
+			return current, nil, nil, &Error{
+				Reason:  ReasonResponseInvalid,
+				Message: fmt.Sprintf("edge from %s points to a nonexistent node %d", version, i),
+			}
+		}
+		update, err := convertRetrievedUpdateToRelease(graph.Nodes[i])
+		if err != nil {
+			return current, nil, nil, &Error{
+				Reason:  ReasonResponseInvalid,
+				Message: fmt.Sprintf("invalid recommended update node: %s", err),
+			}
+		}
+		updates = append(updates, update)
+	}
+
+	var conditionalUpdates []ConditionalUpdate
+	for _, conditionalEdges := range graph.ConditionalEdges {
+		for _, edge := range conditionalEdges.Edges {
+			if version.String() == edge.From {
+				var target *node
+				for i, node := range graph.Nodes {
+					if node.Version.String() == edge.To {
+						target = &graph.Nodes[i]
+						break
+					}
+				}
+				if target == nil {
+					return current, updates, nil, &Error{
+						Reason:  ReasonResponseInvalid,
+						Message: fmt.Sprintf("no node for conditional update %s", edge.To),
+					}
+				}
+				update, err := convertRetrievedUpdateToRelease(*target)
+				if err != nil {
+					return current, updates, nil, &Error{
+						Reason:  ReasonResponseInvalid,
+						Message: fmt.Sprintf("invalid conditional update node: %s", err),
+					}
+				}
+				conditionalUpdates = append(conditionalUpdates, ConditionalUpdate{
+					Release: update,
+					Risks:   conditionalEdges.Risks,
+				})
+			}
+		}
+	}
+
+	for i := len(updates) - 1; i >= 0; i-- {
+		for _, conditionalUpdate := range conditionalUpdates {
+			if conditionalUpdate.Release.Image == updates[i].Image {
+				logrus.Warningf("Update to %s listed as both a conditional and unconditional update; preferring the conditional update.", conditionalUpdate.Release.Version)
+				updates = append(updates[:i], updates[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if len(updates) == 0 {
+		updates = nil
+	}
+
+	targets := make(map[string]int, len(conditionalUpdates))
+	for _, conditionalUpdate := range conditionalUpdates {
+		targets[conditionalUpdate.Release.Image]++
+	}
+
+	for i := len(conditionalUpdates) - 1; i >= 0; i-- {
+		if targets[conditionalUpdates[i].Release.Image] > 1 {
+			logrus.Warningf("Upstream declares %d conditional updates to %s; dropping them all.", targets[conditionalUpdates[i].Release.Image], conditionalUpdates[i].Release.Version)
+			conditionalUpdates = append(conditionalUpdates[:i], conditionalUpdates[i+1:]...)
+		}
+	}
+
+	if len(conditionalUpdates) == 0 {
+		conditionalUpdates = nil
+	}
+
+	return current, updates, conditionalUpdates, nil
+}
+
+// pruneConditionalUpdates prunes invalid matching rules and drops conditional updates with a risk
+// left without any rule
+func pruneConditionalUpdates(ctx context.Context, conditionalUpdates []ConditionalUpdate,
+	registry *clusterconditions.Registry) []ConditionalUpdate {
+
+	for i := len(conditionalUpdates) - 1; i >= 0; i-- {
+		risks := make([]fauxinnati.ConditionalUpdateRisk, len(conditionalUpdates[i].Risks))
+		copy(risks, conditionalUpdates[i].Risks)
+		conditionalUpdates[i].Risks = risks
+		for j, risk := range risks {
+			rules, err := registry.PruneInvalid(ctx, risk.MatchingRules)
+			risks[j].MatchingRules = rules
+			if len(rules) == 0 {
+				logrus.Warningf("Conditional update to %s, risk %q, has empty pruned matchingRules; dropping this target to avoid rejections when pushing to the Kubernetes API server. Pruning results: %s", conditionalUpdates[i].Release.Version, risk.Name, err)
+				conditionalUpdates = append(conditionalUpdates[:i], conditionalUpdates[i+1:]...)
+				break
+			} else if err != nil {
+				logrus.Debugf("Conditional update to %s, risk %q, has pruned matchingRules (although other valid, recognized matchingRules were given, and are sufficient to keep the conditional update): %s", conditionalUpdates[i].Release.Version, risk.Name, err)
+			}
+		}
+	}
+	if len(conditionalUpdates) == 0 {
+		conditionalUpdates = nil
+	}
+	return conditionalUpdates
+}
+
+// AIDEV-NOTE: graph, node and edge mirror the CVO's private graph types instead of reusing fauxinnati.Graph
+// so that responses the CVO would reject (e.g. edges that are not pairs) are rejected here as well
+
+type graph struct {
+	Nodes            []node
+	Edges            []edge
+	ConditionalEdges []conditionalEdges `json:"conditionalEdges"`
+}
+
+type node struct {
+	Version  semver.Version    `json:"version"`
+	Image    string            `json:"payload"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type edge struct {
+	Origin      int
+	Destination int
+}
+
+type conditionalEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type conditionalEdges struct {
+	Edges []conditionalEdge                  `json:"edges"`
+	Risks []fauxinnati.ConditionalUpdateRisk `json:"risks"`
+}
+
+// UnmarshalJSON unmarshals an edge in the update graph. The edge's JSON
+// representation is a two-element array of indices, but Go's representation is
+// a struct with two elements so this custom unmarshal method is required.
+func (e *edge) UnmarshalJSON(data []byte) error {
+	var fields []int
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if len(fields) != 2 {
+		return fmt.Errorf("expected 2 fields, found %d", len(fields))
+	}
+
+	e.Origin = fields[0]
+	e.Destination = fields[1]
+
+	return nil
+}
+
+func convertRetrievedUpdateToRelease(update node) (Release, error) {
+	cvoUpdate := Release{
+		Version: update.Version.String(),
+		Image:   update.Image,
+	}
+	if urlString, ok := update.Metadata["url"]; ok {
+		_, err := url.Parse(urlString)
+		if err != nil {
+			return cvoUpdate, fmt.Errorf("invalid URL for %s: %s", cvoUpdate.Version, err)
+		}
+		cvoUpdate.URL = urlString
+	}
+	if arch, ok := update.Metadata["release.openshift.io/architecture"]; ok {
+		switch arch {
+		case "multi":
+			cvoUpdate.Architecture = ArchitectureMulti
+		default:
+			logrus.Warningf("Unrecognized release.openshift.io/architecture value %q", arch)
+		}
+	}
+	if channels, ok := update.Metadata["io.openshift.upgrades.graph.release.channels"]; ok {
+		cvoUpdate.Channels = strings.Split(channels, ",")
+		sort.Strings(cvoUpdate.Channels)
+	}
+	return cvoUpdate, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
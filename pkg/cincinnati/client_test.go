@@ -0,0 +1,230 @@
+package cincinnati
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+func newGraphServer(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	uri, err := url.Parse(server.URL + "/api/upgrades_info/graph")
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	return NewClient(uri, WithID("01234567-0123-0123-0123-0123456789ab"))
+}
+
+func serveGraph(graph fauxinnati.Graph) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(graph)
+	}
+}
+
+func graphNode(version string, channels string) fauxinnati.Node {
+	return fauxinnati.Node{
+		Version:  semver.MustParse(version),
+		Image:    "quay.io/openshift-release-dev/ocp-release:" + version,
+		Metadata: map[string]string{"io.openshift.upgrades.graph.release.channels": channels},
+	}
+}
+
+func release(version string, channels ...string) Release {
+	return Release{
+		Version:  version,
+		Image:    "quay.io/openshift-release-dev/ocp-release:" + version,
+		Channels: channels,
+	}
+}
+
+func TestClient_GetUpdates(t *testing.T) {
+	risk := fauxinnati.ConditionalUpdateRisk{
+		URL:           "https://example.com/risk",
+		Name:          "SomeRisk",
+		Message:       "Some risk",
+		MatchingRules: []fauxinnati.MatchingRule{{Type: "Always"}},
+	}
+
+	tests := []struct {
+		name        string
+		graph       fauxinnati.Graph
+		handler     http.HandlerFunc
+		desiredArch string
+		currentArch string
+
+		expectedCurrent     Release
+		expectedUpdates     []Release
+		expectedConditional []ConditionalUpdate
+		expectedReason      string
+	}{
+		{
+			name: "recommended and conditional updates",
+			graph: fauxinnati.Graph{
+				Nodes: []fauxinnati.Node{graphNode("4.17.5", "test"), graphNode("4.17.6", "test"), graphNode("4.17.7", "test")},
+				Edges: []fauxinnati.Edge{{0, 1}},
+				ConditionalEdges: []fauxinnati.ConditionalEdge{{
+					Edges: []fauxinnati.ConditionalUpdate{{From: "4.17.5", To: "4.17.7"}},
+					Risks: []fauxinnati.ConditionalUpdateRisk{risk},
+				}},
+			},
+			expectedCurrent:     release("4.17.5", "test"),
+			expectedUpdates:     []Release{release("4.17.6", "test")},
+			expectedConditional: []ConditionalUpdate{{Release: release("4.17.7", "test"), Risks: []fauxinnati.ConditionalUpdateRisk{risk}}},
+		},
+		{
+			name: "conditional update is preferred over an unconditional one",
+			graph: fauxinnati.Graph{
+				Nodes: []fauxinnati.Node{graphNode("4.17.5", "test"), graphNode("4.17.6", "test")},
+				Edges: []fauxinnati.Edge{{0, 1}},
+				ConditionalEdges: []fauxinnati.ConditionalEdge{{
+					Edges: []fauxinnati.ConditionalUpdate{{From: "4.17.5", To: "4.17.6"}},
+					Risks: []fauxinnati.ConditionalUpdateRisk{risk},
+				}},
+			},
+			expectedCurrent:     release("4.17.5", "test"),
+			expectedConditional: []ConditionalUpdate{{Release: release("4.17.6", "test"), Risks: []fauxinnati.ConditionalUpdateRisk{risk}}},
+		},
+		{
+			name: "duplicate conditional updates are all dropped",
+			graph: fauxinnati.Graph{
+				Nodes: []fauxinnati.Node{graphNode("4.17.5", "test"), graphNode("4.17.6", "test")},
+				Edges: []fauxinnati.Edge{},
+				ConditionalEdges: []fauxinnati.ConditionalEdge{
+					{Edges: []fauxinnati.ConditionalUpdate{{From: "4.17.5", To: "4.17.6"}}, Risks: []fauxinnati.ConditionalUpdateRisk{risk}},
+					{Edges: []fauxinnati.ConditionalUpdate{{From: "4.17.5", To: "4.17.6"}}, Risks: []fauxinnati.ConditionalUpdateRisk{risk}},
+				},
+			},
+			expectedCurrent: release("4.17.5", "test"),
+		},
+		{
+			name: "migration to multi offers only the current version",
+			graph: fauxinnati.Graph{
+				Nodes: []fauxinnati.Node{graphNode("4.17.5", "test"), graphNode("4.17.6", "test")},
+				Edges: []fauxinnati.Edge{{0, 1}},
+			},
+			desiredArch:     ArchitectureMulti,
+			currentArch:     "amd64",
+			expectedCurrent: release("4.17.5", "test"),
+			expectedUpdates: []Release{release("4.17.5", "test")},
+		},
+		{
+			name: "current version not in graph",
+			graph: fauxinnati.Graph{
+				Nodes: []fauxinnati.Node{graphNode("4.18.0", "test")},
+				Edges: []fauxinnati.Edge{},
+			},
+			expectedReason: ReasonVersionNotFound,
+		},
+		{
+			name: "edge pointing to a nonexistent node",
+			graph: fauxinnati.Graph{
+				Nodes: []fauxinnati.Node{graphNode("4.17.5", "test")},
+				Edges: []fauxinnati.Edge{{0, 1}},
+			},
+			expectedCurrent: release("4.17.5", "test"),
+			expectedReason:  ReasonResponseInvalid,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "boom", http.StatusInternalServerError)
+			},
+			expectedReason: ReasonResponseFailed,
+		},
+		{
+			name: "invalid response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"nodes": [], "edges": [[0, 1, 2]]}`))
+			},
+			expectedReason: ReasonResponseInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler
+			if handler == nil {
+				handler = serveGraph(tt.graph)
+			}
+			client := newGraphServer(t, handler)
+			desiredArch, currentArch := tt.desiredArch, tt.currentArch
+			if desiredArch == "" {
+				desiredArch, currentArch = "amd64", "amd64"
+			}
+
+			current, updates, conditional, err := client.GetUpdates(context.Background(), desiredArch, currentArch, "test", semver.MustParse("4.17.5"))
+
+			var reason string
+			if err != nil {
+				var cincinnatiErr *Error
+				if !errors.As(err, &cincinnatiErr) {
+					t.Fatalf("expected *Error, got %T: %v", err, err)
+				}
+				reason = cincinnatiErr.Reason
+			}
+			if diff := cmp.Diff(tt.expectedReason, reason); diff != "" {
+				t.Errorf("error reason mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedCurrent, current); diff != "" {
+				t.Errorf("current release mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedUpdates, updates); diff != "" {
+				t.Errorf("updates mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedConditional, conditional); diff != "" {
+				t.Errorf("conditional updates mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_GetGraph(t *testing.T) {
+	var query url.Values
+	client := newGraphServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		if accept := r.Header.Get("Accept"); accept != GraphMediaType {
+			t.Errorf("expected Accept %q, got %q", GraphMediaType, accept)
+		}
+		serveGraph(fauxinnati.Graph{Nodes: []fauxinnati.Node{graphNode("4.17.5", "test")}, Edges: []fauxinnati.Edge{}})(w, r)
+	})
+
+	graph, err := client.GetGraph(context.Background(), "test", "4.17.5", "arm64")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(graph.Nodes) != 1 || graph.Nodes[0].Version.String() != "4.17.5" {
+		t.Errorf("expected a single 4.17.5 node, got %v", graph.Nodes)
+	}
+	expectedQuery := url.Values{
+		"arch":    {"arm64"},
+		"channel": {"test"},
+		"id":      {"01234567-0123-0123-0123-0123456789ab"},
+		"version": {"4.17.5"},
+	}
+	if diff := cmp.Diff(expectedQuery, query); diff != "" {
+		t.Errorf("query mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_GetGraph_RemoteFailed(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	uri, _ := url.Parse(server.URL)
+	server.Close()
+
+	_, err := NewClient(uri).GetGraph(context.Background(), "test", "4.17.5", "amd64")
+	var cincinnatiErr *Error
+	if !errors.As(err, &cincinnatiErr) || cincinnatiErr.Reason != ReasonRemoteFailed {
+		t.Errorf("expected %s error, got %v", ReasonRemoteFailed, err)
+	}
+}
@@ -0,0 +1,142 @@
+package cincinnati
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/petr-muller/vibes/pkg/clusterconditions"
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+// Condition mirrors the metav1.Condition the CVO sets on conditional updates
+type Condition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// ConditionTypeRecommended is the type of the condition reporting whether a conditional update is recommended
+const ConditionTypeRecommended = "Recommended"
+
+// Statuses of the Recommended condition
+const (
+	ConditionTrue    = "True"
+	ConditionFalse   = "False"
+	ConditionUnknown = "Unknown"
+)
+
+// Reasons of the Recommended condition
+const (
+	// ReasonNotExposedToRisks is used when no risk matches the cluster
+	ReasonNotExposedToRisks = "NotExposedToRisks"
+	// ReasonEvaluationFailed is used when the exposure to a risk could not be evaluated
+	ReasonEvaluationFailed = "EvaluationFailed"
+	// ReasonMultipleReasons is used when risks lead to different reasons
+	ReasonMultipleReasons = "MultipleReasons"
+	// ReasonExposedToRisks is used when a matching risk name is not a valid reason
+	ReasonExposedToRisks = "ExposedToRisks"
+)
+
+// reasonPattern matches valid metav1.Condition reasons
+var reasonPattern = regexp.MustCompile(`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`)
+
+// Evaluate computes the updates a cluster running version would see in graph, evaluating the risks of
+// conditional updates with registry the way the CVO does. Matching rules the registry does not accept
+// are pruned first, and each conditional update gets a Recommended condition.
+func Evaluate(ctx context.Context, graph fauxinnati.Graph, version semver.Version,
+	registry *clusterconditions.Registry) (Release, []Release, []ConditionalUpdate, error) {
+
+	current, updates, conditionalUpdates, err := computeUpdates(fromGraph(graph), "", "", version, "the graph")
+	if err != nil {
+		return current, updates, conditionalUpdates, err
+	}
+	conditionalUpdates = pruneConditionalUpdates(ctx, conditionalUpdates, registry)
+	for i := range conditionalUpdates {
+		conditionalUpdates[i].Conditions = []Condition{EvaluateRisks(ctx, conditionalUpdates[i].Risks, registry)}
+	}
+	return current, updates, conditionalUpdates, nil
+}
+
+// EvaluateRisks returns the Recommended condition for an update with the given risks. The update is not
+// recommended (False) when any risk matches, and Unknown when no risk matches but some could not be evaluated.
+func EvaluateRisks(ctx context.Context, risks []fauxinnati.ConditionalUpdateRisk, registry *clusterconditions.Registry) Condition {
+	recommended := Condition{Type: ConditionTypeRecommended}
+	var messages []string
+	for _, risk := range risks {
+		match, err := registry.Match(ctx, risk.MatchingRules)
+		switch {
+		case err != nil:
+			recommended.Status = newRecommendedStatus(recommended.Status, ConditionUnknown)
+			recommended.Reason = newRecommendedReason(recommended.Reason, ReasonEvaluationFailed)
+			messages = append(messages, unknownExposureMessage(risk, err))
+		case match:
+			recommended.Status = newRecommendedStatus(recommended.Status, ConditionFalse)
+			reason := ReasonExposedToRisks
+			if reasonPattern.MatchString(risk.Name) {
+				reason = risk.Name
+			}
+			recommended.Reason = newRecommendedReason(recommended.Reason, reason)
+			messages = append(messages, fmt.Sprintf("%s %s", risk.Message, risk.URL))
+		}
+	}
+	if recommended.Status == "" {
+		recommended.Status = ConditionTrue
+		recommended.Reason = ReasonNotExposedToRisks
+		recommended.Message = "The update is recommended, because none of the conditional update risks apply to this cluster."
+		return recommended
+	}
+	recommended.Message = strings.Join(messages, "\n\n")
+	return recommended
+}
+
+// newRecommendedStatus combines statuses of individual risks: False wins over Unknown, which wins over True
+func newRecommendedStatus(now, want string) string {
+	switch {
+	case now == ConditionFalse || want == ConditionFalse:
+		return ConditionFalse
+	case now == ConditionUnknown || want == ConditionUnknown:
+		return ConditionUnknown
+	default:
+		return want
+	}
+}
+
+// newRecommendedReason combines reasons of individual risks, falling back to MultipleReasons when they differ
+func newRecommendedReason(now, want string) string {
+	switch {
+	case now == "" || now == want:
+		return want
+	default:
+		return ReasonMultipleReasons
+	}
+}
+
+func unknownExposureMessage(risk fauxinnati.ConditionalUpdateRisk, err error) string {
+	template := `Could not evaluate exposure to update risk %s (%v)
+  %s description: %s
+  %s URL: %s`
+	return fmt.Sprintf(template, risk.Name, err, risk.Name, risk.Message, risk.Name, risk.URL)
+}
+
+func fromGraph(g fauxinnati.Graph) graph {
+	converted := graph{}
+	for _, n := range g.Nodes {
+		converted.Nodes = append(converted.Nodes, node{Version: n.Version, Image: n.Image, Metadata: n.Metadata})
+	}
+	for _, e := range g.Edges {
+		converted.Edges = append(converted.Edges, edge{Origin: e[0], Destination: e[1]})
+	}
+	for _, ce := range g.ConditionalEdges {
+		var edges []conditionalEdge
+		for _, e := range ce.Edges {
+			edges = append(edges, conditionalEdge{From: e.From, To: e.To})
+		}
+		converted.ConditionalEdges = append(converted.ConditionalEdges, conditionalEdges{Edges: edges, Risks: ce.Risks})
+	}
+	return converted
+}
@@ -0,0 +1,159 @@
+package cincinnati
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/clusterconditions"
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+func risk(name string, rules ...fauxinnati.MatchingRule) fauxinnati.ConditionalUpdateRisk {
+	return fauxinnati.ConditionalUpdateRisk{
+		URL:           "https://example.com/" + name,
+		Name:          name,
+		Message:       name + " happens.",
+		MatchingRules: rules,
+	}
+}
+
+func promQLRule(query string) fauxinnati.MatchingRule {
+	return fauxinnati.MatchingRule{Type: "PromQL", PromQL: &fauxinnati.PromQLQuery{PromQL: query}}
+}
+
+func TestEvaluateRisks(t *testing.T) {
+	registry := clusterconditions.NewDefaultRegistry(clusterconditions.StaticQuerier{
+		"match":    {1},
+		"no-match": {0},
+	})
+	always := fauxinnati.MatchingRule{Type: "Always"}
+
+	tests := []struct {
+		name     string
+		risks    []fauxinnati.ConditionalUpdateRisk
+		expected Condition
+	}{
+		{
+			name:  "no matching risk is recommended",
+			risks: []fauxinnati.ConditionalUpdateRisk{risk("NoMatch", promQLRule("no-match"))},
+			expected: Condition{
+				Type:    ConditionTypeRecommended,
+				Status:  ConditionTrue,
+				Reason:  ReasonNotExposedToRisks,
+				Message: "The update is recommended, because none of the conditional update risks apply to this cluster.",
+			},
+		},
+		{
+			name:  "matching risk uses its name as the reason",
+			risks: []fauxinnati.ConditionalUpdateRisk{risk("SomeRisk", always)},
+			expected: Condition{
+				Type:    ConditionTypeRecommended,
+				Status:  ConditionFalse,
+				Reason:  "SomeRisk",
+				Message: "SomeRisk happens. https://example.com/SomeRisk",
+			},
+		},
+		{
+			name:  "matching risk with a name that is not a valid reason",
+			risks: []fauxinnati.ConditionalUpdateRisk{risk("Some Risk", always)},
+			expected: Condition{
+				Type:    ConditionTypeRecommended,
+				Status:  ConditionFalse,
+				Reason:  ReasonExposedToRisks,
+				Message: "Some Risk happens. https://example.com/Some Risk",
+			},
+		},
+		{
+			name:  "risk that cannot be evaluated is unknown",
+			risks: []fauxinnati.ConditionalUpdateRisk{risk("Unknown", promQLRule("missing"))},
+			expected: Condition{
+				Type:   ConditionTypeRecommended,
+				Status: ConditionUnknown,
+				Reason: ReasonEvaluationFailed,
+				Message: `Could not evaluate exposure to update risk Unknown (executing the PromQL query failed: no result for query "missing")
+  Unknown description: Unknown happens.
+  Unknown URL: https://example.com/Unknown`,
+			},
+		},
+		{
+			name:  "risk with only unrecognized rules does not apply",
+			risks: []fauxinnati.ConditionalUpdateRisk{risk("Unrecognized", fauxinnati.MatchingRule{Type: "Unrecognized"})},
+			expected: Condition{
+				Type:    ConditionTypeRecommended,
+				Status:  ConditionTrue,
+				Reason:  ReasonNotExposedToRisks,
+				Message: "The update is recommended, because none of the conditional update risks apply to this cluster.",
+			},
+		},
+		{
+			name: "matching risk wins over a failed evaluation",
+			risks: []fauxinnati.ConditionalUpdateRisk{
+				risk("Unknown", promQLRule("missing")),
+				risk("SomeRisk", promQLRule("match")),
+			},
+			expected: Condition{
+				Type:   ConditionTypeRecommended,
+				Status: ConditionFalse,
+				Reason: ReasonMultipleReasons,
+				Message: `Could not evaluate exposure to update risk Unknown (executing the PromQL query failed: no result for query "missing")
+  Unknown description: Unknown happens.
+  Unknown URL: https://example.com/Unknown
+
+SomeRisk happens. https://example.com/SomeRisk`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expected, EvaluateRisks(context.Background(), tt.risks, registry)); diff != "" {
+				t.Errorf("condition mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	always := fauxinnati.MatchingRule{Type: "Always"}
+	graph := fauxinnati.Graph{
+		Nodes: []fauxinnati.Node{graphNode("4.17.5", "test"), graphNode("4.17.6", "test"), graphNode("4.17.7", "test"), graphNode("4.17.8", "test")},
+		Edges: []fauxinnati.Edge{{0, 1}},
+		ConditionalEdges: []fauxinnati.ConditionalEdge{
+			{
+				Edges: []fauxinnati.ConditionalUpdate{{From: "4.17.5", To: "4.17.7"}},
+				Risks: []fauxinnati.ConditionalUpdateRisk{risk("SomeRisk", fauxinnati.MatchingRule{Type: "Unrecognized"}, always)},
+			},
+			{
+				Edges: []fauxinnati.ConditionalUpdate{{From: "4.17.5", To: "4.17.8"}},
+				Risks: []fauxinnati.ConditionalUpdateRisk{risk("Unrecognized", fauxinnati.MatchingRule{Type: "Unrecognized"})},
+			},
+		},
+	}
+
+	current, updates, conditional, err := Evaluate(context.Background(), graph, semver.MustParse("4.17.5"), clusterconditions.NewDefaultRegistry(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(release("4.17.5", "test"), current); diff != "" {
+		t.Errorf("current release mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]Release{release("4.17.6", "test")}, updates); diff != "" {
+		t.Errorf("updates mismatch (-want +got):\n%s", diff)
+	}
+	expected := []ConditionalUpdate{{
+		Release: release("4.17.7", "test"),
+		Risks:   []fauxinnati.ConditionalUpdateRisk{risk("SomeRisk", always)},
+		Conditions: []Condition{{
+			Type:    ConditionTypeRecommended,
+			Status:  ConditionFalse,
+			Reason:  "SomeRisk",
+			Message: "SomeRisk happens. https://example.com/SomeRisk",
+		}},
+	}}
+	if diff := cmp.Diff(expected, conditional); diff != "" {
+		t.Errorf("conditional updates mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,24 @@
+package clusterconditions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+// Always is the condition type that always matches
+type Always struct{}
+
+// Valid returns an error if the rule is not an Always rule
+func (a *Always) Valid(_ context.Context, rule *fauxinnati.MatchingRule) error {
+	if rule.Type != "Always" {
+		return errors.New(`the Always cluster condition type requires type "Always"`)
+	}
+	return nil
+}
+
+// Match always returns true
+func (a *Always) Match(_ context.Context, _ *fauxinnati.MatchingRule) (bool, error) {
+	return true, nil
+}
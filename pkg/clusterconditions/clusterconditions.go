@@ -0,0 +1,96 @@
+// Package clusterconditions evaluates the matching rules of conditional update risks the way the
+// cluster-version operator (CVO) does. Condition types are pluggable: a Registry maps rule types
+// to Condition implementations.
+package clusterconditions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+// Condition evaluates matching rules of a single type
+type Condition interface {
+	// Valid returns an error if the rule is invalid for the condition type
+	Valid(ctx context.Context, rule *fauxinnati.MatchingRule) error
+
+	// Match returns whether the cluster matches the rule
+	Match(ctx context.Context, rule *fauxinnati.MatchingRule) (bool, error)
+}
+
+// Registry maps matching rule types to their Condition implementations
+type Registry struct {
+	conditions map[string]Condition
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{conditions: map[string]Condition{}}
+}
+
+// NewDefaultRegistry creates a registry with the condition types the CVO knows: Always, and PromQL
+// evaluated with the given querier
+func NewDefaultRegistry(querier Querier) *Registry {
+	r := NewRegistry()
+	r.Register("Always", &Always{})
+	r.Register("PromQL", &PromQL{Querier: querier})
+	return r
+}
+
+// Register adds a condition type to the registry, replacing any condition previously registered for it
+func (r *Registry) Register(conditionType string, condition Condition) {
+	r.conditions[conditionType] = condition
+}
+
+// Types returns the sorted registered condition types
+func (r *Registry) Types() []string {
+	return slices.Sorted(maps.Keys(r.conditions))
+}
+
+// PruneInvalid returns the rules of recognized types that are valid, and an error describing any rules
+// that were dropped
+func (r *Registry) PruneInvalid(ctx context.Context, rules []fauxinnati.MatchingRule) ([]fauxinnati.MatchingRule, error) {
+	var valid []fauxinnati.MatchingRule
+	var errs []error
+	for i, rule := range rules {
+		condition, ok := r.conditions[rule.Type]
+		if !ok {
+			errs = append(errs, fmt.Errorf("skipping unrecognized cluster condition type %q", rule.Type))
+			continue
+		}
+		if err := condition.Valid(ctx, &rules[i]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		valid = append(valid, rule)
+	}
+	return valid, errors.Join(errs...)
+}
+
+// Match evaluates the rules in order. The first rule of a recognized type that evaluates without
+// an error decides the result; rules of unrecognized types and rules that fail to evaluate are skipped.
+// An error is returned when there are no rules or some rules failed to evaluate and none decided. Like
+// in the CVO, rules of only unrecognized types do not match.
+func (r *Registry) Match(ctx context.Context, rules []fauxinnati.MatchingRule) (bool, error) {
+	if len(rules) == 0 {
+		return false, errors.New("no cluster conditions")
+	}
+	var errs []error
+	for i, rule := range rules {
+		condition, ok := r.conditions[rule.Type]
+		if !ok {
+			continue
+		}
+		match, err := condition.Match(ctx, &rules[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return match, nil
+	}
+	return false, errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package clusterconditions
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func promQL(query string) fauxinnati.MatchingRule {
+	return fauxinnati.MatchingRule{Type: "PromQL", PromQL: &fauxinnati.PromQLQuery{PromQL: query}}
+}
+
+var always = fauxinnati.MatchingRule{Type: "Always"}
+
+func TestRegistry_Match(t *testing.T) {
+	querier := StaticQuerier{
+		"match":    {1},
+		"no-match": {0},
+		"empty":    {},
+		"two":      {1, 1},
+	}
+
+	tests := []struct {
+		name          string
+		rules         []fauxinnati.MatchingRule
+		expected      bool
+		expectedError error
+	}{
+		{
+			name:     "Always matches",
+			rules:    []fauxinnati.MatchingRule{always},
+			expected: true,
+		},
+		{
+			name:     "PromQL evaluating to 1 matches",
+			rules:    []fauxinnati.MatchingRule{promQL("match")},
+			expected: true,
+		},
+		{
+			name:  "PromQL evaluating to 0 does not match",
+			rules: []fauxinnati.MatchingRule{promQL("no-match")},
+		},
+		{
+			name:          "PromQL with an empty result fails",
+			rules:         []fauxinnati.MatchingRule{promQL("empty")},
+			expectedError: errors.New("invalid PromQL result length must be one: 0"),
+		},
+		{
+			name:          "PromQL with more than one sample fails",
+			rules:         []fauxinnati.MatchingRule{promQL("two")},
+			expectedError: errors.New("invalid PromQL result length must be one: 2"),
+		},
+		{
+			name:          "failing query fails",
+			rules:         []fauxinnati.MatchingRule{promQL("unknown")},
+			expectedError: errors.New(`executing the PromQL query failed: no result for query "unknown"`),
+		},
+		{
+			name:  "only unknown types do not match",
+			rules: []fauxinnati.MatchingRule{{Type: "Unknown"}, {Type: "AlsoUnknown"}},
+		},
+		{
+			name:          "unknown types are skipped in errors",
+			rules:         []fauxinnati.MatchingRule{{Type: "Unknown"}, promQL("unknown")},
+			expectedError: errors.New(`executing the PromQL query failed: no result for query "unknown"`),
+		},
+		{
+			name:          "no rules fail",
+			expectedError: errors.New("no cluster conditions"),
+		},
+		{
+			name:  "first recognized rule wins",
+			rules: []fauxinnati.MatchingRule{{Type: "Unknown"}, promQL("no-match"), always},
+		},
+		{
+			name:     "rules that fail to evaluate are skipped",
+			rules:    []fauxinnati.MatchingRule{promQL("unknown"), always},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := NewDefaultRegistry(querier).Match(context.Background(), tt.rules)
+			if diff := cmp.Diff(tt.expectedError, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+			if match != tt.expected {
+				t.Errorf("expected match=%t, got %t", tt.expected, match)
+			}
+		})
+	}
+}
+
+func TestRegistry_PruneInvalid(t *testing.T) {
+	rules := []fauxinnati.MatchingRule{
+		{Type: "Unknown"},
+		{Type: "PromQL"},
+		promQL(""),
+		promQL("vector(1)"),
+		always,
+	}
+	valid, err := NewDefaultRegistry(nil).PruneInvalid(context.Background(), rules)
+	if diff := cmp.Diff([]fauxinnati.MatchingRule{promQL("vector(1)"), always}, valid); diff != "" {
+		t.Errorf("valid rules mismatch (-want +got):\n%s", diff)
+	}
+	expectedErr := errors.New(`skipping unrecognized cluster condition type "Unknown"
+the PromQL cluster condition type requires a promql property
+the PromQL cluster condition type requires a non-empty promql.promql property`)
+	if diff := cmp.Diff(expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("error mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,72 @@
+package clusterconditions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/petr-muller/vibes/pkg/fauxinnati"
+)
+
+// Querier evaluates PromQL queries, returning the sample values of the resulting instant vector
+type Querier interface {
+	Query(ctx context.Context, query string) ([]float64, error)
+}
+
+// StaticQuerier is a Querier that answers queries from a map of query strings to results. Queries
+// missing from the map fail, which the CVO reports as a failed evaluation.
+type StaticQuerier map[string][]float64
+
+// Query returns the result stored for the query
+func (q StaticQuerier) Query(_ context.Context, query string) ([]float64, error) {
+	result, ok := q[query]
+	if !ok {
+		return nil, fmt.Errorf("no result for query %q", query)
+	}
+	return result, nil
+}
+
+// PromQL is the condition type that matches when a PromQL query evaluates to 1
+type PromQL struct {
+	Querier Querier
+}
+
+// Valid returns an error if the rule has no PromQL query
+func (p *PromQL) Valid(_ context.Context, rule *fauxinnati.MatchingRule) error {
+	if rule.Type != "PromQL" {
+		return errors.New(`the PromQL cluster condition type requires type "PromQL"`)
+	}
+	if rule.PromQL == nil {
+		return errors.New("the PromQL cluster condition type requires a promql property")
+	}
+	if rule.PromQL.PromQL == "" {
+		return errors.New("the PromQL cluster condition type requires a non-empty promql.promql property")
+	}
+	return nil
+}
+
+// Match runs the query and matches when it evaluates to a single sample of 1. A single sample of 0
+// does not match; any other result is an error.
+func (p *PromQL) Match(ctx context.Context, rule *fauxinnati.MatchingRule) (bool, error) {
+	if err := p.Valid(ctx, rule); err != nil {
+		return false, err
+	}
+	if p.Querier == nil {
+		return false, errors.New("no PromQL querier configured")
+	}
+	result, err := p.Querier.Query(ctx, rule.PromQL.PromQL)
+	if err != nil {
+		return false, fmt.Errorf("executing the PromQL query failed: %w", err)
+	}
+	if len(result) != 1 {
+		return false, fmt.Errorf("invalid PromQL result length must be one: %d", len(result))
+	}
+	switch result[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid PromQL result (must be 0 or 1): %v", result[0])
+	}
+}
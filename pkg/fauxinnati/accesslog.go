@@ -0,0 +1,57 @@
+package fauxinnati
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// quietPaths are polled by probes and scrapers; their requests are logged at the debug level only
+var quietPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// WithLogging wraps h with an access log written to the logrus standard logger, see accessLog
+func WithLogging(h http.Handler) http.Handler {
+	return accessLog(logrus.StandardLogger(), realClock{}, h)
+}
+
+// accessLog wraps h with an access log entry per request, logged after the handler completes so that it contains
+// the status code, the size of the response body and the latency. Graph requests also log the channel and version.
+func accessLog(logger logrus.FieldLogger, clock Clock, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := clock.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		// Deferred so that requests aborted by a panic (e.g. a reset fault) are logged too
+		defer func() {
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			fields := logrus.Fields{
+				"address":   r.RemoteAddr,
+				"method":    r.Method,
+				"path":      r.URL.Path,
+				"status":    status,
+				"bytes":     recorder.bytes,
+				"latency":   clock.Now().Sub(start).String(),
+				"userAgent": r.UserAgent(),
+			}
+			query := r.URL.Query()
+			for _, param := range []string{"channel", "version"} {
+				if value := query.Get(param); value != "" {
+					fields[param] = value
+				}
+			}
+			entry := logger.WithFields(fields)
+			if quietPaths[r.URL.Path] {
+				entry.Debug("Access log.")
+			} else {
+				entry.Info("Access log.")
+			}
+		}()
+		h.ServeHTTP(recorder, r)
+	})
+}
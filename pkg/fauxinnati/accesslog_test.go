@@ -0,0 +1,98 @@
+package fauxinnati
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	tests := []struct {
+		name          string
+		target        string
+		expectedLevel logrus.Level
+		expected      logrus.Fields
+	}{
+		{
+			name:          "graph request logs channel and version",
+			target:        "/api/upgrades_info/graph?channel=simple&version=4.17.5",
+			expectedLevel: logrus.InfoLevel,
+			expected: logrus.Fields{
+				"address":   "192.0.2.1:1234",
+				"method":    http.MethodGet,
+				"path":      "/api/upgrades_info/graph",
+				"status":    http.StatusOK,
+				"bytes":     int64(5),
+				"latency":   "250ms",
+				"userAgent": "ClusterVersionOperator/4.17",
+				"channel":   "simple",
+				"version":   "4.17.5",
+			},
+		},
+		{
+			name:          "error status is logged",
+			target:        "/missing",
+			expectedLevel: logrus.InfoLevel,
+			expected: logrus.Fields{
+				"address":   "192.0.2.1:1234",
+				"method":    http.MethodGet,
+				"path":      "/missing",
+				"status":    http.StatusNotFound,
+				"bytes":     int64(10),
+				"latency":   "250ms",
+				"userAgent": "ClusterVersionOperator/4.17",
+			},
+		},
+		{
+			name:          "probes are logged at debug level",
+			target:        "/healthz",
+			expectedLevel: logrus.DebugLevel,
+			expected: logrus.Fields{
+				"address":   "192.0.2.1:1234",
+				"method":    http.MethodGet,
+				"path":      "/healthz",
+				"status":    http.StatusOK,
+				"bytes":     int64(5),
+				"latency":   "250ms",
+				"userAgent": "ClusterVersionOperator/4.17",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			logger.SetLevel(logrus.DebugLevel)
+			clock := &testClock{now: time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC), step: 250 * time.Millisecond}
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("User-Agent", "ClusterVersionOperator/4.17")
+			accessLog(logger, clock, handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := hook.AllEntries()
+			if len(entries) != 1 {
+				t.Fatalf("expected one access log entry, got %d", len(entries))
+			}
+			if entries[0].Level != tt.expectedLevel {
+				t.Errorf("expected level %s, got %s", tt.expectedLevel, entries[0].Level)
+			}
+			if diff := cmp.Diff(tt.expected, entries[0].Data); diff != "" {
+				t.Errorf("unexpected access log fields (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
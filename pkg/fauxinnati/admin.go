@@ -0,0 +1,445 @@
+package fauxinnati
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// AIDEV-NOTE: Static graphs are set over the authenticated admin API for manual exploratory testing
+// They are served regardless of the queried version and replace any other channel with the same name, including
+// scenarios loaded from a directory. They live in memory only and are lost when the server restarts.
+
+// Graph edit operations accepted by StaticGraphEdit
+const (
+	EditAddNode    = "addNode"
+	EditRemoveNode = "removeNode"
+	EditAddEdge    = "addEdge"
+	EditRemoveEdge = "removeEdge"
+	EditAddRisk    = "addRisk"
+	EditRemoveRisk = "removeRisk"
+)
+
+// StaticGraphEdit is a single change of a static graph. Edges are addressed by the versions they connect.
+type StaticGraphEdit struct {
+	Op string `json:"op"`
+	// Node is the node added by addNode
+	Node *Node `json:"node,omitempty"`
+	// Version is the version of the node removed by removeNode
+	Version string `json:"version,omitempty"`
+	// From and To select the edge of addEdge, removeEdge and addRisk
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Risk is the risk attached to the edge by addRisk, turning an unconditional edge into a conditional one
+	Risk *ConditionalUpdateRisk `json:"risk,omitempty"`
+	// Name is the name of the risk removed from all conditional edges by removeRisk. Conditional edges left without
+	// risks become unconditional edges.
+	Name string `json:"name,omitempty"`
+}
+
+// Apply returns a copy of graph with the edit applied. Unlike overlays, edits referencing versions or edges that
+// are not in the graph fail. The graph must be valid; the edited graph is not validated, EditStaticGraph validates
+// it before serving it.
+func (e StaticGraphEdit) Apply(graph Graph) (Graph, error) {
+	g := indexGraph(graph)
+	requireNode := func(version string) error {
+		if g.index(version) < 0 {
+			return fmt.Errorf("version %q is not in the graph", version)
+		}
+		return nil
+	}
+	requireEdge := func() error {
+		if err := requireNode(e.From); err != nil {
+			return err
+		}
+		return requireNode(e.To)
+	}
+	edge := [2]string{e.From, e.To}
+	conditional := func(conditionalEdge ConditionalEdge) int {
+		return slices.Index(conditionalEdge.Edges, ConditionalUpdate{From: e.From, To: e.To})
+	}
+
+	switch e.Op {
+	case EditAddNode:
+		if e.Node == nil {
+			return Graph{}, fmt.Errorf("%s: missing node", e.Op)
+		}
+		if !g.addNode(*e.Node) {
+			return Graph{}, fmt.Errorf("%s: version %s is already in the graph", e.Op, e.Node.Version)
+		}
+	case EditRemoveNode:
+		if err := requireNode(e.Version); err != nil {
+			return Graph{}, fmt.Errorf("%s: %w", e.Op, err)
+		}
+		g.removeNode(e.Version)
+		g.removeConditionalEdges(func(edge ConditionalUpdate) bool { return edge.From == e.Version || edge.To == e.Version })
+	case EditAddEdge:
+		if err := requireEdge(); err != nil {
+			return Graph{}, fmt.Errorf("%s: %w", e.Op, err)
+		}
+		if g.hasEdge(edge) || slices.ContainsFunc(g.conditionalEdges, func(c ConditionalEdge) bool { return conditional(c) >= 0 }) {
+			return Graph{}, fmt.Errorf("%s: edge %s->%s is already in the graph", e.Op, e.From, e.To)
+		}
+		g.addEdge(e.From, e.To)
+	case EditRemoveEdge:
+		if err := requireEdge(); err != nil {
+			return Graph{}, fmt.Errorf("%s: %w", e.Op, err)
+		}
+		removed := g.removeEdges(func(existing [2]string) bool { return existing == edge }) > 0
+		removed = g.removeConditionalEdges(func(existing ConditionalUpdate) bool { return existing.From == e.From && existing.To == e.To }) || removed
+		if !removed {
+			return Graph{}, fmt.Errorf("%s: edge %s->%s is not in the graph", e.Op, e.From, e.To)
+		}
+	case EditAddRisk:
+		if err := requireEdge(); err != nil {
+			return Graph{}, fmt.Errorf("%s: %w", e.Op, err)
+		}
+		if e.Risk == nil {
+			return Graph{}, fmt.Errorf("%s: missing risk", e.Op)
+		}
+		for _, problem := range validateRisk("risk", *e.Risk) {
+			if problem.Severity == SeverityError {
+				return Graph{}, fmt.Errorf("%s: %s", e.Op, problem.Message)
+			}
+		}
+		if err := g.addRisk(edge, *e.Risk); err != nil {
+			return Graph{}, fmt.Errorf("%s: %w", e.Op, err)
+		}
+	case EditRemoveRisk:
+		if e.Name == "" {
+			return Graph{}, fmt.Errorf("%s: missing risk name", e.Op)
+		}
+		found := false
+		var conditionalEdges []ConditionalEdge
+		for _, conditionalEdge := range g.conditionalEdges {
+			risks := slices.DeleteFunc(slices.Clone(conditionalEdge.Risks), func(risk ConditionalUpdateRisk) bool { return risk.Name == e.Name })
+			found = found || len(risks) < len(conditionalEdge.Risks)
+			if len(risks) > 0 {
+				conditionalEdges = append(conditionalEdges, ConditionalEdge{Edges: conditionalEdge.Edges, Risks: risks})
+				continue
+			}
+			for _, unblocked := range conditionalEdge.Edges {
+				g.addEdge(unblocked.From, unblocked.To)
+			}
+		}
+		if !found {
+			return Graph{}, fmt.Errorf("%s: risk %s is not in the graph", e.Op, e.Name)
+		}
+		g.conditionalEdges = conditionalEdges
+	default:
+		return Graph{}, fmt.Errorf("unknown edit operation %q (one of %s)", e.Op, strings.Join([]string{EditAddNode, EditRemoveNode, EditAddEdge, EditRemoveEdge, EditAddRisk, EditRemoveRisk}, ", "))
+	}
+
+	return g.graph(), nil
+}
+
+// removeConditionalEdges removes the matching edges from conditional edges, dropping conditional edges left without
+// edges, and reports whether any edge was removed
+func (g *graphIndex) removeConditionalEdges(matches func(ConditionalUpdate) bool) bool {
+	removed := false
+	var conditionalEdges []ConditionalEdge
+	for _, conditionalEdge := range g.conditionalEdges {
+		edges := slices.DeleteFunc(slices.Clone(conditionalEdge.Edges), matches)
+		removed = removed || len(edges) < len(conditionalEdge.Edges)
+		if len(edges) > 0 {
+			conditionalEdges = append(conditionalEdges, ConditionalEdge{Edges: edges, Risks: conditionalEdge.Risks})
+		}
+	}
+	g.conditionalEdges = conditionalEdges
+	return removed
+}
+
+// addRisk attaches the risk to the edge. An unconditional edge becomes a conditional edge; a conditional edge
+// sharing its risks with other edges is split from them first.
+func (g *graphIndex) addRisk(edge [2]string, risk ConditionalUpdateRisk) error {
+	update := ConditionalUpdate{From: edge[0], To: edge[1]}
+	if g.hasEdge(edge) {
+		g.removeEdges(func(existing [2]string) bool { return existing == edge })
+		g.conditionalEdges = append(g.conditionalEdges, ConditionalEdge{Edges: []ConditionalUpdate{update}, Risks: []ConditionalUpdateRisk{risk}})
+		return nil
+	}
+	for i, conditionalEdge := range g.conditionalEdges {
+		if !slices.Contains(conditionalEdge.Edges, update) {
+			continue
+		}
+		if slices.ContainsFunc(conditionalEdge.Risks, func(existing ConditionalUpdateRisk) bool { return existing.Name == risk.Name }) {
+			return fmt.Errorf("edge %s->%s already has risk %s", edge[0], edge[1], risk.Name)
+		}
+		risks := append(slices.Clone(conditionalEdge.Risks), risk)
+		if len(conditionalEdge.Edges) == 1 {
+			g.conditionalEdges[i].Risks = risks
+			return nil
+		}
+		g.removeConditionalEdges(func(existing ConditionalUpdate) bool { return existing == update })
+		g.conditionalEdges = append(g.conditionalEdges, ConditionalEdge{Edges: []ConditionalUpdate{update}, Risks: risks})
+		return nil
+	}
+	return fmt.Errorf("edge %s->%s is not in the graph", edge[0], edge[1])
+}
+
+// StaticGraph returns the static graph of the channel set over the admin API
+func (s *Server) StaticGraph(channel string) (Graph, bool) {
+	s.channelsLock.Lock()
+	defer s.channelsLock.Unlock()
+	graph, ok := s.staticGraphs[channel]
+	return graph, ok
+}
+
+// SetStaticGraph serves the graph as the channel regardless of the queried version, replacing any other channel
+// with the same name. It reports whether the channel had no static graph before.
+func (s *Server) SetStaticGraph(channel string, graph Graph) (bool, error) {
+	return s.updateStaticGraph(channel, true, func(Graph) (Graph, error) { return graph, nil })
+}
+
+// EditStaticGraph applies the edit to the static graph of the channel, and fails when the edited graph is invalid
+func (s *Server) EditStaticGraph(channel string, edit StaticGraphEdit) (Graph, error) {
+	var edited Graph
+	_, err := s.updateStaticGraph(channel, false, func(graph Graph) (Graph, error) {
+		var err error
+		edited, err = edit.Apply(graph)
+		return edited, err
+	})
+	if err != nil {
+		return Graph{}, err
+	}
+	return edited, nil
+}
+
+// DeleteStaticGraph stops serving the static graph of the channel, and reports whether it existed. A channel
+// replaced by the static graph is served again.
+func (s *Server) DeleteStaticGraph(channel string) bool {
+	s.channelsLock.Lock()
+	defer s.channelsLock.Unlock()
+	if _, ok := s.staticGraphs[channel]; !ok {
+		return false
+	}
+	delete(s.staticGraphs, channel)
+	s.publishChannels()
+	return true
+}
+
+// errNoStaticGraph is returned when editing a channel without a static graph
+type errNoStaticGraph string
+
+func (e errNoStaticGraph) Error() string {
+	return fmt.Sprintf("channel %s has no static graph", string(e))
+}
+
+// updateStaticGraph replaces the static graph of the channel with the result of update. Graphs set and edited over
+// the admin API are validated only here, before they are served.
+func (s *Server) updateStaticGraph(channel string, create bool, update func(Graph) (Graph, error)) (bool, error) {
+	if channel == "" || strings.TrimSpace(channel) != channel {
+		return false, fmt.Errorf("invalid channel name %q", channel)
+	}
+	s.channelsLock.Lock()
+	defer s.channelsLock.Unlock()
+	current, ok := s.staticGraphs[channel]
+	if !ok && !create {
+		return false, errNoStaticGraph(channel)
+	}
+	updated, err := update(current)
+	if err != nil {
+		return false, err
+	}
+	if err := ValidateGraph(updated); err != nil {
+		return false, err
+	}
+	if updated.Nodes == nil {
+		updated.Nodes = []Node{}
+	}
+	if updated.Edges == nil {
+		updated.Edges = []Edge{}
+	}
+	if updated.ConditionalEdges == nil {
+		updated.ConditionalEdges = []ConditionalEdge{}
+	}
+	s.staticGraphs[channel] = updated
+	s.publishChannels()
+	return !ok, nil
+}
+
+// publishChannels rebuilds the served channels after channels changed; channelsLock must be held
+func (s *Server) publishChannels() {
+	current := s.channelSet.Load()
+	next := *current
+	next.registry = s.buildChannelRegistry(current.loaded)
+	s.channelSet.Store(&next)
+}
+
+func (s *Server) staticChannel(name string, graph Graph) Channel {
+	return Channel{
+		Name:        name,
+		Description: fmt.Sprintf("Static graph with %d nodes set over the admin API.", len(graph.Nodes)),
+		Generate: func(_ semver.Version, _ string, _ string) Graph {
+			return graph
+		},
+	}
+}
+
+// authorizeAdmin reports whether the request carries the admin token, responding with an error if it does not
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		http.Error(w, "Admin API is disabled, configure an admin token to enable it", http.StatusForbidden)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fauxinnati"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// decodeStrict decodes a JSON request body, rejecting unknown fields
+func decodeStrict(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to target
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	merged := maps.Clone(targetObject)
+	for key, value := range patchObject {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergePatch(merged[key], value)
+	}
+	return merged
+}
+
+// patchGraph applies a JSON merge patch to the graph
+func patchGraph(graph Graph, patch []byte) (Graph, error) {
+	var target, patchValue any
+	current, err := json.Marshal(graph)
+	if err != nil {
+		return Graph{}, err
+	}
+	if err := json.Unmarshal(current, &target); err != nil {
+		return Graph{}, err
+	}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return Graph{}, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	merged, err := json.Marshal(mergePatch(target, patchValue))
+	if err != nil {
+		return Graph{}, err
+	}
+	var patched Graph
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return Graph{}, fmt.Errorf("invalid patched graph: %w", err)
+	}
+	return patched, nil
+}
+
+func (s *Server) handleAdminChannel(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	channel := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		graph, ok := s.StaticGraph(channel)
+		if !ok {
+			http.Error(w, errNoStaticGraph(channel).Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, graph)
+	case http.MethodPut:
+		var graph Graph
+		if err := decodeStrict(r, &graph); err != nil {
+			http.Error(w, fmt.Sprintf("invalid graph: %v", err), http.StatusBadRequest)
+			return
+		}
+		created, err := s.SetStaticGraph(channel, graph)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid graph: %v", err), http.StatusBadRequest)
+			return
+		}
+		graph, _ = s.StaticGraph(channel)
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, graph)
+	case http.MethodPatch:
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "" && mediaType != "application/merge-patch+json" && mediaType != MediaTypeJSON {
+			http.Error(w, "Unsupported content type, send a JSON merge patch (application/merge-patch+json)", http.StatusUnsupportedMediaType)
+			return
+		}
+		patch, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read the patch: %v", err), http.StatusBadRequest)
+			return
+		}
+		var patched Graph
+		_, err = s.updateStaticGraph(channel, false, func(graph Graph) (Graph, error) {
+			patched, err = patchGraph(graph, patch)
+			return patched, err
+		})
+		s.writeStaticGraphUpdate(w, channel, err)
+	case http.MethodDelete:
+		if !s.DeleteStaticGraph(channel) {
+			http.Error(w, errNoStaticGraph(channel).Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleAdminChannelEdits(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	channel := r.PathValue("name")
+	var edit StaticGraphEdit
+	if err := decodeStrict(r, &edit); err != nil {
+		http.Error(w, fmt.Sprintf("invalid edit: %v", err), http.StatusBadRequest)
+		return
+	}
+	_, err := s.EditStaticGraph(channel, edit)
+	s.writeStaticGraphUpdate(w, channel, err)
+}
+
+// writeStaticGraphUpdate responds with the updated static graph of the channel, or with the update error
+func (s *Server) writeStaticGraphUpdate(w http.ResponseWriter, channel string, err error) {
+	var missing errNoStaticGraph
+	switch {
+	case errors.As(err, &missing):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		graph, _ := s.StaticGraph(channel)
+		writeJSON(w, http.StatusOK, graph)
+	}
+}
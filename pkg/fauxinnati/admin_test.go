@@ -0,0 +1,301 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestStaticGraphEdit_Apply(t *testing.T) {
+	risk := ConditionalUpdateRisk{Name: "Blocked", URL: "https://example.com/blocked", Message: "Blocked", MatchingRules: []MatchingRule{{Type: "Always"}}}
+	added := NewNode(semver.MustParse("4.17.4"), "stable-4.17")
+	testCases := []struct {
+		name        string
+		edit        StaticGraphEdit
+		expected    func(Graph) Graph
+		expectedErr error
+	}{
+		{
+			name: "add node",
+			edit: StaticGraphEdit{Op: EditAddNode, Node: &added},
+			expected: func(g Graph) Graph {
+				g.Nodes = append(g.Nodes, added)
+				return g
+			},
+		},
+		{
+			name:        "add node already in the graph",
+			edit:        StaticGraphEdit{Op: EditAddNode, Node: &Node{Version: semver.MustParse("4.17.1")}},
+			expectedErr: errors.New("addNode: version 4.17.1 is already in the graph"),
+		},
+		{
+			name: "removed node takes its edges with it",
+			edit: StaticGraphEdit{Op: EditRemoveNode, Version: "4.17.1"},
+			expected: func(g Graph) Graph {
+				g.Nodes = []Node{g.Nodes[0], g.Nodes[2], g.Nodes[3]}
+				g.Edges = []Edge{{0, 1}}
+				g.ConditionalEdges[0].Edges = g.ConditionalEdges[0].Edges[:1]
+				return g
+			},
+		},
+		{
+			name:        "remove node not in the graph",
+			edit:        StaticGraphEdit{Op: EditRemoveNode, Version: "4.17.9"},
+			expectedErr: errors.New(`removeNode: version "4.17.9" is not in the graph`),
+		},
+		{
+			name: "add edge",
+			edit: StaticGraphEdit{Op: EditAddEdge, From: "4.17.2", To: "4.17.3"},
+			expected: func(g Graph) Graph {
+				g.Edges = append(g.Edges, Edge{2, 3})
+				return g
+			},
+		},
+		{
+			name:        "add edge that is conditional",
+			edit:        StaticGraphEdit{Op: EditAddEdge, From: "4.17.0", To: "4.17.3"},
+			expectedErr: errors.New("addEdge: edge 4.17.0->4.17.3 is already in the graph"),
+		},
+		{
+			name:        "add edge to a version not in the graph",
+			edit:        StaticGraphEdit{Op: EditAddEdge, From: "4.17.0", To: "4.17.9"},
+			expectedErr: errors.New(`addEdge: version "4.17.9" is not in the graph`),
+		},
+		{
+			name: "remove unconditional edge",
+			edit: StaticGraphEdit{Op: EditRemoveEdge, From: "4.17.0", To: "4.17.2"},
+			expected: func(g Graph) Graph {
+				g.Edges = []Edge{{0, 1}, {1, 2}}
+				return g
+			},
+		},
+		{
+			name: "remove conditional edge",
+			edit: StaticGraphEdit{Op: EditRemoveEdge, From: "4.17.0", To: "4.17.3"},
+			expected: func(g Graph) Graph {
+				g.ConditionalEdges[0].Edges = g.ConditionalEdges[0].Edges[1:]
+				return g
+			},
+		},
+		{
+			name:        "remove edge not in the graph",
+			edit:        StaticGraphEdit{Op: EditRemoveEdge, From: "4.17.2", To: "4.17.3"},
+			expectedErr: errors.New("removeEdge: edge 4.17.2->4.17.3 is not in the graph"),
+		},
+		{
+			name: "risk attached to an unconditional edge makes it conditional",
+			edit: StaticGraphEdit{Op: EditAddRisk, From: "4.17.1", To: "4.17.2", Risk: &risk},
+			expected: func(g Graph) Graph {
+				g.Edges = []Edge{{0, 1}, {0, 2}}
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{
+					Edges: []ConditionalUpdate{{From: "4.17.1", To: "4.17.2"}},
+					Risks: []ConditionalUpdateRisk{risk},
+				})
+				return g
+			},
+		},
+		{
+			name: "risk attached to a conditional edge splits it from the edges sharing its risks",
+			edit: StaticGraphEdit{Op: EditAddRisk, From: "4.17.1", To: "4.17.3", Risk: &risk},
+			expected: func(g Graph) Graph {
+				shared := g.ConditionalEdges[0]
+				g.ConditionalEdges = []ConditionalEdge{
+					{Edges: shared.Edges[:1], Risks: shared.Risks},
+					{Edges: shared.Edges[1:], Risks: append(shared.Risks, risk)},
+				}
+				return g
+			},
+		},
+		{
+			name:        "risk already attached to the edge",
+			edit:        StaticGraphEdit{Op: EditAddRisk, From: "4.17.1", To: "4.17.3", Risk: &ConditionalUpdateRisk{Name: "RiskA", MatchingRules: []MatchingRule{{Type: "Always"}}}},
+			expectedErr: errors.New("addRisk: edge 4.17.1->4.17.3 already has risk RiskA"),
+		},
+		{
+			name:        "risk without matching rules",
+			edit:        StaticGraphEdit{Op: EditAddRisk, From: "4.17.1", To: "4.17.2", Risk: &ConditionalUpdateRisk{Name: "Blocked"}},
+			expectedErr: errors.New("addRisk: risk Blocked has no matching rules"),
+		},
+		{
+			name: "removing a risk keeps edges conditional with the other risks",
+			edit: StaticGraphEdit{Op: EditRemoveRisk, Name: "RiskB"},
+			expected: func(g Graph) Graph {
+				g.ConditionalEdges[0].Risks = g.ConditionalEdges[0].Risks[:1]
+				return g
+			},
+		},
+		{
+			name:        "remove risk not in the graph",
+			edit:        StaticGraphEdit{Op: EditRemoveRisk, Name: "RiskC"},
+			expectedErr: errors.New("removeRisk: risk RiskC is not in the graph"),
+		},
+		{
+			name:        "unknown operation",
+			edit:        StaticGraphEdit{Op: "blockEdge"},
+			expectedErr: errors.New(`unknown edit operation "blockEdge" (one of addNode, removeNode, addEdge, removeEdge, addRisk, removeRisk)`),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.edit.Apply(proxyTestGraph())
+			if diff := cmp.Diff(tc.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("error mismatch (-want +got):\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.expected(proxyTestGraph()), got); diff != "" {
+				t.Errorf("unexpected graph (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_handleAdminChannel(t *testing.T) {
+	server := NewServer(WithAdminToken("secret"))
+	serve := func(method, target, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		server.mux.ServeHTTP(w, req)
+		return w
+	}
+	graph := proxyTestGraph()
+	body, err := json.Marshal(graph)
+	if err != nil {
+		t.Fatalf("failed to marshal graph: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		token          string
+		body           string
+		expectedStatus int
+		expected       func(Graph) Graph
+	}{
+		{name: "unauthenticated", method: http.MethodGet, target: "/api/admin/channels/stable-4.17", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, target: "/api/admin/channels/stable-4.17", token: "guess", expectedStatus: http.StatusUnauthorized},
+		{name: "get without a static graph", method: http.MethodGet, target: "/api/admin/channels/stable-4.17", token: "secret", expectedStatus: http.StatusNotFound},
+		{name: "edit without a static graph", method: http.MethodPost, target: "/api/admin/channels/stable-4.17/edits", token: "secret", body: `{"op": "removeNode", "version": "4.17.1"}`, expectedStatus: http.StatusNotFound},
+		{name: "put invalid graph", method: http.MethodPut, target: "/api/admin/channels/stable-4.17", token: "secret", body: `{"nodes": [], "edges": [[0, 1]]}`, expectedStatus: http.StatusBadRequest},
+		{name: "put unknown fields", method: http.MethodPut, target: "/api/admin/channels/stable-4.17", token: "secret", body: `{"nodes": [], "vertices": []}`, expectedStatus: http.StatusBadRequest},
+		{name: "put", method: http.MethodPut, target: "/api/admin/channels/stable-4.17", token: "secret", body: string(body), expectedStatus: http.StatusCreated, expected: func(g Graph) Graph { return g }},
+		{name: "put again", method: http.MethodPut, target: "/api/admin/channels/stable-4.17", token: "secret", body: string(body), expectedStatus: http.StatusOK, expected: func(g Graph) Graph { return g }},
+		{name: "get", method: http.MethodGet, target: "/api/admin/channels/stable-4.17", token: "secret", expectedStatus: http.StatusOK, expected: func(g Graph) Graph { return g }},
+		{
+			name:           "patch",
+			method:         http.MethodPatch,
+			target:         "/api/admin/channels/stable-4.17",
+			token:          "secret",
+			body:           `{"edges": [[0, 1]], "conditionalEdges": null}`,
+			expectedStatus: http.StatusOK,
+			expected: func(g Graph) Graph {
+				g.Edges = []Edge{{0, 1}}
+				g.ConditionalEdges = []ConditionalEdge{}
+				return g
+			},
+		},
+		{name: "patch into an invalid graph", method: http.MethodPatch, target: "/api/admin/channels/stable-4.17", token: "secret", body: `{"edges": [[0, 7]]}`, expectedStatus: http.StatusBadRequest},
+		{
+			name:           "edit",
+			method:         http.MethodPost,
+			target:         "/api/admin/channels/stable-4.17/edits",
+			token:          "secret",
+			body:           `{"op": "addRisk", "from": "4.17.0", "to": "4.17.1", "risk": {"name": "Blocked", "url": "https://example.com/blocked", "message": "Blocked", "matchingRules": [{"type": "Always"}]}}`,
+			expectedStatus: http.StatusOK,
+			expected: func(g Graph) Graph {
+				g.Edges = []Edge{}
+				g.ConditionalEdges = []ConditionalEdge{{
+					Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.1"}},
+					Risks: []ConditionalUpdateRisk{{Name: "Blocked", URL: "https://example.com/blocked", Message: "Blocked", MatchingRules: []MatchingRule{{Type: "Always"}}}},
+				}}
+				return g
+			},
+		},
+		{
+			name:           "edit removing a conditional edge",
+			method:         http.MethodPost,
+			target:         "/api/admin/channels/stable-4.17/edits",
+			token:          "secret",
+			body:           `{"op": "removeEdge", "from": "4.17.0", "to": "4.17.1"}`,
+			expectedStatus: http.StatusOK,
+			expected: func(g Graph) Graph {
+				g.Edges = []Edge{}
+				g.ConditionalEdges = []ConditionalEdge{}
+				return g
+			},
+		},
+		{name: "edit of a removed edge", method: http.MethodPost, target: "/api/admin/channels/stable-4.17/edits", token: "secret", body: `{"op": "removeEdge", "from": "4.17.0", "to": "4.17.1"}`, expectedStatus: http.StatusBadRequest},
+		{name: "edit into an invalid graph", method: http.MethodPost, target: "/api/admin/channels/stable-4.17/edits", token: "secret", body: `{"op": "addEdge", "from": "4.17.1", "to": "4.17.1"}`, expectedStatus: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, target: "/api/admin/channels/stable-4.17", token: "secret", expectedStatus: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, target: "/api/admin/channels/stable-4.17", token: "secret", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.method, tt.target, tt.token, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expected == nil {
+				return
+			}
+			var got Graph
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal graph: %v", err)
+			}
+			expected := tt.expected(proxyTestGraph())
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Errorf("unexpected static graph (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(expected, queryGraph(t, server, "channel=stable-4.17&version=4.22.0")); diff != "" {
+				t.Errorf("static graph is not served (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_handleAdminChannel_Disabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/channels/stable-4.17", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	NewServer().mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected the admin API to be disabled without a token, got status %d", w.Code)
+	}
+}
+
+func TestServer_StaticGraph_LandingPage(t *testing.T) {
+	server := NewServer(
+		WithAdminToken("secret"),
+		WithHTTPClient(NewOfflineClient()),
+		WithCandidatesSource(NewGraphDataDirCandidatesSource("testdata/graph-data")),
+		WithDigestResolver(testDigestResolver),
+	)
+	if _, err := server.SetStaticGraph("simple", proxyTestGraph()); err != nil {
+		t.Fatalf("failed to set static graph: %v", err)
+	}
+	html := server.generateRootHTML("localhost:8080")
+	if !strings.Contains(html, "Static graph with 4 nodes set over the admin API.") || !strings.Contains(html, "4.17.3") {
+		t.Errorf("expected the landing page to show the static graph replacing the simple channel")
+	}
+	if !server.DeleteStaticGraph("simple") {
+		t.Fatalf("expected the static graph to be deleted")
+	}
+	if graph := queryGraph(t, server, "channel=simple&version=4.17.5"); len(graph.Nodes) != 3 {
+		t.Errorf("expected the simple scenario to be served again, got %+v", graph)
+	}
+}
@@ -0,0 +1,31 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// Architectures are the OpenShift architectures clients can query graphs for. Clients not passing the arch
+// parameter get amd64 graphs, and single-arch clusters query multi when migrating to a heterogeneous (multi-arch)
+// payload.
+var Architectures = []string{"amd64", "arm64", "ppc64le", "s390x", "multi"}
+
+func validateArch(arch string) error {
+	if !slices.Contains(Architectures, arch) {
+		return fmt.Errorf("unknown architecture %q (one of %s)", arch, strings.Join(Architectures, ", "))
+	}
+	return nil
+}
+
+// generateArchImageSHA256 creates a deterministic SHA256 hash for the payload image of a version built for arch;
+// amd64 payloads (and payloads queried without arch) have the digests of generateImageSHA256
+func generateArchImageSHA256(version semver.Version, arch string) string {
+	index := slices.Index(Architectures, arch)
+	if index <= 0 {
+		return generateImageSHA256(version)
+	}
+	return fmt.Sprintf("%02x%062x", index, version.Major*1000000+version.Minor*1000+version.Patch)
+}
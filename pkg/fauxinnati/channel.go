@@ -0,0 +1,197 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// GraphGenerator generates the graph served for a channel to a client querying with the given version and arch
+type GraphGenerator func(queriedVersion semver.Version, arch string, channel string) Graph
+
+// Channel is a channel served by fauxinnati
+type Channel struct {
+	Name        string
+	Description string
+	// ContainsQueriedVersion reports whether the generated graphs contain the queried version. Such channels are
+	// listed in the io.openshift.upgrades.graph.release.channels metadata of the queried version node.
+	ContainsQueriedVersion bool
+	Generate               GraphGenerator
+	// Remote marks channels whose graphs are built from upstream data (candidate releases, registry digests or an
+	// upstream Cincinnati). The landing page shows no example graphs of remote channels, so that rendering it never
+	// makes upstream requests.
+	Remote bool
+	// Fault makes graph requests for the channel fail, unless a request selects its own fault
+	Fault *FaultProfile
+
+	// scenario is the scenario the channel was created from, if any
+	scenario *Scenario
+	// states are the graph states of the scenario timeline, if it has one
+	states []*Scenario
+}
+
+// AIDEV-NOTE: ChannelRegistry is the single source of truth for routing, the channels metadata and the landing page
+// Servers never modify a published registry; they build a new one and swap it (see channelSet)
+
+// ChannelRegistry is a set of channels addressable by name
+type ChannelRegistry struct {
+	channels map[string]Channel
+}
+
+// NewChannelRegistry creates an empty registry
+func NewChannelRegistry() *ChannelRegistry {
+	return &ChannelRegistry{channels: map[string]Channel{}}
+}
+
+// Register adds a channel to the registry. Channel names must be unique.
+func (r *ChannelRegistry) Register(channel Channel) error {
+	if err := channel.validate(); err != nil {
+		return err
+	}
+	if _, ok := r.channels[channel.Name]; ok {
+		return fmt.Errorf("channel %s is already registered", channel.Name)
+	}
+	r.channels[channel.Name] = channel
+	return nil
+}
+
+// set adds the channel to the registry, replacing any channel with the same name
+func (r *ChannelRegistry) set(channel Channel) {
+	r.channels[channel.Name] = channel
+}
+
+// Lookup returns the channel with the given name
+func (r *ChannelRegistry) Lookup(name string) (Channel, bool) {
+	channel, ok := r.channels[name]
+	return channel, ok
+}
+
+// Channels returns all registered channels sorted by name
+func (r *ChannelRegistry) Channels() []Channel {
+	channels := make([]Channel, 0, len(r.channels))
+	for _, name := range slices.Sorted(maps.Keys(r.channels)) {
+		channels = append(channels, r.channels[name])
+	}
+	return channels
+}
+
+// ChannelsContainingVersion returns the sorted names of channels whose graphs contain the queried version
+func (r *ChannelRegistry) ChannelsContainingVersion() []string {
+	var names []string
+	for _, channel := range r.Channels() {
+		if channel.ContainsQueriedVersion {
+			names = append(names, channel.Name)
+		}
+	}
+	return names
+}
+
+func (c Channel) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("channel has no name")
+	}
+	if strings.TrimSpace(c.Name) != c.Name {
+		return fmt.Errorf("channel name %q has leading or trailing whitespace", c.Name)
+	}
+	if c.Generate == nil {
+		return fmt.Errorf("channel %s has no graph generator", c.Name)
+	}
+	if c.Fault != nil {
+		if err := c.Fault.Validate(); err != nil {
+			return fmt.Errorf("channel %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// RegisterChannel serves an additional channel implemented in Go. Channels must be registered before the
+// server starts serving requests. Registered channels replace built-in scenarios with the same name, but
+// scenarios loaded from a scenarios directory replace registered channels.
+func (s *Server) RegisterChannel(channel Channel) error {
+	if err := channel.validate(); err != nil {
+		return err
+	}
+
+	s.channelsLock.Lock()
+	defer s.channelsLock.Unlock()
+	if slices.ContainsFunc(s.registeredChannels, func(c Channel) bool { return c.Name == channel.Name }) {
+		return fmt.Errorf("channel %s is already registered", channel.Name)
+	}
+	s.registeredChannels = append(s.registeredChannels, channel)
+	s.publishChannels()
+	return nil
+}
+
+// buildChannelRegistry composes the served channels: built-in scenarios, channels registered in Go, scenarios
+// loaded from a directory and static graphs set over the admin API, later ones replacing earlier ones with the
+// same name
+func (s *Server) buildChannelRegistry(loaded map[string]*Scenario) *ChannelRegistry {
+	registry := NewChannelRegistry()
+	for _, scenario := range defaultScenarios() {
+		registry.set(s.scenarioChannel(scenario))
+	}
+	for _, channel := range s.registeredChannels {
+		registry.set(channel)
+	}
+	for _, scenario := range loaded {
+		registry.set(s.scenarioChannel(scenario))
+	}
+	for name, graph := range s.staticGraphs {
+		registry.set(s.staticChannel(name, graph))
+	}
+	return registry
+}
+
+func (s *Server) scenarioChannel(scenario *Scenario) Channel {
+	channel := Channel{
+		Name:                   scenario.Name,
+		Description:            scenario.Description,
+		ContainsQueriedVersion: scenario.ContainsQueriedVersion(),
+		Generate: func(queriedVersion semver.Version, arch string, channel string) Graph {
+			return s.generateScenarioGraph(scenario, queriedVersion, arch, channel)
+		},
+		Remote:   slices.ContainsFunc(scenario.Nodes, func(node ScenarioNode) bool { return node.Resolve }),
+		Fault:    scenario.Fault,
+		scenario: scenario,
+	}
+	if len(scenario.Timeline) > 0 {
+		channel.states = s.timelineStates(scenario)
+		// Timelines outside of sessions start when the scenario is first loaded
+		s.timeline(timelineKey{channel: channel.Name})
+		timelineChannel := channel
+		channel.Generate = func(queriedVersion semver.Version, arch string, requested string) Graph {
+			return s.generateTimelineGraph(timelineChannel, "", queriedVersion, arch, requested)
+		}
+	}
+	return channel
+}
+
+func (s *Server) channelRegistry() *ChannelRegistry {
+	return s.channelSet.Load().registry
+}
+
+func (s *Server) builtinChannels() []Channel {
+	return []Channel{
+		{
+			Name:                   "OCP-88175",
+			Description:            "Five-node graph from the client's version to the latest four candidates of its minor, with Always conditional risks on the three newest.",
+			ContainsQueriedVersion: true,
+			Generate: func(queriedVersion semver.Version, arch string, channel string) Graph {
+				return s.generateOCP88175Graph(queriedVersion, arch, channel, false)
+			},
+			Remote: true,
+		},
+		{
+			Name:                   "OCP-88175-PromQL",
+			Description:            "Same as OCP-88175 but the conditional risks use a matching PromQL rule (PromQL: vector(1)).",
+			ContainsQueriedVersion: true,
+			Generate: func(queriedVersion semver.Version, arch string, channel string) Graph {
+				return s.generateOCP88175Graph(queriedVersion, arch, channel, true)
+			},
+			Remote: true,
+		},
+	}
+}
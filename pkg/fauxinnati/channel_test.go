@@ -0,0 +1,157 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func emptyGenerator(semver.Version, string, string) Graph {
+	return Graph{Nodes: []Node{}, Edges: []Edge{}, ConditionalEdges: []ConditionalEdge{}}
+}
+
+func TestChannelRegistry_Register(t *testing.T) {
+	tests := []struct {
+		name        string
+		channels    []Channel
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name: "channels are listed sorted by name",
+			channels: []Channel{
+				{Name: "zeta", Generate: emptyGenerator},
+				{Name: "alpha", Generate: emptyGenerator},
+			},
+			expected: []string{"alpha", "zeta"},
+		},
+		{
+			name: "duplicate channel is rejected",
+			channels: []Channel{
+				{Name: "alpha", Generate: emptyGenerator},
+				{Name: "alpha", Generate: emptyGenerator},
+			},
+			expected:    []string{"alpha"},
+			expectedErr: errors.New("channel alpha is already registered"),
+		},
+		{
+			name:        "channel without a generator is rejected",
+			channels:    []Channel{{Name: "alpha"}},
+			expectedErr: errors.New("channel alpha has no graph generator"),
+		},
+		{
+			name:        "channel without a name is rejected",
+			channels:    []Channel{{Generate: emptyGenerator}},
+			expectedErr: errors.New("channel has no name"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewChannelRegistry()
+			var err error
+			for _, channel := range tt.channels {
+				if err = registry.Register(channel); err != nil {
+					break
+				}
+			}
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+			var names []string
+			for _, channel := range registry.Channels() {
+				names = append(names, channel.Name)
+			}
+			if diff := cmp.Diff(tt.expected, names); diff != "" {
+				t.Errorf("channels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_channelRegistry(t *testing.T) {
+	server := NewServer()
+
+	var names []string
+	for _, channel := range server.channelRegistry().Channels() {
+		names = append(names, channel.Name)
+	}
+	expected := []string{
+		"OCP-88175",
+		"OCP-88175-PromQL",
+		"OTA-1813",
+		"channel-head",
+		"heterogeneous-migration",
+		"risks-always",
+		"risks-cannot-evaluate",
+		"risks-matching",
+		"risks-nonmatching",
+		"simple",
+		"smoke-test",
+		"version-not-found",
+	}
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Errorf("registered channels mismatch (-want +got):\n%s", diff)
+	}
+
+	containing := []string{
+		"OCP-88175",
+		"OCP-88175-PromQL",
+		"OTA-1813",
+		"channel-head",
+		"heterogeneous-migration",
+		"risks-always",
+		"risks-cannot-evaluate",
+		"risks-matching",
+		"risks-nonmatching",
+		"simple",
+		"smoke-test",
+	}
+	if diff := cmp.Diff(containing, server.channelRegistry().ChannelsContainingVersion()); diff != "" {
+		t.Errorf("channels containing the queried version mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_RegisterChannel(t *testing.T) {
+	server := NewServer()
+	err := server.RegisterChannel(Channel{
+		Name:                   "third-party",
+		Description:            "Single node graph registered from Go",
+		ContainsQueriedVersion: true,
+		Generate: func(queriedVersion semver.Version, arch string, channel string) Graph {
+			return Graph{
+				Nodes:            []Node{NewNode(queriedVersion, channel)},
+				Edges:            []Edge{},
+				ConditionalEdges: []ConditionalEdge{},
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to register channel: %v", err)
+	}
+	if err := server.RegisterChannel(Channel{Name: "third-party", Generate: emptyGenerator}); err == nil {
+		t.Errorf("expected registering a channel twice to fail")
+	}
+
+	w := httptest.NewRecorder()
+	server.handleGraph(w, httptest.NewRequest("GET", "/api/upgrades_info/graph?channel=third-party&version=4.17.5", nil))
+	var graph Graph
+	if err := json.Unmarshal(w.Body.Bytes(), &graph); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v", err)
+	}
+	if len(graph.Nodes) != 1 || graph.Nodes[0].Version.String() != "4.17.5" {
+		t.Errorf("expected a single 4.17.5 node, got %v", graph.Nodes)
+	}
+
+	simple := generateChannelGraph(t, server, "simple", semver.MustParse("4.17.5"), "amd64")
+	if channels := findVersion(simple, "4.17.5").Metadata["io.openshift.upgrades.graph.release.channels"]; !strings.Contains(channels, "third-party") {
+		t.Errorf("expected the queried version to be listed in the third-party channel, got %q", channels)
+	}
+}
@@ -0,0 +1,308 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// EdgeChangeKind tells how an edge changed between two graphs
+type EdgeChangeKind string
+
+const (
+	// EdgeAdded is an edge only in the new graph
+	EdgeAdded EdgeChangeKind = "added"
+	// EdgeRemoved is an edge only in the old graph
+	EdgeRemoved EdgeChangeKind = "removed"
+	// EdgeMadeConditional is an unconditional edge that became conditional
+	EdgeMadeConditional EdgeChangeKind = "madeConditional"
+	// EdgeMadeUnconditional is a conditional edge that became unconditional
+	EdgeMadeUnconditional EdgeChangeKind = "madeUnconditional"
+	// EdgeRisksChanged is a conditional edge with different risks
+	EdgeRisksChanged EdgeChangeKind = "risksChanged"
+)
+
+// RiskChangeKind tells how a risk of an edge changed between two graphs
+type RiskChangeKind string
+
+const (
+	// RiskAdded is a risk only on the edge of the new graph
+	RiskAdded RiskChangeKind = "added"
+	// RiskRemoved is a risk only on the edge of the old graph
+	RiskRemoved RiskChangeKind = "removed"
+	// RiskChanged is a risk with the same name on both edges but different fields
+	RiskChanged RiskChangeKind = "changed"
+)
+
+// NodeChange is a changed field of a node present in both graphs
+type NodeChange struct {
+	Version string `json:"version"`
+	// Field is payload or the metadata key, e.g. io.openshift.upgrades.graph.release.channels
+	Field string `json:"field"`
+	// Old and New are empty when the metadata key is not set
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// RiskChange is a changed risk of an edge
+type RiskChange struct {
+	Name string         `json:"name"`
+	Kind RiskChangeKind `json:"kind"`
+	// Fields are the changed fields (url, message, matchingRules) of a changed risk
+	Fields []string               `json:"fields,omitempty"`
+	Old    *ConditionalUpdateRisk `json:"old,omitempty"`
+	New    *ConditionalUpdateRisk `json:"new,omitempty"`
+}
+
+// EdgeChange is a changed edge, addressed by versions
+type EdgeChange struct {
+	From string         `json:"from"`
+	To   string         `json:"to"`
+	Kind EdgeChangeKind `json:"kind"`
+	// Conditional tells whether an added or removed edge is conditional
+	Conditional bool `json:"conditional,omitempty"`
+	// Risks are the risks added to or removed from the edge
+	Risks []RiskChange `json:"risks,omitempty"`
+}
+
+// GraphDiff is the difference between two graphs, compared by version rather than by node index
+type GraphDiff struct {
+	AddedNodes   []string     `json:"addedNodes,omitempty"`
+	RemovedNodes []string     `json:"removedNodes,omitempty"`
+	ChangedNodes []NodeChange `json:"changedNodes,omitempty"`
+	Edges        []EdgeChange `json:"edges,omitempty"`
+}
+
+// Empty tells whether the graphs are the same
+func (d GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.ChangedNodes) == 0 && len(d.Edges) == 0
+}
+
+// AIDEV-NOTE: DiffGraphs compares what clients see, not the graph JSON
+// Node order, edge indices and how conditional edges are grouped do not matter: edges are keyed by versions and risks
+// of an edge are collected from all its groups. An edge that is both unconditional and conditional is unconditional,
+// as for clients.
+
+// DiffGraphs returns the changes from the before to the after graph, sorted by version
+func DiffGraphs(before, after Graph) GraphDiff {
+	oldNodes, newNodes := diffNodes(before), diffNodes(after)
+	versions := map[string]semver.Version{}
+	for _, nodes := range []map[string]Node{oldNodes, newNodes} {
+		for version, node := range nodes {
+			versions[version] = node.Version
+		}
+	}
+	// Semantic version order ignores build metadata, and conditional edges may reference versions without nodes, so
+	// ties are broken by the version string to keep the order stable
+	compareVersions := func(a, b string) int {
+		if c := diffVersion(versions, a).Compare(diffVersion(versions, b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	}
+
+	var diff GraphDiff
+	for _, version := range slices.SortedFunc(maps.Keys(versions), compareVersions) {
+		oldNode, inOld := oldNodes[version]
+		newNode, inNew := newNodes[version]
+		switch {
+		case !inOld:
+			diff.AddedNodes = append(diff.AddedNodes, version)
+		case !inNew:
+			diff.RemovedNodes = append(diff.RemovedNodes, version)
+		default:
+			if oldNode.Image != newNode.Image {
+				diff.ChangedNodes = append(diff.ChangedNodes, NodeChange{Version: version, Field: "payload", Old: oldNode.Image, New: newNode.Image})
+			}
+			keys := slices.Collect(maps.Keys(oldNode.Metadata))
+			for key := range newNode.Metadata {
+				if _, ok := oldNode.Metadata[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				if oldNode.Metadata[key] != newNode.Metadata[key] {
+					diff.ChangedNodes = append(diff.ChangedNodes, NodeChange{Version: version, Field: key, Old: oldNode.Metadata[key], New: newNode.Metadata[key]})
+				}
+			}
+		}
+	}
+
+	oldEdges, newEdges := diffEdges(before), diffEdges(after)
+	pairs := slices.Collect(maps.Keys(oldEdges))
+	for pair := range newEdges {
+		if _, ok := oldEdges[pair]; !ok {
+			pairs = append(pairs, pair)
+		}
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		if c := compareVersions(a[0], b[0]); c != 0 {
+			return c
+		}
+		return compareVersions(a[1], b[1])
+	})
+	for _, pair := range pairs {
+		oldRisks, inOld := oldEdges[pair]
+		newRisks, inNew := newEdges[pair]
+		change := EdgeChange{From: pair[0], To: pair[1]}
+		switch {
+		case !inOld:
+			change.Kind, change.Conditional = EdgeAdded, newRisks != nil
+		case !inNew:
+			change.Kind, change.Conditional = EdgeRemoved, oldRisks != nil
+		case oldRisks == nil && newRisks != nil:
+			change.Kind = EdgeMadeConditional
+		case oldRisks != nil && newRisks == nil:
+			change.Kind = EdgeMadeUnconditional
+		default:
+			change.Kind = EdgeRisksChanged
+		}
+		change.Risks = diffRisks(oldRisks, newRisks)
+		if change.Kind == EdgeRisksChanged && len(change.Risks) == 0 {
+			continue
+		}
+		diff.Edges = append(diff.Edges, change)
+	}
+	return diff
+}
+
+// diffVersion returns the version of the node, or the parsed version string when no graph has a node for it
+func diffVersion(versions map[string]semver.Version, version string) semver.Version {
+	if parsed, ok := versions[version]; ok {
+		return parsed
+	}
+	parsed, _ := semver.Parse(version)
+	return parsed
+}
+
+// diffNodes indexes the nodes of the graph by version, the first node wins for duplicate versions
+func diffNodes(graph Graph) map[string]Node {
+	nodes := map[string]Node{}
+	for _, node := range graph.Nodes {
+		if _, ok := nodes[node.Version.String()]; !ok {
+			nodes[node.Version.String()] = node
+		}
+	}
+	return nodes
+}
+
+// diffEdges indexes the edges of the graph by versions. Unconditional edges have nil risks, conditional edges their
+// risks by name; edges pointing to nonexistent nodes are skipped.
+func diffEdges(graph Graph) map[[2]string]map[string]ConditionalUpdateRisk {
+	edges := map[[2]string]map[string]ConditionalUpdateRisk{}
+	for _, edge := range graph.Edges {
+		if edge[0] < 0 || edge[0] >= len(graph.Nodes) || edge[1] < 0 || edge[1] >= len(graph.Nodes) {
+			continue
+		}
+		edges[[2]string{graph.Nodes[edge[0]].Version.String(), graph.Nodes[edge[1]].Version.String()}] = nil
+	}
+	for _, conditionalEdge := range graph.ConditionalEdges {
+		for _, edge := range conditionalEdge.Edges {
+			pair := [2]string{edge.From, edge.To}
+			risks, ok := edges[pair]
+			if ok && risks == nil {
+				continue
+			}
+			if !ok {
+				risks = map[string]ConditionalUpdateRisk{}
+				edges[pair] = risks
+			}
+			for _, risk := range conditionalEdge.Risks {
+				if _, ok := risks[risk.Name]; !ok {
+					risks[risk.Name] = risk
+				}
+			}
+		}
+	}
+	return edges
+}
+
+func diffRisks(before, after map[string]ConditionalUpdateRisk) []RiskChange {
+	names := slices.Collect(maps.Keys(before))
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var changes []RiskChange
+	for _, name := range names {
+		oldRisk, inOld := before[name]
+		newRisk, inNew := after[name]
+		switch {
+		case !inOld:
+			changes = append(changes, RiskChange{Name: name, Kind: RiskAdded, New: &newRisk})
+		case !inNew:
+			changes = append(changes, RiskChange{Name: name, Kind: RiskRemoved, Old: &oldRisk})
+		default:
+			var fields []string
+			if oldRisk.URL != newRisk.URL {
+				fields = append(fields, "url")
+			}
+			if oldRisk.Message != newRisk.Message {
+				fields = append(fields, "message")
+			}
+			if !reflect.DeepEqual(oldRisk.MatchingRules, newRisk.MatchingRules) {
+				fields = append(fields, "matchingRules")
+			}
+			if len(fields) > 0 {
+				changes = append(changes, RiskChange{Name: name, Kind: RiskChanged, Fields: fields, Old: &oldRisk, New: &newRisk})
+			}
+		}
+	}
+	return changes
+}
+
+// WriteText writes the diff in a human-readable form, one change per line: + for added, - for removed and ~ for
+// changed nodes and edges
+func (d GraphDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, version := range d.AddedNodes {
+		fmt.Fprintf(&b, "+ node %s\n", version)
+	}
+	for _, version := range d.RemovedNodes {
+		fmt.Fprintf(&b, "- node %s\n", version)
+	}
+	for _, change := range d.ChangedNodes {
+		fmt.Fprintf(&b, "~ node %s: %s %q -> %q\n", change.Version, change.Field, change.Old, change.New)
+	}
+	for _, change := range d.Edges {
+		edge := fmt.Sprintf("edge %s -> %s", change.From, change.To)
+		switch change.Kind {
+		case EdgeAdded, EdgeRemoved:
+			sign := "+"
+			if change.Kind == EdgeRemoved {
+				sign = "-"
+			}
+			if change.Conditional {
+				edge = "conditional " + edge
+			}
+			fmt.Fprintf(&b, "%s %s\n", sign, edge)
+		case EdgeMadeConditional:
+			fmt.Fprintf(&b, "~ %s became conditional\n", edge)
+		case EdgeMadeUnconditional:
+			fmt.Fprintf(&b, "~ %s became unconditional\n", edge)
+		default:
+			fmt.Fprintf(&b, "~ %s changed risks\n", edge)
+		}
+		for _, risk := range change.Risks {
+			switch risk.Kind {
+			case RiskAdded:
+				fmt.Fprintf(&b, "    + risk %s\n", risk.Name)
+			case RiskRemoved:
+				fmt.Fprintf(&b, "    - risk %s\n", risk.Name)
+			default:
+				fmt.Fprintf(&b, "    ~ risk %s: %s\n", risk.Name, strings.Join(risk.Fields, ", "))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,194 @@
+package fauxinnati
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	riskA := ConditionalUpdateRisk{Name: "RiskA", URL: "https://example.com/a", Message: "A", MatchingRules: []MatchingRule{{Type: "Always"}}}
+	riskB := ConditionalUpdateRisk{Name: "RiskB", URL: "https://example.com/b", Message: "B", MatchingRules: []MatchingRule{{Type: "Always"}}}
+	riskC := ConditionalUpdateRisk{Name: "RiskC", URL: "https://example.com/c", Message: "C", MatchingRules: []MatchingRule{{Type: "Always"}}}
+	promQLRiskA := riskA
+	promQLRiskA.Message = "A, but only on AWS"
+	promQLRiskA.MatchingRules = []MatchingRule{{Type: "PromQL", PromQL: &PromQLQuery{PromQL: `cluster_infrastructure_provider{type="AWS"}`}}}
+
+	testCases := []struct {
+		name     string
+		mutate   func(*Graph)
+		expected GraphDiff
+	}{
+		{
+			name:   "same graph",
+			mutate: func(*Graph) {},
+		},
+		{
+			name: "reordered nodes and regrouped conditional edges are the same graph",
+			mutate: func(g *Graph) {
+				g.Nodes = []Node{g.Nodes[3], g.Nodes[2], g.Nodes[1], g.Nodes[0]}
+				g.Edges = []Edge{{2, 1}, {3, 2}, {3, 1}}
+				g.ConditionalEdges = []ConditionalEdge{
+					{Edges: []ConditionalUpdate{{From: "4.17.1", To: "4.17.3"}}, Risks: []ConditionalUpdateRisk{riskB, riskA}},
+					{Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.3"}}, Risks: []ConditionalUpdateRisk{riskA}},
+					{Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.3"}}, Risks: []ConditionalUpdateRisk{riskB}},
+				}
+			},
+		},
+		{
+			name: "added and removed nodes with their edges",
+			mutate: func(g *Graph) {
+				g.Nodes[1] = NewNode(semver.MustParse("4.17.4"), "stable-4.17")
+				g.ConditionalEdges[0].Edges = append(g.ConditionalEdges[0].Edges[:1], ConditionalUpdate{From: "4.17.3", To: "4.17.4"})
+			},
+			expected: GraphDiff{
+				AddedNodes:   []string{"4.17.4"},
+				RemovedNodes: []string{"4.17.1"},
+				Edges: []EdgeChange{
+					{From: "4.17.0", To: "4.17.1", Kind: EdgeRemoved},
+					{From: "4.17.0", To: "4.17.4", Kind: EdgeAdded},
+					{From: "4.17.1", To: "4.17.2", Kind: EdgeRemoved},
+					{From: "4.17.1", To: "4.17.3", Kind: EdgeRemoved, Conditional: true, Risks: []RiskChange{
+						{Name: "RiskA", Kind: RiskRemoved, Old: &riskA},
+						{Name: "RiskB", Kind: RiskRemoved, Old: &riskB},
+					}},
+					{From: "4.17.3", To: "4.17.4", Kind: EdgeAdded, Conditional: true, Risks: []RiskChange{
+						{Name: "RiskA", Kind: RiskAdded, New: &riskA},
+						{Name: "RiskB", Kind: RiskAdded, New: &riskB},
+					}},
+					{From: "4.17.4", To: "4.17.2", Kind: EdgeAdded},
+				},
+			},
+		},
+		{
+			name: "versions equal but for build metadata are sorted by version string",
+			mutate: func(g *Graph) {
+				g.Nodes = append(g.Nodes, NewNode(semver.MustParse("4.17.4+b"), "stable-4.17"), NewNode(semver.MustParse("4.17.4+a"), "stable-4.17"))
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{
+					Edges: []ConditionalUpdate{{From: "4.17.3", To: "4.17.4+b"}, {From: "4.17.3", To: "4.17.4+a"}, {From: "4.17.3", To: "4.17.5"}},
+					Risks: []ConditionalUpdateRisk{riskC},
+				})
+			},
+			expected: GraphDiff{
+				AddedNodes: []string{"4.17.4+a", "4.17.4+b"},
+				Edges: []EdgeChange{
+					{From: "4.17.3", To: "4.17.4+a", Kind: EdgeAdded, Conditional: true, Risks: []RiskChange{{Name: "RiskC", Kind: RiskAdded, New: &riskC}}},
+					{From: "4.17.3", To: "4.17.4+b", Kind: EdgeAdded, Conditional: true, Risks: []RiskChange{{Name: "RiskC", Kind: RiskAdded, New: &riskC}}},
+					{From: "4.17.3", To: "4.17.5", Kind: EdgeAdded, Conditional: true, Risks: []RiskChange{{Name: "RiskC", Kind: RiskAdded, New: &riskC}}},
+				},
+			},
+		},
+		{
+			name: "changed payload and metadata",
+			mutate: func(g *Graph) {
+				g.Nodes[2].Image = "quay.io/openshift-release-dev/ocp-release@sha256:0000"
+				g.Nodes[2].Metadata["io.openshift.upgrades.graph.release.channels"] = "fast-4.17,stable-4.17"
+				delete(g.Nodes[2].Metadata, "url")
+				g.Nodes[3].Metadata["io.openshift.upgrades.graph.previous.remove_regex"] = ".*"
+			},
+			expected: GraphDiff{
+				ChangedNodes: []NodeChange{
+					{Version: "4.17.2", Field: "payload", Old: NewNode(semver.MustParse("4.17.2"), "").Image, New: "quay.io/openshift-release-dev/ocp-release@sha256:0000"},
+					{Version: "4.17.2", Field: "io.openshift.upgrades.graph.release.channels", Old: "stable-4.17", New: "fast-4.17,stable-4.17"},
+					{Version: "4.17.2", Field: "url", Old: generateErrataURL(semver.MustParse("4.17.2"))},
+					{Version: "4.17.3", Field: "io.openshift.upgrades.graph.previous.remove_regex", New: ".*"},
+				},
+			},
+		},
+		{
+			name: "edges moved between unconditional and conditional",
+			mutate: func(g *Graph) {
+				g.Edges = []Edge{{0, 1}, {1, 2}, {0, 3}}
+				g.ConditionalEdges[0].Edges = g.ConditionalEdges[0].Edges[1:]
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.2"}}, Risks: []ConditionalUpdateRisk{riskC}})
+			},
+			expected: GraphDiff{
+				Edges: []EdgeChange{
+					{From: "4.17.0", To: "4.17.2", Kind: EdgeMadeConditional, Risks: []RiskChange{{Name: "RiskC", Kind: RiskAdded, New: &riskC}}},
+					{From: "4.17.0", To: "4.17.3", Kind: EdgeMadeUnconditional, Risks: []RiskChange{
+						{Name: "RiskA", Kind: RiskRemoved, Old: &riskA},
+						{Name: "RiskB", Kind: RiskRemoved, Old: &riskB},
+					}},
+				},
+			},
+		},
+		{
+			name: "unconditional edge hides the risks of the same conditional edge",
+			mutate: func(g *Graph) {
+				g.Edges = append(g.Edges, Edge{1, 3})
+			},
+			expected: GraphDiff{
+				Edges: []EdgeChange{
+					{From: "4.17.1", To: "4.17.3", Kind: EdgeMadeUnconditional, Risks: []RiskChange{
+						{Name: "RiskA", Kind: RiskRemoved, Old: &riskA},
+						{Name: "RiskB", Kind: RiskRemoved, Old: &riskB},
+					}},
+				},
+			},
+		},
+		{
+			name: "changed risks of one edge",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges = []ConditionalEdge{
+					{Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.3"}}, Risks: []ConditionalUpdateRisk{riskA, riskB}},
+					{Edges: []ConditionalUpdate{{From: "4.17.1", To: "4.17.3"}}, Risks: []ConditionalUpdateRisk{promQLRiskA, riskC}},
+				}
+			},
+			expected: GraphDiff{
+				Edges: []EdgeChange{
+					{From: "4.17.1", To: "4.17.3", Kind: EdgeRisksChanged, Risks: []RiskChange{
+						{Name: "RiskA", Kind: RiskChanged, Fields: []string{"message", "matchingRules"}, Old: &riskA, New: &promQLRiskA},
+						{Name: "RiskB", Kind: RiskRemoved, Old: &riskB},
+						{Name: "RiskC", Kind: RiskAdded, New: &riskC},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			after := proxyTestGraph()
+			tc.mutate(&after)
+			got := DiffGraphs(proxyTestGraph(), after)
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("unexpected diff (-want +got):\n%s", diff)
+			}
+			if got.Empty() != (len(tc.expected.AddedNodes)+len(tc.expected.RemovedNodes)+len(tc.expected.ChangedNodes)+len(tc.expected.Edges) == 0) {
+				t.Errorf("unexpected Empty() = %t", got.Empty())
+			}
+		})
+	}
+}
+
+func TestGraphDiff_WriteText(t *testing.T) {
+	after := proxyTestGraph()
+	after.Nodes = append(after.Nodes, NewNode(semver.MustParse("4.17.4"), "stable-4.17"))
+	after.Nodes[1].Metadata["io.openshift.upgrades.graph.release.channels"] = "fast-4.17,stable-4.17"
+	after.Edges = []Edge{{0, 2}, {1, 2}, {2, 4}}
+	after.ConditionalEdges[0].Risks[0].URL = "https://example.com/a2"
+	after.ConditionalEdges = append(after.ConditionalEdges, ConditionalEdge{
+		Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.1"}},
+		Risks: []ConditionalUpdateRisk{{Name: "RiskC", URL: "https://example.com/c", Message: "C", MatchingRules: []MatchingRule{{Type: "Always"}}}},
+	})
+
+	var out strings.Builder
+	if err := DiffGraphs(proxyTestGraph(), after).WriteText(&out); err != nil {
+		t.Fatalf("failed to write diff: %v", err)
+	}
+	expected := `+ node 4.17.4
+~ node 4.17.1: io.openshift.upgrades.graph.release.channels "stable-4.17" -> "fast-4.17,stable-4.17"
+~ edge 4.17.0 -> 4.17.1 became conditional
+    + risk RiskC
+~ edge 4.17.0 -> 4.17.3 changed risks
+    ~ risk RiskA: url
+~ edge 4.17.1 -> 4.17.3 changed risks
+    ~ risk RiskA: url
++ edge 4.17.2 -> 4.17.4
+`
+	if diff := cmp.Diff(expected, out.String()); diff != "" {
+		t.Errorf("unexpected text (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,292 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPayloadRepository is the repository OpenShift release payloads are published to
+const DefaultPayloadRepository = "quay.io/openshift-release-dev/ocp-release"
+
+type registryDigestResolver struct {
+	repository string
+	token      string
+	plainHTTP  bool
+	cache      Cache
+	// tokens holds pull tokens apart from the digests, so that they are not counted by the digest cache metrics
+	// nor replaced by WithDigestCache
+	tokens Cache
+	logger logrus.FieldLogger
+}
+
+// NewRegistryDigestResolver resolves digests with HEAD requests to the manifests endpoint of the Docker Registry
+// v2 API serving repository (e.g. quay.io/openshift-release-dev/ocp-release or mirror.local:5000/ocp/release).
+// A non-empty token is sent as a bearer token, and plainHTTP makes the requests use http instead of https. Registries
+// that challenge the request for a token (e.g. anonymous pulls from quay.io) are answered with a pull token from the
+// challenge realm.
+func NewRegistryDigestResolver(repository, token string, plainHTTP bool) DigestResolver {
+	return &registryDigestResolver{
+		repository: repository,
+		token:      token,
+		plainHTTP:  plainHTTP,
+		cache:      cache.New(5*time.Minute, 10*time.Minute),
+		tokens:     cache.New(time.Minute, 10*time.Minute),
+		logger:     logrus.StandardLogger(),
+	}
+}
+
+func (r *registryDigestResolver) Repository() string {
+	return r.repository
+}
+
+func (r *registryDigestResolver) Digest(client Client, tag string) (string, error) {
+	key := fmt.Sprintf("%s-%s", "getDigest", tag)
+	if data, found := r.cache.Get(key); found {
+		r.logger.WithField("key", key).Debug("Found digest in cache")
+		return data.(string), nil
+	}
+
+	host, name, ok := strings.Cut(r.repository, "/")
+	if !ok {
+		return "", fmt.Errorf("repository %s has no registry host", r.repository)
+	}
+	scheme := "https"
+	if r.plainHTTP {
+		scheme = "http"
+	}
+	r.logger.WithField("key", key).WithField("registry", host).Debug("Getting digest from the registry ...")
+	url := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, name, tag)
+	res, err := r.head(client, url, r.token)
+	if err != nil {
+		return "", err
+	}
+	if challenge := res.Header.Get("WWW-Authenticate"); res.StatusCode == http.StatusUnauthorized && challenge != "" {
+		_ = res.Body.Close()
+		token, err := r.pullToken(client, challenge)
+		if err != nil {
+			return "", fmt.Errorf("unexpected status code %d for url %s: %w", http.StatusUnauthorized, url, err)
+		}
+		if res, err = r.head(client, url, token); err != nil {
+			return "", err
+		}
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d for url %s", res.StatusCode, url)
+	}
+
+	digest := res.Header.Get("docker-content-digest")
+	if digest == "" {
+		return "", fmt.Errorf("missing digest for url %s", url)
+	}
+	r.cache.Set(key, digest, cache.NoExpiration)
+	return digest, nil
+}
+
+// manifestMediaTypes are the manifest media types a payload tag may point to: single-arch payloads are image
+// manifests, multi-arch payloads are manifest lists or OCI image indexes
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+func (r *registryDigestResolver) head(client Client, url, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.Do(req)
+}
+
+// AIDEV-NOTE: Registry token challenge
+// Registries like quay.io and registry.ci.openshift.org answer anonymous manifest requests with 401 and a
+// `WWW-Authenticate: Bearer realm=...,service=...,scope=...` challenge; a pull token is then requested from the realm
+// and the manifest request retried with it. Tokens are cached per scope until they expire.
+func (r *registryDigestResolver) pullToken(client Client, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("no bearer token challenge")
+	}
+	parsed := parseChallengeParams(params)
+	realm := parsed["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("token challenge has no realm")
+	}
+	key := fmt.Sprintf("%s-%s-%s", realm, parsed["service"], parsed["scope"])
+	if data, found := r.tokens.Get(key); found {
+		return data.(string), nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, realm, nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	for _, param := range []string{"service", "scope"} {
+		if value := parsed[param]; value != "" {
+			query.Set(param, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+	r.logger.WithField("realm", realm).WithField("scope", parsed["scope"]).Debug("Getting registry token ...")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d for token url %s", res.StatusCode, req.URL)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token from %s: %w", req.URL, err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("no token in the response from %s", req.URL)
+	}
+	// Tokens are valid for 60 seconds unless the response says otherwise
+	expiresIn := 60 * time.Second
+	if body.ExpiresIn > 0 {
+		expiresIn = time.Duration(body.ExpiresIn) * time.Second
+	}
+	r.tokens.Set(key, token, expiresIn*9/10)
+	return token, nil
+}
+
+// parseChallengeParams parses the comma-separated key="value" parameters of a WWW-Authenticate challenge; quoted
+// values may contain commas (e.g. scope="repository:ocp/release:pull,push")
+func parseChallengeParams(params string) map[string]string {
+	parsed := map[string]string{}
+	for params != "" {
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		parsed[key] = value
+		params = strings.TrimLeft(rest, " ")
+	}
+	return parsed
+}
+
+type staticDigestResolver struct {
+	repository string
+	digests    map[string]string
+}
+
+// NewStaticDigestResolver resolves digests from a mapping of tags to digests
+func NewStaticDigestResolver(repository string, digests map[string]string) DigestResolver {
+	return &staticDigestResolver{repository: repository, digests: digests}
+}
+
+// LoadStaticDigestResolver resolves digests from a YAML (or JSON) file mapping tags to digests:
+//
+//	4.18.5-x86_64: sha256:0123...
+func LoadStaticDigestResolver(repository, path string) (DigestResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var digests map[string]string
+	if err := yaml.Unmarshal(data, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for tag, digest := range digests {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("%s: digest of %s is not a sha256 digest: %q", path, tag, digest)
+		}
+	}
+	return NewStaticDigestResolver(repository, digests), nil
+}
+
+func (r *staticDigestResolver) Repository() string {
+	return r.repository
+}
+
+func (r *staticDigestResolver) Digest(_ Client, tag string) (string, error) {
+	digest, ok := r.digests[tag]
+	if !ok {
+		return "", fmt.Errorf("no digest for tag %s", tag)
+	}
+	return digest, nil
+}
+
+// ociIndex is the subset of an OCI image-layout index.json needed to find tagged manifests
+type ociIndex struct {
+	Manifests []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"manifests"`
+}
+
+const ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// NewOCILayoutDigestResolver resolves digests from the index of an OCI image-layout directory (as written e.g. by
+// `oc mirror` or `skopeo copy ... oci:dir:tag`), where payloads are tagged with the org.opencontainers.image.ref.name
+// annotation. The annotation may be the tag itself or a full reference ending with :tag.
+func NewOCILayoutDigestResolver(repository, dir string) (DigestResolver, error) {
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err != nil {
+		return nil, fmt.Errorf("%s is not an OCI image layout: %w", dir, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the index of %s: %w", dir, err)
+	}
+	digests := map[string]string{}
+	for _, manifest := range index.Manifests {
+		ref, ok := manifest.Annotations[ociRefNameAnnotation]
+		if !ok {
+			continue
+		}
+		if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+			ref = ref[i+1:]
+		}
+		digests[ref] = manifest.Digest
+	}
+	return NewStaticDigestResolver(repository, digests), nil
+}
@@ -0,0 +1,269 @@
+package fauxinnati
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestRegistryDigestResolver(t *testing.T) {
+	var requests []string
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/v2/ocp/release/manifests/4.18.5-x86_64" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:0123")
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	tests := []struct {
+		name           string
+		token          string
+		tag            string
+		expectedDigest string
+		expectedErr    error
+	}{
+		{
+			name:           "resolves a tag with a bearer token",
+			token:          "secret",
+			tag:            "4.18.5-x86_64",
+			expectedDigest: "sha256:0123",
+		},
+		{
+			name:        "missing tag",
+			token:       "secret",
+			tag:         "4.18.6-x86_64",
+			expectedErr: errors.New("unexpected status code 404 for url " + registry.URL + "/v2/ocp/release/manifests/4.18.6-x86_64"),
+		},
+		{
+			name:        "unauthorized",
+			tag:         "4.18.5-x86_64",
+			expectedErr: errors.New("unexpected status code 401 for url " + registry.URL + "/v2/ocp/release/manifests/4.18.5-x86_64"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewRegistryDigestResolver(host+"/ocp/release", tt.token, true)
+			digest, err := resolver.Digest(http.DefaultClient, tt.tag)
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+			if digest != tt.expectedDigest {
+				t.Errorf("expected digest %q, got %q", tt.expectedDigest, digest)
+			}
+		})
+	}
+
+	requests = nil
+	resolver := NewRegistryDigestResolver(host+"/ocp/release", "secret", true)
+	for range 2 {
+		if _, err := resolver.Digest(http.DefaultClient, "4.18.5-x86_64"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if diff := cmp.Diff([]string{"HEAD /v2/ocp/release/manifests/4.18.5-x86_64"}, requests); diff != "" {
+		t.Errorf("expected resolved digests to be cached (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegistryDigestResolver_TokenChallenge(t *testing.T) {
+	var requests []string
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch {
+		case r.URL.Path == "/token" && r.URL.Query().Get("service") == "registry.example.com":
+			_, _ = w.Write([]byte(`{"token": "pull", "expires_in": 300}`))
+		case r.URL.Path == "/token":
+			w.WriteHeader(http.StatusForbidden)
+		case r.Header.Get("Authorization") != "Bearer pull":
+			service := "registry.example.com"
+			if strings.Contains(r.URL.Path, "/private/") {
+				service = "private.example.com"
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="`+service+`",scope="repository:ocp/release:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case !strings.Contains(r.Header.Get("Accept"), "application/vnd.docker.distribution.manifest.list.v2+json") ||
+			!strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json"):
+			w.WriteHeader(http.StatusNotAcceptable)
+		default:
+			w.Header().Set("Docker-Content-Digest", "sha256:0123")
+		}
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	resolver := NewRegistryDigestResolver(host+"/ocp/release", "", true)
+	for _, tag := range []string{"4.18.5-multi", "4.18.6-multi"} {
+		digest, err := resolver.Digest(http.DefaultClient, tag)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if digest != "sha256:0123" {
+			t.Errorf("expected digest sha256:0123, got %q", digest)
+		}
+	}
+	expected := []string{
+		"HEAD /v2/ocp/release/manifests/4.18.5-multi",
+		"GET /token?scope=repository%3Aocp%2Frelease%3Apull&service=registry.example.com",
+		"HEAD /v2/ocp/release/manifests/4.18.5-multi",
+		"HEAD /v2/ocp/release/manifests/4.18.6-multi",
+		"HEAD /v2/ocp/release/manifests/4.18.6-multi",
+	}
+	if diff := cmp.Diff(expected, requests); diff != "" {
+		t.Errorf("expected the token to be requested once and reused (-want +got):\n%s", diff)
+	}
+	registryResolver := resolver.(*registryDigestResolver)
+	if diff := cmp.Diff([]string{"getDigest-4.18.5-multi", "getDigest-4.18.6-multi"}, slices.Sorted(maps.Keys(registryResolver.cache.(*cache.Cache).Items()))); diff != "" {
+		t.Errorf("expected only digests in the digest cache (-want +got):\n%s", diff)
+	}
+	tokenKey := registry.URL + "/token-registry.example.com-repository:ocp/release:pull"
+	if item, found := registryResolver.tokens.(*cache.Cache).Items()[tokenKey]; !found || item.Object != "pull" {
+		t.Errorf("expected the pull token to be cached under %q, got %v", tokenKey, registryResolver.tokens.(*cache.Cache).Items())
+	} else if expiresIn := time.Until(time.Unix(0, item.Expiration)); expiresIn <= 4*time.Minute || expiresIn > 270*time.Second {
+		t.Errorf("expected the pull token to expire with the response expires_in, expires in %s", expiresIn)
+	}
+
+	_, err := NewRegistryDigestResolver(host+"/private/release", "", true).Digest(http.DefaultClient, "4.18.5-multi")
+	expectedErr := errors.New("unexpected status code 401 for url " + registry.URL + "/v2/private/release/manifests/4.18.5-multi: " +
+		"unexpected status code 403 for token url " + registry.URL + "/token?scope=repository%3Aocp%2Frelease%3Apull&service=private.example.com")
+	if diff := cmp.Diff(expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("error mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseChallengeParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		expected map[string]string
+	}{
+		{
+			name:     "quoted values",
+			params:   `realm="https://quay.io/v2/auth",service="quay.io",scope="repository:openshift-release-dev/ocp-release:pull"`,
+			expected: map[string]string{"realm": "https://quay.io/v2/auth", "service": "quay.io", "scope": "repository:openshift-release-dev/ocp-release:pull"},
+		},
+		{
+			name:     "commas in quoted values, spaces and unquoted values",
+			params:   `Realm="https://auth.example.com/token", scope="repository:ocp/release:pull,push", service=registry`,
+			expected: map[string]string{"realm": "https://auth.example.com/token", "scope": "repository:ocp/release:pull,push", "service": "registry"},
+		},
+		{
+			name:     "empty",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.expected, parseChallengeParams(tt.params)); diff != "" {
+				t.Errorf("unexpected params (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadStaticDigestResolver(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "digests.yaml")
+	if err := os.WriteFile(valid, []byte("4.18.5-x86_64: sha256:0123\n"), 0644); err != nil {
+		t.Fatalf("failed to write digests: %v", err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("4.18.5-x86_64: latest\n"), 0644); err != nil {
+		t.Fatalf("failed to write digests: %v", err)
+	}
+
+	resolver, err := LoadStaticDigestResolver("mirror.local/ocp/release", valid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if digest, err := resolver.Digest(nil, "4.18.5-x86_64"); err != nil || digest != "sha256:0123" {
+		t.Errorf("expected sha256:0123, got %q (%v)", digest, err)
+	}
+	if _, err := resolver.Digest(nil, "4.18.6-x86_64"); err == nil {
+		t.Errorf("expected an unknown tag to fail")
+	}
+
+	_, err = LoadStaticDigestResolver("mirror.local/ocp/release", invalid)
+	expectedErr := errors.New(invalid + `: digest of 4.18.5-x86_64 is not a sha256 digest: "latest"`)
+	if diff := cmp.Diff(expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("error mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewOCILayoutDigestResolver(t *testing.T) {
+	resolver, err := NewOCILayoutDigestResolver("mirror.local/ocp/release", "testdata/oci-layout")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"4.18.5-x86_64":  "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"4.18.6-aarch64": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+	}
+	for tag, expectedDigest := range expected {
+		if digest, err := resolver.Digest(nil, tag); err != nil || digest != expectedDigest {
+			t.Errorf("%s: expected %s, got %q (%v)", tag, expectedDigest, digest, err)
+		}
+	}
+
+	if _, err := NewOCILayoutDigestResolver("mirror.local/ocp/release", "testdata/graph-data"); err == nil {
+		t.Errorf("expected a directory without an oci-layout file to be rejected")
+	}
+}
+
+func TestServer_WithDigestResolver(t *testing.T) {
+	server := NewServer(
+		WithCandidatesSource(NewGraphDataDirCandidatesSource("testdata/graph-data")),
+		WithDigestResolver(NewStaticDigestResolver("mirror.local/ocp/release", map[string]string{
+			"4.22.0-ec.3-x86_64": "sha256:3333",
+		})),
+	)
+	scenario, err := ParseScenario([]byte(`name: mirror
+nodes:
+- id: A
+  version: '{{version}}'
+- id: B
+  version: 4.22.0-ec.3
+  resolve: true
+edges:
+- [A, B]
+`))
+	if err != nil {
+		t.Fatalf("failed to parse scenario: %v", err)
+	}
+
+	graph := server.generateScenarioGraph(scenario, semver.MustParse("4.22.0-ec.0"), "amd64", "mirror")
+	var images []string
+	for _, node := range graph.Nodes {
+		images = append(images, node.Image)
+	}
+	expected := []string{
+		"mirror.local/ocp/release@sha256:" + generateImageSHA256(semver.MustParse("4.22.0-ec.0")),
+		"mirror.local/ocp/release@sha256:3333",
+	}
+	if diff := cmp.Diff(expected, images); diff != "" {
+		t.Errorf("payload pullspecs mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,33 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error kinds of the Cincinnati policy engine, reported in the kind field of error responses
+const (
+	// ErrorKindMissingParams is reported for requests without the mandatory channel or version parameters
+	ErrorKindMissingParams = "missing_params"
+	// ErrorKindInvalidParams is reported for requests with a malformed version, an unknown arch or channel (in strict
+	// mode) and invalid fault parameters
+	ErrorKindInvalidParams = "invalid_params"
+	// ErrorKindInvalidContentType is reported for requests not accepting any of the served graph media types
+	ErrorKindInvalidContentType = "invalid_content_type"
+	// ErrorKindFailedJSONOut is reported when the graph cannot be serialized
+	ErrorKindFailedJSONOut = "failed_json_out"
+)
+
+// ErrorResponse is the body of graph error responses, as served by the Cincinnati policy engine
+type ErrorResponse struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// writeErrorResponse responds with a JSON ErrorResponse
+func writeErrorResponse(w http.ResponseWriter, status int, kind, value string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{Kind: kind, Value: value})
+}
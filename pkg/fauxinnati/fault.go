@@ -0,0 +1,189 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FaultType is a way of failing a graph request
+type FaultType string
+
+const (
+	// FaultLatency only delays the response
+	FaultLatency FaultType = "latency"
+	// FaultTimeout never responds; the request is held until the client gives up
+	FaultTimeout FaultType = "timeout"
+	// FaultStatus responds with an HTTP error status code
+	FaultStatus FaultType = "status"
+	// FaultReset resets the connection without responding
+	FaultReset FaultType = "reset"
+	// FaultTruncate declares the full body length but closes the connection after half of the body
+	FaultTruncate FaultType = "truncate"
+	// FaultInvalidJSON responds with a 200 and a body that is not valid JSON
+	FaultInvalidJSON FaultType = "invalid-json"
+	// FaultContentType responds with the graph served with a wrong Content-Type
+	FaultContentType FaultType = "content-type"
+)
+
+var faultTypes = []FaultType{FaultLatency, FaultTimeout, FaultStatus, FaultReset, FaultTruncate, FaultInvalidJSON, FaultContentType}
+
+// maxFaultTimeout bounds how long FaultTimeout holds a request whose client never gives up
+const maxFaultTimeout = 10 * time.Minute
+
+// FaultProfile describes how graph requests for a channel fail. Profiles can be declared in scenarios and on
+// channels, and overridden per request with query parameters (see faultProfileFromQuery).
+type FaultProfile struct {
+	// Type is the way the request fails. Latency is applied to all types; FaultLatency does nothing else.
+	Type FaultType `yaml:"type"`
+	// Latency delays the response
+	Latency time.Duration `yaml:"latency,omitempty"`
+	// Jitter is the upper bound of a random delay added to Latency
+	Jitter time.Duration `yaml:"jitter,omitempty"`
+	// StatusCode is the status code of FaultStatus responses, 503 when zero
+	StatusCode int `yaml:"statusCode,omitempty"`
+	// Probability is the probability of a request being affected by the fault, 1 when zero
+	Probability float64 `yaml:"probability,omitempty"`
+}
+
+// Validate checks that the fault profile is consistent
+func (f *FaultProfile) Validate() error {
+	if f.Type == "" {
+		return fmt.Errorf("fault has no type (one of %v)", faultTypes)
+	}
+	if !slices.Contains(faultTypes, f.Type) {
+		return fmt.Errorf("unknown fault type %q (one of %v)", f.Type, faultTypes)
+	}
+	if f.Latency < 0 || f.Jitter < 0 {
+		return fmt.Errorf("fault latency and jitter must not be negative")
+	}
+	if f.StatusCode != 0 && (f.StatusCode < 400 || f.StatusCode > 599) {
+		return fmt.Errorf("fault status code must be a 4xx or 5xx code, got %d", f.StatusCode)
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("fault probability must be between 0 and 1, got %v", f.Probability)
+	}
+	return nil
+}
+
+// faultProfileFromQuery returns the fault profile requested with the fault, fault_latency, fault_jitter,
+// fault_status and fault_probability query parameters, or the channel profile when the request does not
+// select a fault. A latency without a fault type means FaultLatency, and fault=none disables the channel profile.
+func faultProfileFromQuery(query url.Values, channelProfile *FaultProfile) (*FaultProfile, error) {
+	if !query.Has("fault") && !query.Has("fault_latency") {
+		return channelProfile, nil
+	}
+	if query.Get("fault") == "none" {
+		return nil, nil
+	}
+	profile := &FaultProfile{Type: FaultType(query.Get("fault"))}
+	if profile.Type == "" {
+		profile.Type = FaultLatency
+	}
+	var err error
+	if value := query.Get("fault_latency"); value != "" {
+		if profile.Latency, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid fault_latency: %w", err)
+		}
+	}
+	if value := query.Get("fault_jitter"); value != "" {
+		if profile.Jitter, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid fault_jitter: %w", err)
+		}
+	}
+	if value := query.Get("fault_status"); value != "" {
+		if profile.StatusCode, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid fault_status: %w", err)
+		}
+	}
+	if value := query.Get("fault_probability"); value != "" {
+		if profile.Probability, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid fault_probability: %w", err)
+		}
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// triggered decides whether a request is affected by the fault
+func (f *FaultProfile) triggered() bool {
+	return f.Probability == 0 || rand.Float64() < f.Probability
+}
+
+// delay waits for the profile latency, returning false when the client gave up in the meantime
+func (f *FaultProfile) delay(r *http.Request) bool {
+	latency := f.Latency
+	if f.Jitter > 0 {
+		latency += rand.N(f.Jitter)
+	}
+	if latency == 0 {
+		return true
+	}
+	select {
+	case <-time.After(latency):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// write responds to the request with the encoded graph body of the media type, failing it as the profile says
+func (f *FaultProfile) write(logger logrus.FieldLogger, w http.ResponseWriter, r *http.Request, body []byte, mediaType string) {
+	logger.WithFields(logrus.Fields{
+		"fault": f.Type,
+		"url":   r.URL,
+	}).Debug("Injecting fault.")
+
+	switch f.Type {
+	case FaultTimeout:
+		select {
+		case <-r.Context().Done():
+		case <-time.After(maxFaultTimeout):
+		}
+		resetConnection(w)
+	case FaultStatus:
+		code := f.StatusCode
+		if code == 0 {
+			code = http.StatusServiceUnavailable
+		}
+		http.Error(w, fmt.Sprintf("Injected fault: %s", http.StatusText(code)), code)
+	case FaultReset:
+		resetConnection(w)
+	case FaultTruncate:
+		// The server closes connections whose handler wrote less than the declared Content-Length
+		w.Header().Set("Content-Type", mediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body[:len(body)/2])
+	case FaultInvalidJSON:
+		w.Header().Set("Content-Type", mediaType)
+		_, _ = w.Write(body[:len(body)/2])
+	case FaultContentType:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(body)
+	default:
+		w.Header().Set("Content-Type", mediaType)
+		_, _ = w.Write(body)
+	}
+}
+
+// resetConnection closes the client connection with a TCP RST, or aborts the response when the connection
+// cannot be taken over
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,256 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestServer_handleGraph_Faults(t *testing.T) {
+	server := NewServer()
+	httpServer := httptest.NewServer(server.mux)
+	defer httpServer.Close()
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	tests := []struct {
+		name   string
+		query  string
+		client *http.Client
+		// delay is the minimal time to the response
+		delay time.Duration
+		// check inspects the response, or the error when there is no response
+		check func(t *testing.T, res *http.Response, body []byte, err error)
+	}{
+		{
+			name:  "latency only delays the graph",
+			query: "fault_latency=100ms",
+			delay: 100 * time.Millisecond,
+			check: func(t *testing.T, res *http.Response, body []byte, err error) {
+				if err != nil || res.StatusCode != http.StatusOK || !json.Valid(body) {
+					t.Errorf("expected a valid graph, got status %v, error %v", res, err)
+				}
+			},
+		},
+		{
+			name:  "status defaults to 503",
+			query: "fault=status",
+			check: func(t *testing.T, res *http.Response, _ []byte, err error) {
+				if err != nil || res.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("expected 503, got %v, error %v", res, err)
+				}
+			},
+		},
+		{
+			name:  "status with a code",
+			query: "fault=status&fault_status=404",
+			check: func(t *testing.T, res *http.Response, _ []byte, err error) {
+				if err != nil || res.StatusCode != http.StatusNotFound {
+					t.Errorf("expected 404, got %v, error %v", res, err)
+				}
+			},
+		},
+		{
+			name:  "reset",
+			query: "fault=reset",
+			check: func(t *testing.T, res *http.Response, _ []byte, err error) {
+				if err == nil {
+					t.Errorf("expected a connection error, got %d", res.StatusCode)
+				}
+			},
+		},
+		{
+			name:  "truncate",
+			query: "fault=truncate",
+			check: func(t *testing.T, res *http.Response, _ []byte, err error) {
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("expected an unexpected EOF reading the body, got %v", err)
+				}
+			},
+		},
+		{
+			name:  "invalid JSON",
+			query: "fault=invalid-json",
+			check: func(t *testing.T, res *http.Response, body []byte, err error) {
+				if err != nil || res.StatusCode != http.StatusOK || json.Valid(body) {
+					t.Errorf("expected a 200 with invalid JSON, got %v, error %v, body %q", res, err, body)
+				}
+			},
+		},
+		{
+			name:  "content type",
+			query: "fault=content-type",
+			check: func(t *testing.T, res *http.Response, body []byte, err error) {
+				if err != nil || res.Header.Get("Content-Type") != "text/html; charset=utf-8" || !json.Valid(body) {
+					t.Errorf("expected a graph served as HTML, got %v, error %v", res, err)
+				}
+			},
+		},
+		{
+			name:   "timeout",
+			query:  "fault=timeout",
+			client: &http.Client{Timeout: 200 * time.Millisecond},
+			check: func(t *testing.T, res *http.Response, _ []byte, err error) {
+				var urlErr *url.Error
+				if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+					t.Errorf("expected the client to time out, got %v", err)
+				}
+			},
+		},
+		{
+			name:  "invalid fault",
+			query: "fault=explode",
+			check: func(t *testing.T, res *http.Response, body []byte, err error) {
+				if err != nil || res.StatusCode != http.StatusBadRequest {
+					t.Errorf("expected 400, got %v, error %v", res, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := client
+			if tt.client != nil {
+				c = tt.client
+			}
+			start := time.Now()
+			res, err := c.Get(httpServer.URL + "/api/upgrades_info/graph?channel=simple&version=4.17.5&" + tt.query)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(res.Body)
+				_ = res.Body.Close()
+			}
+			tt.check(t, res, body, err)
+			if elapsed := time.Since(start); elapsed < tt.delay {
+				t.Errorf("expected the response to be delayed by %s, got it after %s", tt.delay, elapsed)
+			}
+		})
+	}
+}
+
+func TestFaultProfile_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     FaultProfile
+		expectedErr error
+	}{
+		{
+			name:    "valid",
+			profile: FaultProfile{Type: FaultStatus, StatusCode: 502, Latency: time.Second, Probability: 0.5},
+		},
+		{
+			name:        "no type",
+			expectedErr: errors.New("fault has no type (one of [latency timeout status reset truncate invalid-json content-type])"),
+		},
+		{
+			name:        "unknown type",
+			profile:     FaultProfile{Type: "explode"},
+			expectedErr: errors.New(`unknown fault type "explode" (one of [latency timeout status reset truncate invalid-json content-type])`),
+		},
+		{
+			name:        "status code outside errors",
+			profile:     FaultProfile{Type: FaultStatus, StatusCode: 302},
+			expectedErr: errors.New("fault status code must be a 4xx or 5xx code, got 302"),
+		},
+		{
+			name:        "probability above one",
+			profile:     FaultProfile{Type: FaultReset, Probability: 1.5},
+			expectedErr: errors.New("fault probability must be between 0 and 1, got 1.5"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate()
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_handleGraph_ScenarioFault(t *testing.T) {
+	dir := t.TempDir()
+	scenario := `name: flaky
+description: Always fails
+nodes:
+  - id: A
+    version: "{{version}}"
+fault:
+  type: status
+  statusCode: 500
+`
+	if err := os.WriteFile(filepath.Join(dir, "flaky.yaml"), []byte(scenario), 0644); err != nil {
+		t.Fatalf("failed to write scenario: %v", err)
+	}
+	server := NewServer()
+	if err := server.LoadScenariosDir(dir); err != nil {
+		t.Fatalf("failed to load scenarios: %v", err)
+	}
+
+	for query, expected := range map[string]int{
+		"channel=flaky&version=4.17.5":              http.StatusInternalServerError,
+		"channel=flaky&version=4.17.5&fault=status": http.StatusServiceUnavailable,
+		"channel=flaky&version=4.17.5&fault=none":   http.StatusOK,
+		"channel=simple&version=4.17.5":             http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?"+query, nil))
+		if w.Code != expected {
+			t.Errorf("%s: expected status %d, got %d", query, expected, w.Code)
+		}
+	}
+}
+
+func TestServer_handleGraph_FaultsWithoutResponse(t *testing.T) {
+	server := NewServer()
+	httpServer := httptest.NewServer(server.mux)
+	defer httpServer.Close()
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+
+	for _, fault := range []FaultType{FaultReset, FaultTimeout} {
+		res, err := client.Get(httpServer.URL + "/api/upgrades_info/graph?channel=simple&version=4.17.5&arch=amd64&fault=" + string(fault))
+		if err == nil {
+			_ = res.Body.Close()
+			t.Fatalf("%s: expected no response, got %d", fault, res.StatusCode)
+		}
+	}
+
+	// The timed out request is recorded once the server notices the client went away
+	var requests []RecordedRequest
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if requests = server.RecordedRequests(RequestFilter{}); len(requests) == 2 {
+			break
+		}
+	}
+	var got []RecordedRequest
+	for _, request := range requests {
+		got = append(got, RecordedRequest{Status: request.Status, Fault: request.Fault})
+	}
+	expected := []RecordedRequest{{Status: StatusNoResponse, Fault: FaultReset}, {Status: StatusNoResponse, Fault: FaultTimeout}}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected recorded requests (-want +got):\n%s", diff)
+	}
+
+	w := httptest.NewRecorder()
+	server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, expected := range []string{
+		`fauxinnati_graph_requests_total{channel="simple",arch="amd64",code="0",fault="reset"} 1`,
+		`fauxinnati_graph_requests_total{channel="simple",arch="amd64",code="0",fault="timeout"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), expected+"\n") {
+			t.Errorf("expected metrics to contain %s, got:\n%s", expected, w.Body.String())
+		}
+	}
+}
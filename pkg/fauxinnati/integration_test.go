@@ -1,6 +1,7 @@
 package fauxinnati
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,8 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	"github.com/petr-muller/vibes/pkg/testhelper"
 )
 
@@ -227,21 +230,64 @@ func TestServer_ErrorHandling(t *testing.T) {
 		name           string
 		url            string
 		method         string
+		options        []Option
 		expectedStatus int
-		expectedError  string
+		expectedError  *ErrorResponse
 	}{
 		{
 			name:           "missing parameters",
 			url:            "/api/upgrades_info/graph",
 			method:         "GET",
 			expectedStatus: 400,
-			expectedError:  "Missing required parameters",
+			expectedError:  &ErrorResponse{Kind: ErrorKindMissingParams, Value: "mandatory client parameters missing: channel, version"},
+		},
+		{
+			name:           "missing version",
+			url:            "/api/upgrades_info/graph?channel=simple",
+			method:         "GET",
+			expectedStatus: 400,
+			expectedError:  &ErrorResponse{Kind: ErrorKindMissingParams, Value: "mandatory client parameters missing: version"},
+		},
+		{
+			name:           "invalid version",
+			url:            "/api/upgrades_info/graph?channel=simple&version=4.17",
+			method:         "GET",
+			expectedStatus: 400,
+			expectedError:  &ErrorResponse{Kind: ErrorKindInvalidParams, Value: `invalid version "4.17": No Major.Minor.Patch elements found`},
+		},
+		{
+			name:           "unknown arch",
+			url:            "/api/upgrades_info/graph?channel=simple&version=4.17.5&arch=x86_64",
+			method:         "GET",
+			expectedStatus: 400,
+			expectedError:  &ErrorResponse{Kind: ErrorKindInvalidParams, Value: `unknown architecture "x86_64" (one of amd64, arm64, ppc64le, s390x, multi)`},
+		},
+		{
+			name:           "invalid fault parameters",
+			url:            "/api/upgrades_info/graph?channel=simple&version=4.17.5&fault=explode",
+			method:         "GET",
+			expectedStatus: 400,
+			expectedError:  &ErrorResponse{Kind: ErrorKindInvalidParams, Value: `invalid fault parameters: unknown fault type "explode" (one of [latency timeout status reset truncate invalid-json content-type])`},
+		},
+		{
+			name:           "unknown channel returns an empty graph",
+			url:            "/api/upgrades_info/graph?channel=stable-4.17&version=4.17.5",
+			method:         "GET",
+			expectedStatus: 200,
+		},
+		{
+			name:           "unknown channel is rejected in strict mode",
+			url:            "/api/upgrades_info/graph?channel=stable-4.17&version=4.17.5",
+			method:         "GET",
+			options:        []Option{WithStrictChannels()},
+			expectedStatus: 400,
+			expectedError:  &ErrorResponse{Kind: ErrorKindInvalidParams, Value: `unknown channel "stable-4.17"`},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			server := NewServer()
+			server := NewServer(tt.options...)
 			testServer := httptest.NewServer(server.mux)
 			defer testServer.Close()
 
@@ -262,6 +308,19 @@ func TestServer_ErrorHandling(t *testing.T) {
 			if resp.StatusCode != tt.expectedStatus {
 				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
 			}
+			if tt.expectedError == nil {
+				return
+			}
+			if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("expected a JSON error, got Content-Type %q", contentType)
+			}
+			var actual ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&actual); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if diff := cmp.Diff(tt.expectedError, &actual); diff != "" {
+				t.Errorf("unexpected error response (-want +got):\n%s", diff)
+			}
 		})
 	}
 }
@@ -0,0 +1,105 @@
+package fauxinnati
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long servers wait for in-flight requests to finish when shutting down
+const DefaultShutdownTimeout = 10 * time.Second
+
+// RunningServer is a server accepting connections, started with Listen
+type RunningServer struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:41234. Servers listening on all addresses are
+	// reported on the IPv4 loopback address.
+	URL string
+
+	stop context.CancelFunc
+	done chan struct{}
+	err  error
+}
+
+// Listen binds addr (e.g. ":8080" or "127.0.0.1:0" for a random port) and serves requests in the background
+// until ctx is cancelled or the returned server is closed. The server is then shut down gracefully: in-flight
+// requests are given the shutdown timeout to finish.
+func (s *Server) Listen(ctx context.Context, addr string) (*RunningServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	running := &RunningServer{
+		URL:  fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port)),
+		stop: stop,
+		done: make(chan struct{}),
+	}
+	httpServer := &http.Server{Handler: accessLog(s.logger, s.clock, s.mux)}
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.Serve(listener)
+	}()
+	go func() {
+		defer close(running.done)
+		select {
+		case err := <-served:
+			running.err = err
+			stop()
+			return
+		case <-ctx.Done():
+		}
+
+		s.logger.WithField("timeout", s.shutdownTimeout).Info("Shutting down fauxinnati server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			s.logger.WithError(err).Warning("In-flight requests did not finish in time, closing their connections")
+			_ = httpServer.Close()
+			running.err = err
+		}
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) && running.err == nil {
+			running.err = err
+		}
+	}()
+	return running, nil
+}
+
+// Wait blocks until the server is shut down. It returns nil when all in-flight requests finished in time.
+func (r *RunningServer) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// Close shuts the server down and waits for it, see Wait
+func (r *RunningServer) Close() error {
+	r.stop()
+	return r.Wait()
+}
+
+// Run serves requests on addr until ctx is cancelled, then shuts the server down gracefully
+func (s *Server) Run(ctx context.Context, addr string) error {
+	running, err := s.Listen(ctx, addr)
+	if err != nil {
+		return err
+	}
+	s.logger.WithField("url", running.URL).Info("Started fauxinnati server")
+	return running.Wait()
+}
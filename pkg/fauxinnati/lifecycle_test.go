@@ -0,0 +1,109 @@
+package fauxinnati
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Listen(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	running, err := NewServer().Listen(ctx, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	if !strings.HasPrefix(running.URL, "http://127.0.0.1:") {
+		t.Errorf("unexpected URL %s", running.URL)
+	}
+
+	res, err := http.Get(running.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("failed to query the running server: %v", err)
+	}
+	_ = res.Body.Close()
+
+	cancel()
+	if err := running.Wait(); err != nil {
+		t.Errorf("expected a graceful shutdown, got %v", err)
+	}
+	if _, err := http.Get(running.URL + "/healthz"); err == nil {
+		t.Errorf("expected the server to stop accepting connections")
+	}
+}
+
+func TestServer_Listen_UnspecifiedAddress(t *testing.T) {
+	server := NewServer()
+	if err := server.EnableSelfSignedTLS([]string{"127.0.0.1"}); err != nil {
+		t.Fatalf("failed to enable TLS: %v", err)
+	}
+	running, err := server.Listen(t.Context(), ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() {
+		_ = running.Close()
+	}()
+	if !strings.HasPrefix(running.URL, "https://127.0.0.1:") {
+		t.Errorf("unexpected URL %s", running.URL)
+	}
+}
+
+func TestRunningServer_Close(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		shutdownTimeout time.Duration
+		expectGraceful  bool
+	}{
+		{
+			name:            "in-flight requests are drained",
+			query:           "fault_latency=200ms",
+			shutdownTimeout: 5 * time.Second,
+			expectGraceful:  true,
+		},
+		{
+			name:            "requests running past the timeout are cut off",
+			query:           "fault=timeout",
+			shutdownTimeout: 100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(WithShutdownTimeout(tt.shutdownTimeout))
+			running, err := server.Listen(t.Context(), "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+
+			responses := make(chan error, 1)
+			go func() {
+				res, err := http.Get(running.URL + "/api/upgrades_info/graph?channel=simple&version=4.17.5&" + tt.query)
+				if err == nil {
+					_ = res.Body.Close()
+				}
+				responses <- err
+			}()
+			// Let the request reach the server
+			time.Sleep(50 * time.Millisecond)
+
+			closeErr := running.Close()
+			requestErr := <-responses
+			if tt.expectGraceful {
+				if closeErr != nil || requestErr != nil {
+					t.Errorf("expected the request to be drained, got close error %v and request error %v", closeErr, requestErr)
+				}
+				return
+			}
+			if !errors.Is(closeErr, context.DeadlineExceeded) {
+				t.Errorf("expected the shutdown to time out, got %v", closeErr)
+			}
+			if requestErr == nil {
+				t.Errorf("expected the request to be cut off")
+			}
+		})
+	}
+}
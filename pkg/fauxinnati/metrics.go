@@ -0,0 +1,248 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AIDEV-NOTE: Metrics are rendered in the Prometheus text exposition format by hand to avoid depending on
+// client_golang; only counters and histograms are needed
+
+// metricsContentType is the content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// durationBuckets are the upper bounds of the request duration histogram buckets, in seconds
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics are the metrics of a server, exposed at /metrics
+type metrics struct {
+	graphRequests        *counterVec
+	graphRequestDuration *histogramVec
+	upstreamRequests     *counterVec
+	upstreamFailures     *counterVec
+	cacheHits            *counterVec
+	cacheMisses          *counterVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		graphRequests: newCounterVec("fauxinnati_graph_requests_total",
+			"Graph requests by channel, arch, response status code and injected fault.", "channel", "arch", "code", "fault"),
+		graphRequestDuration: newHistogramVec("fauxinnati_graph_request_duration_seconds",
+			"Duration of graph requests by channel, arch, response status code and injected fault.", durationBuckets, "channel", "arch", "code", "fault"),
+		upstreamRequests: newCounterVec("fauxinnati_upstream_requests_total",
+			"Requests to upstream services (GitHub, payload registry, upstream Cincinnati) by host.", "host"),
+		upstreamFailures: newCounterVec("fauxinnati_upstream_request_failures_total",
+			"Failed requests to upstream services by host, including non-2xx responses.", "host"),
+		cacheHits: newCounterVec("fauxinnati_cache_hits_total",
+			"Cache lookups that found the value, by cache.", "cache"),
+		cacheMisses: newCounterVec("fauxinnati_cache_misses_total",
+			"Cache lookups that did not find the value, by cache.", "cache"),
+	}
+}
+
+// observeGraphRequest records a served graph request. Unknown channels are reported as "unknown" and
+// unknown arches as "other" so that clients cannot blow up the number of series. Requests closed without a
+// response have the StatusNoResponse code, and the fault label tells which fault closed them.
+func (m *metrics) observeGraphRequest(channel string, known bool, arch string, status int, fault FaultType, duration time.Duration) {
+	if !known {
+		channel = "unknown"
+	}
+	// Requests with unknown arches are rejected but still observed; keep the label cardinality bounded
+	if arch != "" && !slices.Contains(Architectures, arch) {
+		arch = "other"
+	}
+	code := strconv.Itoa(status)
+	m.graphRequests.add(1, channel, arch, code, string(fault))
+	m.graphRequestDuration.observe(duration.Seconds(), channel, arch, code, string(fault))
+}
+
+func (m *metrics) write(w io.Writer) error {
+	for _, metric := range []interface{ write(io.Writer) error }{
+		m.graphRequests, m.graphRequestDuration, m.upstreamRequests, m.upstreamFailures, m.cacheHits, m.cacheMisses,
+	} {
+		if err := metric.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	_ = s.metrics.write(w)
+}
+
+// instrumentedClient counts requests to upstream services
+type instrumentedClient struct {
+	client  Client
+	metrics *metrics
+}
+
+func (c *instrumentedClient) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	c.metrics.upstreamRequests.add(1, host)
+	res, err := c.client.Do(req)
+	if err != nil || res.StatusCode < 200 || res.StatusCode > 299 {
+		c.metrics.upstreamFailures.add(1, host)
+	}
+	return res, err
+}
+
+// instrumentedCache counts hits and misses of a Cache
+type instrumentedCache struct {
+	Cache
+	name    string
+	metrics *metrics
+}
+
+func (c *instrumentedCache) Get(k string) (interface{}, bool) {
+	value, found := c.Cache.Get(k)
+	if found {
+		c.metrics.cacheHits.add(1, c.name)
+	} else {
+		c.metrics.cacheMisses.add(1, c.name)
+	}
+	return value, found
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labeledSeries is a set of series of a metric, keyed by their label values
+type labeledSeries[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+
+	lock   sync.Mutex
+	series map[string]*T
+	labels map[string][]string
+}
+
+func (l *labeledSeries[T]) get(labelValues []string) *T {
+	key := strings.Join(labelValues, "\xff")
+	if s, ok := l.series[key]; ok {
+		return s
+	}
+	s := new(T)
+	l.series[key] = s
+	l.labels[key] = labelValues
+	return s
+}
+
+func (l *labeledSeries[T]) writeHeader(w io.Writer, metricType string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", l.name, l.help, l.name, metricType)
+	return err
+}
+
+// formatLabels renders the label set of the series with key, with optional extra label pairs
+func (l *labeledSeries[T]) formatLabels(key string, extra ...string) string {
+	var pairs []string
+	for i, value := range l.labels[key] {
+		pairs = append(pairs, l.labelNames[i]+`="`+labelValueEscaper.Replace(value)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelValueEscaper.Replace(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type counterVec struct {
+	labeledSeries[float64]
+}
+
+func newCounterVec(name, help string, labelNames ...string) *counterVec {
+	return &counterVec{labeledSeries[float64]{name: name, help: help, labelNames: labelNames, series: map[string]*float64{}, labels: map[string][]string{}}}
+}
+
+func (c *counterVec) add(value float64, labelValues ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	*c.get(labelValues) += value
+}
+
+func (c *counterVec) write(w io.Writer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.writeHeader(w, "counter"); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.series)) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(key), formatPrometheusValue(*c.series[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogram struct {
+	// counts are the non-cumulative counts of observations per bucket, the last one being +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	labeledSeries[histogram]
+	buckets []float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labelNames ...string) *histogramVec {
+	return &histogramVec{
+		labeledSeries: labeledSeries[histogram]{name: name, help: help, labelNames: labelNames, series: map[string]*histogram{}, labels: map[string][]string{}},
+		buckets:       buckets,
+	}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := h.get(labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets)+1)
+	}
+	bucket, _ := slices.BinarySearch(h.buckets, value)
+	s.counts[bucket]++
+	s.sum += value
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.writeHeader(w, "histogram"); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatPrometheusValue(h.buckets[i])
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", le), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, h.formatLabels(key), formatPrometheusValue(s.sum), h.name, h.formatLabels(key), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
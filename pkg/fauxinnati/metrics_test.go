@@ -0,0 +1,94 @@
+package fauxinnati
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestMetrics_write(t *testing.T) {
+	m := newMetrics()
+	m.observeGraphRequest("simple", true, "amd64", http.StatusOK, "", 3*time.Millisecond)
+	m.observeGraphRequest("simple", true, "amd64", http.StatusOK, "", 300*time.Millisecond)
+	m.observeGraphRequest("simple", true, "", http.StatusServiceUnavailable, FaultStatus, 20*time.Second)
+	m.observeGraphRequest("simple", true, "", StatusNoResponse, FaultReset, time.Millisecond)
+	m.observeGraphRequest("no-such-channel", false, "sparc", http.StatusOK, "", time.Millisecond)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	client := &instrumentedClient{client: http.DefaultClient, metrics: m}
+	for _, path := range []string{"/ok", "/ok", "/missing"} {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, upstream.URL+path, nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = res.Body.Close()
+	}
+
+	c := &instrumentedCache{Cache: cache.New(time.Minute, time.Minute), name: "candidates", metrics: m}
+	c.Get("candidates-4.17")
+	c.Set("candidates-4.17", "cached", time.Minute)
+	c.Get("candidates-4.17")
+
+	var out bytes.Buffer
+	if err := m.write(&out); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	// The upstream host has a random port
+	testhelper.CompareWithFixture(t, strings.ReplaceAll(out.String(), strings.TrimPrefix(upstream.URL, "http://"), "upstream"))
+}
+
+func TestServer_handleMetrics(t *testing.T) {
+	server := NewServer()
+	for _, query := range []string{"channel=simple&version=4.17.5&arch=amd64", "channel=simple&version=4.17.5&fault=status", "channel=whatever&version=4.17.5"} {
+		server.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?"+query, nil))
+	}
+
+	w := httptest.NewRecorder()
+	server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != metricsContentType {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	for _, expected := range []string{
+		`fauxinnati_graph_requests_total{channel="simple",arch="amd64",code="200",fault=""} 1`,
+		`fauxinnati_graph_requests_total{channel="simple",arch="",code="503",fault="status"} 1`,
+		`fauxinnati_graph_requests_total{channel="unknown",arch="",code="200",fault=""} 1`,
+		`fauxinnati_graph_request_duration_seconds_count{channel="simple",arch="amd64",code="200",fault=""} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), expected+"\n") {
+			t.Errorf("expected metrics to contain %s, got:\n%s", expected, w.Body.String())
+		}
+	}
+}
+
+func TestServer_handleMetrics_HealthChecks(t *testing.T) {
+	server := NewServer(WithStrictChannels())
+	for _, path := range []string{"/healthz", "/readyz", "/healthz"} {
+		server.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	w := httptest.NewRecorder()
+	server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, series := range []string{"fauxinnati_graph_requests_total{", "fauxinnati_graph_request_duration_seconds_count{"} {
+		if strings.Contains(w.Body.String(), series) {
+			t.Errorf("expected health checks not to be observed as graph requests, got:\n%s", w.Body.String())
+		}
+	}
+}
@@ -19,32 +19,40 @@ type NodeBuilder struct {
 	client         Client
 	getLatest      func(client Client, major, minor uint64) (semver.Version, error)
 	digestResolver DigestResolver
+	logger         logrus.FieldLogger
 }
 
+// DigestResolver resolves release payload tags (e.g. 4.18.5-x86_64) to manifest digests
 type DigestResolver interface {
-	getDigest(client Client, tag string) (string, error)
-	getRepository() string
+	// Digest returns the digest (sha256:...) of the payload with the given tag
+	Digest(client Client, tag string) (string, error)
+	// Repository returns the repository payload pullspecs point to
+	Repository() string
 }
 
-func latestPatchVersion(client Client, queriedVersion, version semver.Version, getLatest func(client Client, major, minor uint64) (semver.Version, error)) semver.Version {
+func latestPatchVersion(logger logrus.FieldLogger, client Client, queriedVersion, version semver.Version, getLatest func(client Client, major, minor uint64) (semver.Version, error)) semver.Version {
 	latest, err := getLatest(client, version.Major, version.Minor)
 	if err != nil {
-		logrus.WithError(err).WithField("version", version).WithField("major.minor", fmt.Sprintf("%d.%d", version.Minor, version.Minor)).Warning("Fail to find the latest version")
+		logger.WithError(err).WithField("version", version).WithField("major.minor", fmt.Sprintf("%d.%d", version.Minor, version.Minor)).Warning("Fail to find the latest version")
 	} else if latest.LTE(queriedVersion) {
-		logrus.WithField("queriedVersion", queriedVersion).WithField("latest", latest).Warning("The latest version is not greater than the queried version")
+		logger.WithField("queriedVersion", queriedVersion).WithField("latest", latest).Warning("The latest version is not greater than the queried version")
 	} else if latest.LE(version) {
-		logrus.WithField("version", version).WithField("latest", latest).Debug("Use the latest patch version")
+		logger.WithField("version", version).WithField("latest", latest).Debug("Use the latest patch version")
 		return latest
 	}
 	return version
 }
 
 func (b *NodeBuilder) Build() Node {
-	version := latestPatchVersion(b.client, b.queriedVersion, b.version, b.getLatest)
+	logger := b.logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	version := latestPatchVersion(logger, b.client, b.queriedVersion, b.version, b.getLatest)
 	suffix := b.architecture
 	switch b.architecture {
 	case "":
-		logrus.Debug("No architecture specified. Using default to resolve the image digest")
+		logger.Debug("No architecture specified. Using default to resolve the image digest")
 		suffix = "x86_64"
 	case "amd64":
 		suffix = "x86_64"
@@ -52,7 +60,7 @@ func (b *NodeBuilder) Build() Node {
 		suffix = "aarch64"
 	}
 	tag := fmt.Sprintf("%s-%s", version.String(), suffix)
-	digest, err := b.digestResolver.getDigest(b.client, tag)
+	digest, err := b.digestResolver.Digest(b.client, tag)
 	if err != nil {
 		digest = fmt.Sprintf("sha256:%064x", version.Major*1000000+version.Minor*1000+version.Patch)
 	}
@@ -66,7 +74,7 @@ func (b *NodeBuilder) Build() Node {
 	}
 	return Node{
 		Version:  version,
-		Image:    fmt.Sprintf("%s@%s", b.digestResolver.getRepository(), digest),
+		Image:    fmt.Sprintf("%s@%s", b.digestResolver.Repository(), digest),
 		Metadata: metadata,
 	}
 }
@@ -105,3 +113,9 @@ func (b *NodeBuilder) WithDigestResolver(digestResolver DigestResolver) *NodeBui
 	b.digestResolver = digestResolver
 	return b
 }
+
+// WithLogger sets the logger of the builder, the logrus standard logger by default
+func (b *NodeBuilder) WithLogger(logger logrus.FieldLogger) *NodeBuilder {
+	b.logger = logger
+	return b
+}
@@ -0,0 +1,210 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultHTTPTimeout is the timeout of a single attempt of an upstream request made by the default client
+	DefaultHTTPTimeout = 30 * time.Second
+	// DefaultHTTPRetries is how many times the default client retries failed upstream requests
+	DefaultHTTPRetries = 3
+	// DefaultCandidatesTTL is how long candidate channels read from a CandidatesSource are cached
+	DefaultCandidatesTTL = 60 * time.Minute
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Option configures a Server created by NewServer
+type Option func(*Server)
+
+// WithHTTPClient sets the client used for all upstream requests (GitHub, payload registry, upstream Cincinnati).
+// The default is NewRetryingClient(DefaultHTTPTimeout, DefaultHTTPRetries).
+func WithHTTPClient(client Client) Option {
+	return func(s *Server) {
+		s.client = client
+	}
+}
+
+// WithCache sets the cache of candidate channels
+func WithCache(cache Cache) Option {
+	return func(s *Server) {
+		s.candidatesGetter.cache = cache
+	}
+}
+
+// WithDigestCache sets the cache of payload digests resolved from a registry by the default or a
+// NewRegistryDigestResolver resolver; other resolvers do not cache digests
+func WithDigestCache(cache Cache) Option {
+	return func(s *Server) {
+		s.digestCache = cache
+	}
+}
+
+// WithCandidatesTTL sets how long candidate channels are cached, DefaultCandidatesTTL by default
+func WithCandidatesTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.candidatesGetter.ttl = ttl
+	}
+}
+
+// WithDigestResolver sets how payload tags are resolved to digests, and the repository payload pullspecs point to.
+// Digests are resolved from quay.io by default.
+func WithDigestResolver(resolver DigestResolver) Option {
+	return func(s *Server) {
+		s.digestResolver = resolver
+	}
+}
+
+// WithCandidatesSource sets where candidate channels of cincinnati-graph-data are read from, GitHub by default
+func WithCandidatesSource(source CandidatesSource) Option {
+	return func(s *Server) {
+		s.candidatesGetter.source = source
+	}
+}
+
+// WithSessionIdleTimeout sets how long sessions of clusters that stopped polling are kept,
+// DefaultSessionIdleTimeout by default
+func WithSessionIdleTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.sessionIdleTimeout = timeout
+	}
+}
+
+// WithRequestHistory sets how many graph requests the server keeps, DefaultRequestHistory by default. Zero
+// (or a negative capacity) disables recording.
+func WithRequestHistory(capacity int) Option {
+	return func(s *Server) {
+		s.requests = NewRequestRecorder(capacity)
+	}
+}
+
+// WithShutdownTimeout sets how long the server waits for in-flight requests to finish when shutting down,
+// DefaultShutdownTimeout by default. Requests still running after the timeout have their connections closed.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithAdminToken enables the admin API at /api/admin, authenticating requests with the bearer token
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithClock sets the clock used to timestamp recorded requests, measure request durations, trigger scenario timeline
+// steps and date generated certificates
+func WithClock(clock Clock) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithLogger sets the logger of the server and everything it logs through (candidate sources, the registry digest
+// resolver, proxy overlays, fault injection and clients created by NewRetryingClient), the logrus standard logger by
+// default
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(s *Server) {
+		s.logger = logger
+		s.candidatesGetter.logger = logger
+	}
+}
+
+// WithStrictChannels makes graph requests for channels that are not served fail with a 400 invalid_params error,
+// like an upstream Cincinnati rejecting the channel, instead of returning an empty graph
+func WithStrictChannels() Option {
+	return func(s *Server) {
+		s.strict = true
+	}
+}
+
+// NewRetryingClient returns a client retrying failed requests (connection errors, 429 and 5xx responses) with
+// backoff, each attempt limited by timeout. Requests and retries are logged with the logrus standard logger, or
+// with the server logger when the client is passed to a server.
+func NewRetryingClient(timeout time.Duration, retries int) Client {
+	return newRetryingClient(timeout, retries, logrus.StandardLogger())
+}
+
+func newRetryingClient(timeout time.Duration, retries int, logger logrus.FieldLogger) *http.Client {
+	client := retryablehttp.NewClient()
+	client.HTTPClient.Timeout = timeout
+	client.RetryMax = retries
+	client.RetryWaitMin = 1 * time.Second
+	client.RetryWaitMax = 5 * time.Second
+	client.Logger = retryLogger{logger: logger}
+	return client.StandardClient()
+}
+
+// withClientLogger returns a client created by NewRetryingClient with the same settings, logging with logger.
+// Other clients are returned as they are.
+func withClientLogger(client Client, logger logrus.FieldLogger) Client {
+	standard, ok := client.(*http.Client)
+	if !ok {
+		return client
+	}
+	transport, ok := standard.Transport.(*retryablehttp.RoundTripper)
+	if !ok {
+		return client
+	}
+	if _, ok := transport.Client.Logger.(retryLogger); !ok {
+		return client
+	}
+	return newRetryingClient(transport.Client.HTTPClient.Timeout, transport.Client.RetryMax, logger)
+}
+
+// retryLogger adapts a logrus logger to the leveled logger of retrying clients, which would otherwise write to
+// stderr
+type retryLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l retryLogger) withFields(keysAndValues []interface{}) logrus.FieldLogger {
+	fields := logrus.Fields{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	return l.logger.WithFields(fields)
+}
+
+func (l retryLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Error(msg)
+}
+
+func (l retryLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Info(msg)
+}
+
+func (l retryLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Debug(msg)
+}
+
+func (l retryLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.withFields(keysAndValues).Warning(msg)
+}
+
+type offlineClient struct{}
+
+// NewOfflineClient returns a client failing all requests, for running a server that must not reach the network
+func NewOfflineClient() Client {
+	return offlineClient{}
+}
+
+func (offlineClient) Do(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("offline: refusing to request %s", req.URL)
+}
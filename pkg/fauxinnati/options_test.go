@@ -0,0 +1,175 @@
+package fauxinnati
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+	"github.com/patrickmn/go-cache"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// testClock only moves when advanced, and by step every time it is read
+type testClock struct {
+	lock sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *testClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeClient answers upstream requests with the graph and counts them
+type fakeClient struct {
+	graph    Graph
+	requests []string
+}
+
+func (c *fakeClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests = append(c.requests, req.URL.String())
+	body, err := json.Marshal(c.graph)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestNewServer_Options(t *testing.T) {
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	client := &fakeClient{graph: proxyTestGraph()}
+	logger, hook := test.NewNullLogger()
+	candidates := cache.New(time.Minute, time.Minute)
+	server := NewServer(
+		WithClock(&testClock{now: now}),
+		WithHTTPClient(client),
+		WithLogger(logger),
+		WithCache(candidates),
+		WithCandidatesSource(NewGraphDataDirCandidatesSource("testdata/graph-data")),
+		WithDigestResolver(testDigestResolver),
+	)
+
+	if err := server.RegisterProxyChannel(&ProxyChannel{Name: "stable-4.17", Upstream: "https://upstream.example.com/graph"}); err != nil {
+		t.Fatalf("failed to register proxy channel: %v", err)
+	}
+	graph := queryGraph(t, server, "channel=stable-4.17&version=4.17.1&id=options")
+	if diff := cmp.Diff(proxyTestGraph(), graph); diff != "" {
+		t.Errorf("unexpected graph (-want +got):\n%s", diff)
+	}
+	if len(client.requests) != 1 || !strings.HasPrefix(client.requests[0], "https://upstream.example.com/graph?") {
+		t.Errorf("expected the upstream graph to be fetched with the injected client, got %v", client.requests)
+	}
+
+	requests := server.RecordedRequests(RequestFilter{ID: "options"})
+	if len(requests) != 1 || !requests[0].Time.Equal(now) {
+		t.Errorf("expected one request recorded at %s, got %+v", now, requests)
+	}
+
+	ocp88175 := server.generateOCP88175Graph(semver.MustParse("4.22.0-0-2026-03-03-000541-test-ci-ln-1phllqb-latest"), "amd64", "", false)
+	if ocp88175.Error != "" || len(ocp88175.Nodes) == 0 {
+		t.Errorf("expected a graph from the injected candidates source and digest resolver, got %+v", ocp88175)
+	}
+	if _, ok := candidates.Get("candidates-4.22"); !ok {
+		t.Errorf("expected the candidates to be cached in the injected cache")
+	}
+
+	running, err := server.Listen(t.Context(), "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	_ = running.Close()
+	if entry := hook.LastEntry(); entry == nil || entry.Message != "Shutting down fauxinnati server" {
+		t.Errorf("expected the server to log with the injected logger, got %+v", entry)
+	}
+}
+
+func TestNewServer_DigestCache(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	digests := cache.New(time.Minute, time.Minute)
+	digests.Set("getDigest-4.17.1-x86_64", "sha256:1111", cache.NoExpiration)
+	server := NewServer(
+		WithHTTPClient(NewOfflineClient()),
+		WithLogger(logger),
+		WithDigestResolver(NewRegistryDigestResolver("quay.io/openshift-release-dev/ocp-release", "", false)),
+		WithDigestCache(digests),
+	)
+
+	digest, err := server.digestResolver.Digest(server.client, "4.17.1-x86_64")
+	if err != nil {
+		t.Fatalf("expected the digest to be served from the injected cache, got %v", err)
+	}
+	if digest != "sha256:1111" {
+		t.Errorf("unexpected digest %q", digest)
+	}
+	if entry := hook.LastEntry(); entry == nil || entry.Message != "Found digest in cache" {
+		t.Errorf("expected the digest resolver to log with the injected logger, got %+v", entry)
+	}
+}
+
+func TestNewServer_RetryingClientLogger(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	server := NewServer(WithHTTPClient(NewRetryingClient(time.Second, 0)), WithLogger(logger))
+
+	req, err := http.NewRequest(http.MethodGet, upstream.URL+"/graph", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	res, err := server.client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = res.Body.Close()
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.DebugLevel || entry.Data["url"] != upstream.URL+"/graph" {
+		t.Errorf("expected the retrying client to log the request with the injected logger, got %+v", entry)
+	}
+}
+
+func TestNewServer_OfflineClient(t *testing.T) {
+	server := NewServer(WithHTTPClient(NewOfflineClient()), WithLogger(logrus.New()))
+	if err := server.RegisterProxyChannel(&ProxyChannel{Name: "stable-4.17"}); err != nil {
+		t.Fatalf("failed to register proxy channel: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?channel=stable-4.17&version=4.17.1", nil)
+	w := httptest.NewRecorder()
+	server.mux.ServeHTTP(w, req)
+	var graph Graph
+	if err := json.NewDecoder(w.Body).Decode(&graph); err != nil {
+		t.Fatalf("failed to decode graph: %v", err)
+	}
+	if !strings.Contains(graph.Error, "failed to fetch") || len(graph.Nodes) != 0 {
+		t.Errorf("expected an offline server to fail fetching upstream graphs, got %+v", graph)
+	}
+}
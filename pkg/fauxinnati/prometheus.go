@@ -0,0 +1,106 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// prometheusResponse is the envelope of Prometheus HTTP API responses
+type prometheusResponse struct {
+	Status    string          `json:"status"`
+	Data      *prometheusData `json:"data,omitempty"`
+	ErrorType string          `json:"errorType,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type prometheusData struct {
+	ResultType string      `json:"resultType"`
+	Result     interface{} `json:"result"`
+}
+
+type prometheusSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"`
+}
+
+// EnablePrometheus serves a Prometheus-compatible /api/v1/query endpoint that evaluates a small PromQL subset
+// against the given static series, so that clusters can evaluate the PromQL matching rules of served risks
+// without a real Prometheus. It must be called before the server starts serving requests.
+func (s *Server) EnablePrometheus(series []Sample) {
+	s.prometheusSeries = series
+	s.prometheusEnabled = true
+}
+
+func (s *Server) handlePrometheusQuery(w http.ResponseWriter, r *http.Request) {
+	if !s.prometheusEnabled {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.writePrometheusError(w, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+
+	evaluationTime := s.clock.Now()
+	if t := r.Form.Get("time"); t != "" {
+		seconds, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			s.writePrometheusError(w, http.StatusBadRequest, "bad_data", "invalid parameter \"time\": cannot parse \""+t+"\" to a valid timestamp")
+			return
+		}
+		evaluationTime = time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+	timestamp := float64(evaluationTime.UnixMilli()) / 1000
+
+	query := r.Form.Get("query")
+	scalar, vector, err := EvaluatePromQL(query, s.prometheusSeries)
+	if err != nil {
+		s.logger.WithError(err).WithField("query", query).Debug("Failed to evaluate PromQL query")
+		var parseErr *PromQLParseError
+		if errors.As(err, &parseErr) {
+			s.writePrometheusError(w, http.StatusBadRequest, "bad_data", err.Error())
+		} else {
+			s.writePrometheusError(w, http.StatusUnprocessableEntity, "execution", err.Error())
+		}
+		return
+	}
+
+	data := &prometheusData{ResultType: "vector"}
+	if scalar != nil {
+		data.ResultType = "scalar"
+		data.Result = [2]interface{}{timestamp, formatPrometheusValue(*scalar)}
+	} else {
+		samples := []prometheusSample{}
+		for _, sample := range vector {
+			samples = append(samples, prometheusSample{
+				Metric: sample.Labels,
+				Value:  [2]interface{}{timestamp, formatPrometheusValue(sample.Value)},
+			})
+		}
+		data.Result = samples
+	}
+	s.writePrometheusResponse(w, http.StatusOK, prometheusResponse{Status: "success", Data: data})
+}
+
+func formatPrometheusValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (s *Server) writePrometheusError(w http.ResponseWriter, status int, errorType, message string) {
+	s.writePrometheusResponse(w, status, prometheusResponse{Status: "error", ErrorType: errorType, Error: message})
+}
+
+func (s *Server) writePrometheusResponse(w http.ResponseWriter, status int, response prometheusResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.WithError(err).Warning("Failed to encode Prometheus response")
+	}
+}
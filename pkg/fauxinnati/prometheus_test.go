@@ -0,0 +1,99 @@
+package fauxinnati
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServer_handlePrometheusQuery(t *testing.T) {
+	series, err := ParseSeries(testSeries)
+	if err != nil {
+		t.Fatalf("failed to parse series: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		disabled       bool
+		method         string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "matching rule",
+			query:          "vector(1)",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"1"]}]}}`,
+		},
+		{
+			name:           "non-matching rule over POST",
+			method:         http.MethodPost,
+			query:          "vector(0)",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0"]}]}}`,
+		},
+		{
+			name:           "selector over static series",
+			query:          `group by (type) (cluster_infrastructure_provider)`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"type":"AWS"},"value":[1700000000,"1"]}]}}`,
+		},
+		{
+			name:           "scalar",
+			query:          "1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1"]}}`,
+		},
+		{
+			name:           "unparsable query",
+			query:          "this will fail; muahaha",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"error","errorType":"bad_data","error":"parse error: unexpected character ';'"}`,
+		},
+		{
+			name:           "query failing to evaluate",
+			query:          "up * up",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"status":"error","errorType":"execution","error":"multiplying two instant vectors is not supported"}`,
+		},
+		{
+			name:           "disabled",
+			disabled:       true,
+			query:          "vector(1)",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			if !tt.disabled {
+				server.EnablePrometheus(series)
+			}
+
+			params := url.Values{"query": {tt.query}, "time": {"1700000000"}}
+			var req *http.Request
+			if tt.method == http.MethodPost {
+				req = httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(http.MethodGet, "/api/v1/query?"+params.Encode(), nil)
+			}
+			w := httptest.NewRecorder()
+			server.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if diff := cmp.Diff(tt.expectedBody, strings.TrimSpace(w.Body.String())); diff != "" {
+				t.Errorf("body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
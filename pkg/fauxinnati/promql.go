@@ -0,0 +1,633 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// AIDEV-NOTE: This is a deliberately small PromQL subset, just enough to answer the matching rules of update risks:
+// number and vector() literals, selectors with =, !=, =~ and !~ matchers, aggregations (group, count, sum, min, max)
+// with by/without, scalar multiplication and the or/and/unless set operators. Everything else is a parse error.
+
+// Sample is a sample of an instant vector: a set of labels (the metric name is the __name__ label) and a value
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// promQLValue is the result of evaluating an expression: a scalar or an instant vector
+type promQLValue struct {
+	scalar   *float64
+	vector   []Sample
+	isScalar bool
+}
+
+// promQLExpr is a parsed expression evaluated against a static set of series
+type promQLExpr func(series []Sample) (promQLValue, error)
+
+// EvaluatePromQL evaluates query against the static series set. Parse errors are returned as *PromQLParseError.
+func EvaluatePromQL(query string, series []Sample) (scalar *float64, vector []Sample, err error) {
+	expr, err := parsePromQL(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := expr(series)
+	if err != nil {
+		return nil, nil, err
+	}
+	if value.isScalar {
+		return value.scalar, nil, nil
+	}
+	sortSamples(value.vector)
+	return nil, value.vector, nil
+}
+
+// PromQLParseError is returned for queries outside the supported PromQL subset
+type PromQLParseError struct {
+	Message string
+}
+
+func (e *PromQLParseError) Error() string {
+	return "parse error: " + e.Message
+}
+
+// parsePromQL parses query into an expression that can be evaluated against a set of series
+func parsePromQL(query string) (promQLExpr, error) {
+	tokens, err := tokenizePromQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &promQLParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return expr, nil
+}
+
+// ParseSeries parses series in the "metric{label="value"} value" format, one per line. Empty lines and
+// lines starting with # are ignored.
+func ParseSeries(data string) ([]Sample, error) {
+	var series []Sample
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample, err := parseSeriesLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		series = append(series, sample)
+	}
+	return series, nil
+}
+
+func parseSeriesLine(line string) (Sample, error) {
+	tokens, err := tokenizePromQL(line)
+	if err != nil {
+		return Sample{}, err
+	}
+	p := &promQLParser{tokens: tokens}
+	name, matchers, err := p.parseSelector()
+	if err != nil {
+		return Sample{}, err
+	}
+	labels := map[string]string{}
+	if name != "" {
+		labels["__name__"] = name
+	}
+	for _, m := range matchers {
+		if m.op != "=" {
+			return Sample{}, fmt.Errorf("series labels must use =, got %s", m.op)
+		}
+		labels[m.label] = m.value
+	}
+	value, err := p.parseNumber()
+	if err != nil {
+		return Sample{}, err
+	}
+	if !p.done() {
+		return Sample{}, p.errorf("unexpected %s", p.peek())
+	}
+	return Sample{Labels: labels, Value: value}, nil
+}
+
+type promQLToken struct {
+	kind  string // ident, number, string, or the punctuation itself
+	value string
+}
+
+func (t promQLToken) String() string {
+	if t.kind == "string" {
+		return strconv.Quote(t.value)
+	}
+	return t.value
+}
+
+func tokenizePromQL(query string) ([]promQLToken, error) {
+	var tokens []promQLToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			j := i + 1
+			var value strings.Builder
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, &PromQLParseError{Message: "unterminated string"}
+			}
+			tokens = append(tokens, promQLToken{kind: "string", value: value.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || r == '.' || (r == '-' && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E' ||
+				((runes[j] == '-' || runes[j] == '+') && (runes[j-1] == 'e' || runes[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, promQLToken{kind: "number", value: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == ':':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == ':') {
+				j++
+			}
+			tokens = append(tokens, promQLToken{kind: "ident", value: string(runes[i:j])})
+			i = j
+		case strings.ContainsRune("(){},*", r):
+			tokens = append(tokens, promQLToken{kind: string(r), value: string(r)})
+			i++
+		case r == '=' || r == '!':
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || runes[i+1] == '~') {
+				op += string(runes[i+1])
+			}
+			if op == "!" || op == "==" {
+				return nil, &PromQLParseError{Message: fmt.Sprintf("unexpected character %q", op)}
+			}
+			tokens = append(tokens, promQLToken{kind: op, value: op})
+			i += len(op)
+		default:
+			return nil, &PromQLParseError{Message: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return tokens, nil
+}
+
+type promQLParser struct {
+	tokens []promQLToken
+	pos    int
+}
+
+func (p *promQLParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *promQLParser) peek() promQLToken {
+	if p.done() {
+		return promQLToken{kind: "eof", value: "end of input"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *promQLParser) peekKeyword(keywords ...string) bool {
+	t := p.peek()
+	return t.kind == "ident" && slices.Contains(keywords, t.value)
+}
+
+func (p *promQLParser) expect(kind string) (promQLToken, error) {
+	t := p.peek()
+	if t.kind != kind {
+		return t, p.errorf("expected %q, got %s", kind, t)
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *promQLParser) errorf(format string, args ...interface{}) error {
+	return &PromQLParseError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *promQLParser) parseOr() (promQLExpr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = setOperation("or", lhs, rhs)
+	}
+	return lhs, nil
+}
+
+func (p *promQLParser) parseAnd() (promQLExpr, error) {
+	lhs, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and", "unless") {
+		op := p.peek().value
+		p.pos++
+		rhs, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		lhs = setOperation(op, lhs, rhs)
+	}
+	return lhs, nil
+}
+
+func (p *promQLParser) parseMul() (promQLExpr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "*" {
+		p.pos++
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = multiplication(lhs, rhs)
+	}
+	return lhs, nil
+}
+
+var promQLAggregations = []string{"group", "count", "sum", "min", "max"}
+
+func (p *promQLParser) parseUnary() (promQLExpr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == "number":
+		value, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return func([]Sample) (promQLValue, error) {
+			return promQLValue{scalar: &value, isScalar: true}, nil
+		}, nil
+	case t.kind == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case p.peekKeyword("vector") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == "(":
+		p.pos += 2
+		value, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func([]Sample) (promQLValue, error) {
+			return promQLValue{vector: []Sample{{Labels: map[string]string{}, Value: value}}}, nil
+		}, nil
+	case p.peekKeyword(promQLAggregations...) && p.pos+1 < len(p.tokens) &&
+		(p.tokens[p.pos+1].kind == "(" || p.tokens[p.pos+1].value == "by" || p.tokens[p.pos+1].value == "without"):
+		return p.parseAggregation()
+	case t.kind == "ident" || t.kind == "{":
+		name, matchers, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		return selector(name, matchers), nil
+	default:
+		return nil, p.errorf("unexpected %s", t)
+	}
+}
+
+func (p *promQLParser) parseNumber() (float64, error) {
+	t, err := p.expect("number")
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(t.value, 64)
+	if err != nil {
+		return 0, p.errorf("invalid number %q", t.value)
+	}
+	return value, nil
+}
+
+func (p *promQLParser) parseAggregation() (promQLExpr, error) {
+	op := p.peek().value
+	p.pos++
+
+	var grouping []string
+	var without, hasGrouping bool
+	parseGrouping := func() error {
+		if hasGrouping {
+			return p.errorf("duplicate grouping clause")
+		}
+		hasGrouping = true
+		without = p.peek().value == "without"
+		p.pos++
+		if _, err := p.expect("("); err != nil {
+			return err
+		}
+		for p.peek().kind != ")" {
+			label, err := p.expect("ident")
+			if err != nil {
+				return err
+			}
+			grouping = append(grouping, label.value)
+			if p.peek().kind != "," {
+				break
+			}
+			p.pos++
+		}
+		_, err := p.expect(")")
+		return err
+	}
+
+	if p.peekKeyword("by", "without") {
+		if err := parseGrouping(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if p.peekKeyword("by", "without") {
+		if err := parseGrouping(); err != nil {
+			return nil, err
+		}
+	}
+	return aggregation(op, grouping, without, inner), nil
+}
+
+type labelMatcher struct {
+	label string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m labelMatcher) matches(labels map[string]string) bool {
+	value := labels[m.label]
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+func (p *promQLParser) parseSelector() (string, []labelMatcher, error) {
+	var name string
+	if p.peek().kind == "ident" {
+		name = p.peek().value
+		p.pos++
+	}
+	var matchers []labelMatcher
+	if p.peek().kind == "{" {
+		p.pos++
+		for p.peek().kind != "}" {
+			label, err := p.expect("ident")
+			if err != nil {
+				return "", nil, err
+			}
+			op := p.peek()
+			if op.kind != "=" && op.kind != "!=" && op.kind != "=~" && op.kind != "!~" {
+				return "", nil, p.errorf("expected label matching operator, got %s", op)
+			}
+			p.pos++
+			value, err := p.expect("string")
+			if err != nil {
+				return "", nil, err
+			}
+			matcher := labelMatcher{label: label.value, op: op.kind, value: value.value}
+			if op.kind == "=~" || op.kind == "!~" {
+				if matcher.re, err = regexp.Compile("^(?:" + value.value + ")$"); err != nil {
+					return "", nil, p.errorf("invalid regular expression %q: %v", value.value, err)
+				}
+			}
+			matchers = append(matchers, matcher)
+			if p.peek().kind != "," {
+				break
+			}
+			p.pos++
+		}
+		if _, err := p.expect("}"); err != nil {
+			return "", nil, err
+		}
+	}
+	if name == "" && len(matchers) == 0 {
+		return "", nil, p.errorf("vector selector must contain at least one non-empty matcher")
+	}
+	return name, matchers, nil
+}
+
+func selector(name string, matchers []labelMatcher) promQLExpr {
+	if name != "" {
+		matchers = append(matchers, labelMatcher{label: "__name__", op: "=", value: name})
+	}
+	return func(series []Sample) (promQLValue, error) {
+		result := []Sample{}
+		for _, sample := range series {
+			if !slices.ContainsFunc(matchers, func(m labelMatcher) bool { return !m.matches(sample.Labels) }) {
+				result = append(result, Sample{Labels: maps.Clone(sample.Labels), Value: sample.Value})
+			}
+		}
+		return promQLValue{vector: result}, nil
+	}
+}
+
+func aggregation(op string, grouping []string, without bool, inner promQLExpr) promQLExpr {
+	return func(series []Sample) (promQLValue, error) {
+		value, err := inner(series)
+		if err != nil {
+			return value, err
+		}
+		if value.isScalar {
+			return value, fmt.Errorf("expected type instant vector in aggregation expression, got scalar")
+		}
+
+		groups := map[string]*Sample{}
+		var order []string
+		for _, sample := range value.vector {
+			labels := map[string]string{}
+			if without {
+				for k, v := range sample.Labels {
+					if k != "__name__" && !slices.Contains(grouping, k) {
+						labels[k] = v
+					}
+				}
+			} else {
+				for _, k := range grouping {
+					if v, ok := sample.Labels[k]; ok {
+						labels[k] = v
+					}
+				}
+			}
+			key := labelsKey(labels)
+			group, ok := groups[key]
+			if !ok {
+				initial := sample.Value
+				switch op {
+				case "group", "count":
+					initial = 1
+				}
+				groups[key] = &Sample{Labels: labels, Value: initial}
+				order = append(order, key)
+				continue
+			}
+			switch op {
+			case "count":
+				group.Value++
+			case "sum":
+				group.Value += sample.Value
+			case "min":
+				group.Value = math.Min(group.Value, sample.Value)
+			case "max":
+				group.Value = math.Max(group.Value, sample.Value)
+			}
+		}
+
+		result := []Sample{}
+		for _, key := range order {
+			result = append(result, *groups[key])
+		}
+		return promQLValue{vector: result}, nil
+	}
+}
+
+func multiplication(lhs, rhs promQLExpr) promQLExpr {
+	return func(series []Sample) (promQLValue, error) {
+		l, err := lhs(series)
+		if err != nil {
+			return l, err
+		}
+		r, err := rhs(series)
+		if err != nil {
+			return r, err
+		}
+		switch {
+		case l.isScalar && r.isScalar:
+			value := *l.scalar * *r.scalar
+			return promQLValue{scalar: &value, isScalar: true}, nil
+		case l.isScalar:
+			return promQLValue{vector: scaleVector(r.vector, *l.scalar)}, nil
+		case r.isScalar:
+			return promQLValue{vector: scaleVector(l.vector, *r.scalar)}, nil
+		default:
+			return promQLValue{}, fmt.Errorf("multiplying two instant vectors is not supported")
+		}
+	}
+}
+
+func scaleVector(vector []Sample, factor float64) []Sample {
+	result := []Sample{}
+	for _, sample := range vector {
+		labels := maps.Clone(sample.Labels)
+		delete(labels, "__name__")
+		result = append(result, Sample{Labels: labels, Value: sample.Value * factor})
+	}
+	return result
+}
+
+func setOperation(op string, lhs, rhs promQLExpr) promQLExpr {
+	return func(series []Sample) (promQLValue, error) {
+		l, err := lhs(series)
+		if err != nil {
+			return l, err
+		}
+		r, err := rhs(series)
+		if err != nil {
+			return r, err
+		}
+		if l.isScalar || r.isScalar {
+			return promQLValue{}, fmt.Errorf("set operator %q not allowed in binary scalar expression", op)
+		}
+
+		rhsKeys := map[string]bool{}
+		for _, sample := range r.vector {
+			rhsKeys[signature(sample.Labels)] = true
+		}
+		result := []Sample{}
+		switch op {
+		case "or":
+			lhsKeys := map[string]bool{}
+			for _, sample := range l.vector {
+				lhsKeys[signature(sample.Labels)] = true
+				result = append(result, sample)
+			}
+			for _, sample := range r.vector {
+				if !lhsKeys[signature(sample.Labels)] {
+					result = append(result, sample)
+				}
+			}
+		case "and":
+			for _, sample := range l.vector {
+				if rhsKeys[signature(sample.Labels)] {
+					result = append(result, sample)
+				}
+			}
+		case "unless":
+			for _, sample := range l.vector {
+				if !rhsKeys[signature(sample.Labels)] {
+					result = append(result, sample)
+				}
+			}
+		}
+		return promQLValue{vector: result}, nil
+	}
+}
+
+// signature identifies samples for set operations, which ignore the metric name
+func signature(labels map[string]string) string {
+	withoutName := maps.Clone(labels)
+	delete(withoutName, "__name__")
+	return labelsKey(withoutName)
+}
+
+func labelsKey(labels map[string]string) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		_, _ = fmt.Fprintf(&b, "%s=%q,", k, labels[k])
+	}
+	return b.String()
+}
+
+func sortSamples(samples []Sample) {
+	slices.SortFunc(samples, func(a, b Sample) int {
+		return strings.Compare(labelsKey(a.Labels), labelsKey(b.Labels))
+	})
+}
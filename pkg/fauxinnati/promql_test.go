@@ -0,0 +1,156 @@
+package fauxinnati
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+const testSeries = `
+# infrastructure
+cluster_infrastructure_provider{type="AWS", region="us-east-1"} 1
+cluster_operator_conditions{name="network", condition="Degraded"} 0
+cluster_operator_conditions{name="network", condition="Available"} 1
+cluster_operator_conditions{name="aro", condition="Available"} 1
+up{job="a"} 3
+up{job="b"} 5
+`
+
+func TestEvaluatePromQL(t *testing.T) {
+	series, err := ParseSeries(testSeries)
+	if err != nil {
+		t.Fatalf("failed to parse series: %v", err)
+	}
+	one, six := 1.0, 6.0
+
+	tests := []struct {
+		query          string
+		expectedScalar *float64
+		expectedVector []Sample
+		expectedErr    error
+	}{
+		{
+			query:          "vector(1)",
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 1}},
+		},
+		{
+			query:          "vector(0)",
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 0}},
+		},
+		{
+			query:          "1",
+			expectedScalar: &one,
+		},
+		{
+			query:          "2 * 3",
+			expectedScalar: &six,
+		},
+		{
+			query: `cluster_infrastructure_provider{type="AWS"}`,
+			expectedVector: []Sample{{
+				Labels: map[string]string{"__name__": "cluster_infrastructure_provider", "type": "AWS", "region": "us-east-1"},
+				Value:  1,
+			}},
+		},
+		{
+			query:          `cluster_infrastructure_provider{type!="AWS"}`,
+			expectedVector: []Sample{},
+		},
+		{
+			query:          `group(cluster_infrastructure_provider{type=~"A.*"})`,
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 1}},
+		},
+		{
+			query: `group by (name) (cluster_operator_conditions{condition!~"Deg.*"})`,
+			expectedVector: []Sample{
+				{Labels: map[string]string{"name": "aro"}, Value: 1},
+				{Labels: map[string]string{"name": "network"}, Value: 1},
+			},
+		},
+		{
+			query:          `count(cluster_operator_conditions) by (name)`,
+			expectedVector: []Sample{{Labels: map[string]string{"name": "aro"}, Value: 1}, {Labels: map[string]string{"name": "network"}, Value: 2}},
+		},
+		{
+			query:          `sum without (job) (up)`,
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 8}},
+		},
+		{
+			query:          `max(up)`,
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 5}},
+		},
+		{
+			query:          `min(up)`,
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 3}},
+		},
+		{
+			query:          `group(cluster_operator_conditions{name="missing"}) or 0 * group(cluster_infrastructure_provider)`,
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 0}},
+		},
+		{
+			query:          `group(cluster_operator_conditions{name="aro"}) or 0 * group(cluster_infrastructure_provider)`,
+			expectedVector: []Sample{{Labels: map[string]string{}, Value: 1}},
+		},
+		{
+			query:          `up{job="a"} unless up{job="a"}`,
+			expectedVector: []Sample{},
+		},
+		{
+			query:          `(up and up{job="b"})`,
+			expectedVector: []Sample{{Labels: map[string]string{"__name__": "up", "job": "b"}, Value: 5}},
+		},
+		{
+			query:       "this will fail; muahaha",
+			expectedErr: errors.New(`parse error: unexpected character ';'`),
+		},
+		{
+			query:       "this will fail",
+			expectedErr: errors.New(`parse error: unexpected will`),
+		},
+		{
+			query:       "",
+			expectedErr: errors.New(`parse error: unexpected end of input`),
+		},
+		{
+			query:       "{}",
+			expectedErr: errors.New(`parse error: vector selector must contain at least one non-empty matcher`),
+		},
+		{
+			query:       `up{job="a"`,
+			expectedErr: errors.New(`parse error: expected "}", got end of input`),
+		},
+		{
+			query:       "up * up",
+			expectedErr: errors.New("multiplying two instant vectors is not supported"),
+		},
+		{
+			query:       "group(1)",
+			expectedErr: errors.New("expected type instant vector in aggregation expression, got scalar"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			scalar, vector, err := EvaluatePromQL(tt.query, series)
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedScalar, scalar); diff != "" {
+				t.Errorf("scalar mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedVector, vector); diff != "" {
+				t.Errorf("vector mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSeries_Invalid(t *testing.T) {
+	_, err := ParseSeries("up{job=~\"a\"} 1")
+	if diff := cmp.Diff(errors.New("line 1: series labels must use =, got =~"), err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("error mismatch (-want +got):\n%s", diff)
+	}
+}
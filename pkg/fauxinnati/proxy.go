@@ -0,0 +1,418 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/patrickmn/go-cache"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultUpstream is the graph API of the production OpenShift Update Service
+const DefaultUpstream = "https://api.openshift.com/api/upgrades_info/graph"
+
+// ProxyChannel declares a channel serving the graph of an upstream Cincinnati channel with an Overlay applied
+type ProxyChannel struct {
+	// Name is the name of the served channel
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Upstream is the graph API URL of the upstream Cincinnati, DefaultUpstream when empty
+	Upstream string `yaml:"upstream,omitempty"`
+	// Channel is the upstream channel, Name when empty
+	Channel string `yaml:"channel,omitempty"`
+	// CacheTTL is how long upstream graphs are cached, 5 minutes when zero
+	CacheTTL time.Duration `yaml:"cacheTTL,omitempty"`
+	Overlay  Overlay       `yaml:"overlay,omitempty"`
+	// Fault makes graph requests for the channel fail
+	Fault *FaultProfile `yaml:"fault,omitempty"`
+}
+
+// Overlay is a set of mutations applied to a graph, in the order of the fields
+type Overlay struct {
+	// RemoveNodes removes nodes with the given versions, together with their edges
+	RemoveNodes []string `yaml:"removeNodes,omitempty"`
+	// AddNodes adds nodes that are not in the graph yet
+	AddNodes []OverlayNode `yaml:"addNodes,omitempty"`
+	// AddEdges adds unconditional edges between versions: [from, to]
+	AddEdges []ScenarioEdge `yaml:"addEdges,omitempty"`
+	// BlockEdges turns unconditional edges into conditional edges with the given risks
+	BlockEdges []OverlayBlockedEdge `yaml:"blockEdges,omitempty"`
+	// DropRisks removes conditional update risks with the given names. Conditional edges left without risks
+	// become unconditional edges, unless another conditional edge still has risks for them.
+	DropRisks []string `yaml:"dropRisks,omitempty"`
+}
+
+// OverlayNode is a node added by an overlay. Payload and metadata default to the ones of NewNode.
+type OverlayNode struct {
+	Version  string            `yaml:"version"`
+	Payload  string            `yaml:"payload,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+}
+
+// OverlayBlockedEdge selects unconditional edges to the To version, from the From version or from all versions
+// when From is empty, and the risks they are blocked by
+type OverlayBlockedEdge struct {
+	From  string         `yaml:"from,omitempty"`
+	To    string         `yaml:"to"`
+	Risks []ScenarioRisk `yaml:"risks"`
+}
+
+// LoadProxyChannel reads a YAML (or JSON) proxy channel declaration. Proxy channels without a name are named
+// after their file.
+func LoadProxyChannel(path string) (*ProxyChannel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var proxy ProxyChannel
+	if err := yaml.Unmarshal(data, &proxy); err != nil {
+		return nil, fmt.Errorf("%s: failed to unmarshal proxy channel: %w", path, err)
+	}
+	if proxy.Name == "" {
+		proxy.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := proxy.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &proxy, nil
+}
+
+// Validate checks that the proxy channel declaration is complete and its overlay only references valid versions
+func (p *ProxyChannel) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("proxy channel has no name")
+	}
+	if p.Upstream != "" {
+		if _, err := url.Parse(p.Upstream); err != nil {
+			return fmt.Errorf("proxy channel %s: invalid upstream: %w", p.Name, err)
+		}
+	}
+	if err := p.Overlay.validate(); err != nil {
+		return fmt.Errorf("proxy channel %s: %w", p.Name, err)
+	}
+	if p.Fault != nil {
+		if err := p.Fault.Validate(); err != nil {
+			return fmt.Errorf("proxy channel %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (o *Overlay) validate() error {
+	checkVersion := func(version string) error {
+		if _, err := semver.Parse(version); err != nil {
+			return fmt.Errorf("invalid version %q: %w", version, err)
+		}
+		return nil
+	}
+	for _, version := range o.RemoveNodes {
+		if err := checkVersion(version); err != nil {
+			return err
+		}
+	}
+	for _, node := range o.AddNodes {
+		if err := checkVersion(node.Version); err != nil {
+			return err
+		}
+	}
+	for _, edge := range o.AddEdges {
+		for _, version := range edge {
+			if err := checkVersion(version); err != nil {
+				return err
+			}
+		}
+	}
+	for _, blocked := range o.BlockEdges {
+		if blocked.From != "" {
+			if err := checkVersion(blocked.From); err != nil {
+				return err
+			}
+		}
+		if err := checkVersion(blocked.To); err != nil {
+			return err
+		}
+		if len(blocked.Risks) == 0 {
+			return fmt.Errorf("blocked edge to %s has no risks", blocked.To)
+		}
+		for _, risk := range blocked.Risks {
+			if risk.Name == "" {
+				return fmt.Errorf("blocked edge to %s has a risk without a name", blocked.To)
+			}
+			if err := risk.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of graph with the overlay applied. Mutations referencing versions that are not in the
+// graph are skipped; overlays with invalid versions or risks fail.
+func (o *Overlay) Apply(graph Graph) (Graph, error) {
+	if err := o.validate(); err != nil {
+		return Graph{}, err
+	}
+	return o.apply(graph, logrus.StandardLogger()), nil
+}
+
+// apply applies a validated overlay
+func (o *Overlay) apply(graph Graph, logger logrus.FieldLogger) Graph {
+	removed := map[string]bool{}
+	for _, version := range o.RemoveNodes {
+		removed[version] = true
+	}
+	g := newGraphIndex()
+	for _, node := range graph.Nodes {
+		if !removed[node.Version.String()] {
+			g.addNode(node)
+		}
+	}
+	for _, node := range o.AddNodes {
+		added := NewNode(semver.MustParse(node.Version), "")
+		if node.Payload != "" {
+			added.Image = node.Payload
+		}
+		if node.Metadata != nil {
+			added.Metadata = node.Metadata
+		}
+		if !g.addNode(added) {
+			logger.WithField("version", node.Version).Warning("Overlay node is already in the graph, skipping it")
+		}
+	}
+
+	for _, edge := range graph.Edges {
+		g.addEdge(graph.Nodes[edge[0]].Version.String(), graph.Nodes[edge[1]].Version.String())
+	}
+	for _, edge := range o.AddEdges {
+		g.addEdge(edge[0], edge[1])
+	}
+
+	for _, conditionalEdge := range graph.ConditionalEdges {
+		kept := ConditionalEdge{Edges: []ConditionalUpdate{}, Risks: conditionalEdge.Risks}
+		for _, edge := range conditionalEdge.Edges {
+			if g.index(edge.From) >= 0 && g.index(edge.To) >= 0 {
+				kept.Edges = append(kept.Edges, edge)
+			}
+		}
+		if len(kept.Edges) > 0 {
+			g.conditionalEdges = append(g.conditionalEdges, kept)
+		}
+	}
+
+	for _, blocked := range o.BlockEdges {
+		conditionalEdge := ConditionalEdge{Edges: []ConditionalUpdate{}, Risks: []ConditionalUpdateRisk{}}
+		g.removeEdges(func(edge [2]string) bool {
+			if edge[1] != blocked.To || (blocked.From != "" && edge[0] != blocked.From) {
+				return false
+			}
+			conditionalEdge.Edges = append(conditionalEdge.Edges, ConditionalUpdate{From: edge[0], To: edge[1]})
+			return true
+		})
+		if len(conditionalEdge.Edges) == 0 {
+			continue
+		}
+		for _, risk := range blocked.Risks {
+			conditionalEdge.Risks = append(conditionalEdge.Risks, risk.toRisk())
+		}
+		g.conditionalEdges = append(g.conditionalEdges, conditionalEdge)
+	}
+
+	if len(o.DropRisks) > 0 {
+		var conditionalEdges []ConditionalEdge
+		var unconditional []ConditionalUpdate
+		for _, conditionalEdge := range g.conditionalEdges {
+			risks := slices.DeleteFunc(slices.Clone(conditionalEdge.Risks), func(risk ConditionalUpdateRisk) bool {
+				return slices.Contains(o.DropRisks, risk.Name)
+			})
+			if len(risks) > 0 {
+				conditionalEdges = append(conditionalEdges, ConditionalEdge{Edges: conditionalEdge.Edges, Risks: risks})
+				continue
+			}
+			unconditional = append(unconditional, conditionalEdge.Edges...)
+		}
+		g.conditionalEdges = conditionalEdges
+		// AIDEV-NOTE: The same edge can be listed by several conditional edges; clients merge their risks, so an
+		// edge stays conditional while any of them keeps a risk
+		for _, edge := range unconditional {
+			if !slices.ContainsFunc(conditionalEdges, func(conditionalEdge ConditionalEdge) bool {
+				return slices.Contains(conditionalEdge.Edges, edge)
+			}) {
+				g.addEdge(edge.From, edge.To)
+			}
+		}
+	}
+
+	return g.graph()
+}
+
+// AIDEV-NOTE: graphIndex keeps nodes and edges indexed so overlays stay linear in the size of upstream graphs
+// Modify nodes and edges only through its methods, which keep versions and edgeSet in sync.
+
+// graphIndex is a graph with edges addressed by versions, used to apply overlays and admin edits
+type graphIndex struct {
+	nodes            []Node
+	edges            [][2]string
+	conditionalEdges []ConditionalEdge
+
+	// versions maps versions to their index in nodes
+	versions map[string]int
+	// edgeSet holds the edges
+	edgeSet map[[2]string]bool
+}
+
+func newGraphIndex() *graphIndex {
+	return &graphIndex{versions: map[string]int{}, edgeSet: map[[2]string]bool{}}
+}
+
+// indexGraph indexes the nodes, edges and conditional edges of a graph with valid edges
+func indexGraph(graph Graph) *graphIndex {
+	g := newGraphIndex()
+	for _, node := range graph.Nodes {
+		g.addNode(node)
+	}
+	for _, edge := range graph.Edges {
+		g.addEdge(graph.Nodes[edge[0]].Version.String(), graph.Nodes[edge[1]].Version.String())
+	}
+	g.conditionalEdges = slices.Clone(graph.ConditionalEdges)
+	return g
+}
+
+func (g *graphIndex) index(version string) int {
+	if index, ok := g.versions[version]; ok {
+		return index
+	}
+	return -1
+}
+
+// addNode adds the node unless its version is already in the graph, and reports whether it was added
+func (g *graphIndex) addNode(node Node) bool {
+	version := node.Version.String()
+	if _, ok := g.versions[version]; ok {
+		return false
+	}
+	g.versions[version] = len(g.nodes)
+	g.nodes = append(g.nodes, node)
+	return true
+}
+
+// removeNode removes the node of the version together with its unconditional edges
+func (g *graphIndex) removeNode(version string) {
+	g.nodes = slices.DeleteFunc(g.nodes, func(node Node) bool { return node.Version.String() == version })
+	clear(g.versions)
+	for i, node := range g.nodes {
+		g.versions[node.Version.String()] = i
+	}
+	g.removeEdges(func(edge [2]string) bool { return edge[0] == version || edge[1] == version })
+}
+
+func (g *graphIndex) hasEdge(edge [2]string) bool {
+	return g.edgeSet[edge]
+}
+
+// addEdge adds an edge between versions present in the graph, unless it already exists
+func (g *graphIndex) addEdge(from, to string) {
+	edge := [2]string{from, to}
+	if g.index(from) < 0 || g.index(to) < 0 || g.edgeSet[edge] {
+		return
+	}
+	g.edgeSet[edge] = true
+	g.edges = append(g.edges, edge)
+}
+
+// removeEdges removes the matching unconditional edges and reports how many were removed
+func (g *graphIndex) removeEdges(matches func([2]string) bool) int {
+	edges := len(g.edges)
+	g.edges = slices.DeleteFunc(g.edges, func(edge [2]string) bool {
+		if !matches(edge) {
+			return false
+		}
+		delete(g.edgeSet, edge)
+		return true
+	})
+	return edges - len(g.edges)
+}
+
+func (g *graphIndex) graph() Graph {
+	graph := Graph{
+		Nodes:            append([]Node{}, g.nodes...),
+		Edges:            make([]Edge, 0, len(g.edges)),
+		ConditionalEdges: append([]ConditionalEdge{}, g.conditionalEdges...),
+	}
+	for _, edge := range g.edges {
+		graph.Edges = append(graph.Edges, Edge{g.versions[edge[0]], g.versions[edge[1]]})
+	}
+	return graph
+}
+
+// RegisterProxyChannel serves a channel with the graph of an upstream channel, fetched with the server's
+// retrying client, with the overlay applied and cached
+func (s *Server) RegisterProxyChannel(proxy *ProxyChannel) error {
+	if err := proxy.Validate(); err != nil {
+		return err
+	}
+	upstream := proxy.Upstream
+	if upstream == "" {
+		upstream = DefaultUpstream
+	}
+	upstreamChannel := proxy.Channel
+	if upstreamChannel == "" {
+		upstreamChannel = proxy.Name
+	}
+	ttl := proxy.CacheTTL
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+	description := proxy.Description
+	if description == "" {
+		description = fmt.Sprintf("Graph of the %s channel of %s with an overlay applied.", upstreamChannel, upstream)
+	}
+
+	graphs := cache.New(ttl, 2*ttl)
+	return s.RegisterChannel(Channel{
+		Name:        proxy.Name,
+		Description: description,
+		Fault:       proxy.Fault,
+		Remote:      true,
+		Generate: func(queriedVersion semver.Version, arch string, _ string) Graph {
+			// The overlaid graph is cached; served graphs are never modified
+			key := "graph-" + arch
+			if cached, found := graphs.Get(key); found {
+				return cached.(Graph)
+			}
+			graph, err := s.fetchUpstreamGraph(upstream, upstreamChannel, queriedVersion, arch)
+			if err != nil {
+				s.logger.WithError(err).WithField("upstream", upstream).WithField("channel", upstreamChannel).Warning("Failed to fetch upstream graph")
+				return s.generateEmptyGraph(fmt.Sprintf("failed to fetch the %s channel from upstream", upstreamChannel))
+			}
+			graph = proxy.Overlay.apply(graph, s.logger)
+			graphs.Set(key, graph, cache.DefaultExpiration)
+			return graph
+		},
+	})
+}
+
+func (s *Server) fetchUpstreamGraph(upstream, channel string, queriedVersion semver.Version, arch string) (Graph, error) {
+	params := url.Values{"channel": {channel}, "version": {queriedVersion.String()}}
+	if arch != "" {
+		params.Set("arch", arch)
+	}
+	graph, err := fetchGraph(s.client, upstream, params)
+	if err != nil {
+		return Graph{}, err
+	}
+	for i, edge := range graph.Edges {
+		for _, index := range edge {
+			if index < 0 || index >= len(graph.Nodes) {
+				return Graph{}, fmt.Errorf("edge %d of the upstream graph points to nonexistent node %d", i, index)
+			}
+		}
+	}
+	return graph, nil
+}
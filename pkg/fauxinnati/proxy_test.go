@@ -0,0 +1,259 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func proxyTestGraph() Graph {
+	return Graph{
+		Nodes: []Node{
+			NewNode(semver.MustParse("4.17.0"), "stable-4.17"),
+			NewNode(semver.MustParse("4.17.1"), "stable-4.17"),
+			NewNode(semver.MustParse("4.17.2"), "stable-4.17"),
+			NewNode(semver.MustParse("4.17.3"), "stable-4.17"),
+		},
+		Edges: []Edge{{0, 1}, {0, 2}, {1, 2}},
+		ConditionalEdges: []ConditionalEdge{
+			{
+				Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.3"}, {From: "4.17.1", To: "4.17.3"}},
+				Risks: []ConditionalUpdateRisk{
+					{Name: "RiskA", URL: "https://example.com/a", Message: "A", MatchingRules: []MatchingRule{{Type: "Always"}}},
+					{Name: "RiskB", URL: "https://example.com/b", Message: "B", MatchingRules: []MatchingRule{{Type: "Always"}}},
+				},
+			},
+		},
+	}
+}
+
+func TestOverlay_Apply(t *testing.T) {
+	risk := ScenarioRisk{Name: "Blocked", URL: "https://example.com/blocked", Message: "Blocked", MatchingRules: []ScenarioMatchingRule{{Type: "Always"}}}
+	testCases := []struct {
+		name        string
+		graph       func() Graph
+		overlay     Overlay
+		expected    func(Graph) Graph
+		expectedErr error
+	}{
+		{
+			name:     "empty overlay keeps the graph",
+			expected: func(g Graph) Graph { return g },
+		},
+		{
+			name:    "removed node takes its edges with it",
+			overlay: Overlay{RemoveNodes: []string{"4.17.1"}},
+			expected: func(g Graph) Graph {
+				g.Nodes = []Node{g.Nodes[0], g.Nodes[2], g.Nodes[3]}
+				g.Edges = []Edge{{0, 1}}
+				g.ConditionalEdges[0].Edges = g.ConditionalEdges[0].Edges[:1]
+				return g
+			},
+		},
+		{
+			name:    "removing all targets of a conditional edge drops it",
+			overlay: Overlay{RemoveNodes: []string{"4.17.3"}},
+			expected: func(g Graph) Graph {
+				g.Nodes = g.Nodes[:3]
+				g.ConditionalEdges = []ConditionalEdge{}
+				return g
+			},
+		},
+		{
+			name: "added node and edges",
+			overlay: Overlay{
+				AddNodes: []OverlayNode{{Version: "4.17.4", Payload: "quay.io/openshift-release-dev/ocp-release@sha256:1234"}, {Version: "4.17.0"}},
+				AddEdges: []ScenarioEdge{{"4.17.2", "4.17.4"}, {"4.17.0", "4.17.1"}, {"4.17.2", "4.17.99"}},
+			},
+			expected: func(g Graph) Graph {
+				added := NewNode(semver.MustParse("4.17.4"), "")
+				added.Image = "quay.io/openshift-release-dev/ocp-release@sha256:1234"
+				g.Nodes = append(g.Nodes, added)
+				g.Edges = append(g.Edges, Edge{2, 4})
+				return g
+			},
+		},
+		{
+			name: "blocked edges become conditional",
+			overlay: Overlay{BlockEdges: []OverlayBlockedEdge{
+				{To: "4.17.2", Risks: []ScenarioRisk{risk}},
+				{From: "4.17.0", To: "4.17.3", Risks: []ScenarioRisk{risk}},
+			}},
+			expected: func(g Graph) Graph {
+				g.Edges = []Edge{{0, 1}}
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{
+					Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.2"}, {From: "4.17.1", To: "4.17.2"}},
+					Risks: []ConditionalUpdateRisk{risk.toRisk()},
+				})
+				return g
+			},
+		},
+		{
+			name:    "dropping all risks makes edges unconditional",
+			overlay: Overlay{DropRisks: []string{"RiskA", "RiskB"}},
+			expected: func(g Graph) Graph {
+				g.Edges = append(g.Edges, Edge{0, 3}, Edge{1, 3})
+				g.ConditionalEdges = []ConditionalEdge{}
+				return g
+			},
+		},
+		{
+			name: "edges with risks left in another conditional edge stay conditional",
+			graph: func() Graph {
+				g := proxyTestGraph()
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{
+					Edges: []ConditionalUpdate{{From: "4.17.0", To: "4.17.3"}},
+					Risks: []ConditionalUpdateRisk{{Name: "RiskC", URL: "https://example.com/c", Message: "C", MatchingRules: []MatchingRule{{Type: "Always"}}}},
+				})
+				return g
+			},
+			overlay: Overlay{DropRisks: []string{"RiskA", "RiskB"}},
+			expected: func(g Graph) Graph {
+				g.Edges = append(g.Edges, Edge{1, 3})
+				g.ConditionalEdges = g.ConditionalEdges[1:]
+				return g
+			},
+		},
+		{
+			name:        "invalid version of an added node",
+			overlay:     Overlay{AddNodes: []OverlayNode{{Version: "4.17"}}},
+			expectedErr: errors.New(`invalid version "4.17": No Major.Minor.Patch elements found`),
+		},
+		{
+			name:    "dropping some risks keeps edges conditional",
+			overlay: Overlay{DropRisks: []string{"RiskA"}},
+			expected: func(g Graph) Graph {
+				g.ConditionalEdges[0].Risks = g.ConditionalEdges[0].Risks[1:]
+				return g
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			graph := proxyTestGraph
+			if tc.graph != nil {
+				graph = tc.graph
+			}
+			got, err := tc.overlay.Apply(graph())
+			if diff := cmp.Diff(tc.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("error mismatch (-want +got):\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.expected(graph()), got); diff != "" {
+				t.Errorf("unexpected graph (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProxyChannel_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		proxy    ProxyChannel
+		expected error
+	}{
+		{
+			name:  "valid",
+			proxy: ProxyChannel{Name: "stable-4.17", Overlay: Overlay{RemoveNodes: []string{"4.17.1"}}},
+		},
+		{
+			name:     "no name",
+			expected: errors.New("proxy channel has no name"),
+		},
+		{
+			name:     "invalid version",
+			proxy:    ProxyChannel{Name: "stable-4.17", Overlay: Overlay{AddEdges: []ScenarioEdge{{"4.17.0", "latest"}}}},
+			expected: errors.New(`proxy channel stable-4.17: invalid version "latest": No Major.Minor.Patch elements found`),
+		},
+		{
+			name:     "blocked edge without risks",
+			proxy:    ProxyChannel{Name: "stable-4.17", Overlay: Overlay{BlockEdges: []OverlayBlockedEdge{{To: "4.17.1"}}}},
+			expected: errors.New("proxy channel stable-4.17: blocked edge to 4.17.1 has no risks"),
+		},
+		{
+			name: "blocked edge with an invalid risk",
+			proxy: ProxyChannel{Name: "stable-4.17", Overlay: Overlay{BlockEdges: []OverlayBlockedEdge{
+				{To: "4.17.1", Risks: []ScenarioRisk{{Name: "Risk"}}},
+			}}},
+			expected: errors.New("proxy channel stable-4.17: risk Risk has no matching rules"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.proxy.Validate()
+			if diff := cmp.Diff(tc.expected, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_RegisterProxyChannel(t *testing.T) {
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if channel := r.URL.Query().Get("channel"); channel != "stable-4.17" {
+			http.Error(w, "unknown channel "+channel, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(proxyTestGraph())
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "patched-4.17.yaml")
+	declaration := `upstream: ` + upstream.URL + `
+channel: stable-4.17
+overlay:
+  removeNodes: [4.17.0]
+  dropRisks: [RiskA, RiskB]
+`
+	if err := os.WriteFile(path, []byte(declaration), 0644); err != nil {
+		t.Fatalf("failed to write proxy channel: %v", err)
+	}
+	proxy, err := LoadProxyChannel(path)
+	if err != nil {
+		t.Fatalf("failed to load proxy channel: %v", err)
+	}
+
+	server := NewServer()
+	if err := server.RegisterProxyChannel(proxy); err != nil {
+		t.Fatalf("failed to register proxy channel: %v", err)
+	}
+	broken := &ProxyChannel{Name: "broken", Upstream: upstream.URL}
+	if err := server.RegisterProxyChannel(broken); err != nil {
+		t.Fatalf("failed to register proxy channel: %v", err)
+	}
+
+	expected := proxyTestGraph()
+	expected.Nodes = expected.Nodes[1:]
+	expected.Edges = []Edge{{0, 1}, {0, 2}}
+	expected.ConditionalEdges = []ConditionalEdge{}
+	for range 2 {
+		if diff := cmp.Diff(expected, queryGraph(t, server, "channel=patched-4.17&version=4.17.1")); diff != "" {
+			t.Errorf("unexpected graph (-want +got):\n%s", diff)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected the upstream graph to be fetched once, got %d requests", n)
+	}
+
+	graph := queryGraph(t, server, "channel=broken&version=4.17.1")
+	if graph.Error == "" || len(graph.Nodes) != 0 {
+		t.Errorf("expected an empty graph with an error for a failed upstream fetch, got %+v", graph)
+	}
+}
@@ -1,72 +1,46 @@
 package fauxinnati
 
 import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/blang/semver/v4"
-	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
-type prereleaseDigestResolver struct {
-	cache Cache
-}
-
-func (r *prereleaseDigestResolver) getRepository() string {
-	return "quay.io/openshift-release-dev/ocp-release"
-}
-
-func (r *prereleaseDigestResolver) getDigest(client Client, tag string) (string, error) {
-	key := fmt.Sprintf("%s-%s", "getDigest", tag)
-	if data, found := r.cache.Get(key); found {
-		logrus.WithField("key", key).Debug("Found digest in cache")
-		return data.(string), nil
-	}
-	logrus.WithField("key", key).Debug("Getting digest from quay.io ...")
-	req, err := http.NewRequest(http.MethodHead, fmt.Sprintf("https://quay.io/v2/openshift-release-dev/ocp-release/manifests/%s", tag), nil)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer func() {
-		_ = res.Body.Close()
-	}()
-
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code %d for url %s", res.StatusCode, req.URL)
-	}
-
-	digest := res.Header.Get("docker-content-digest")
-	if digest == "" {
-		return "", fmt.Errorf("missing digest for url %s", req.URL)
-	}
-	r.cache.Set(key, digest, cache.NoExpiration)
-	return digest, nil
+// CandidatesSource provides the versions in the candidate-X.Y channels of the cincinnati-graph-data repository
+type CandidatesSource interface {
+	Candidates(client Client, major, minor uint64) ([]semver.Version, error)
 }
 
 type candidatesGetter struct {
-	cache Cache
+	cache  Cache
+	source CandidatesSource
+	ttl    time.Duration
+	logger logrus.FieldLogger
 }
 
 func (g *candidatesGetter) candidates(client Client, major, minor uint64) ([]semver.Version, error) {
 	key := fmt.Sprintf("candidates-%d.%d", major, minor)
 	if data, found := g.cache.Get(key); found {
-		logrus.WithField("key", key).Info("Found candidates in cache")
+		g.logger.WithField("key", key).Info("Found candidates in cache")
 		return data.([]semver.Version), nil
 	}
-	logrus.WithField("key", key).Debug("Getting candidates from github.com ...")
-	versions, err := getVersions(client, major, minor)
+	g.logger.WithField("major.minor", fmt.Sprintf("%d.%d", major, minor)).Debug("Getting candidates ...")
+	versions, err := g.source.Candidates(client, major, minor)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +50,7 @@ func (g *candidatesGetter) candidates(client Client, major, minor uint64) ([]sem
 	sort.Slice(versions, func(i, j int) bool {
 		return versions[i].LT(versions[j])
 	})
-	g.cache.Set(key, versions, 60*time.Minute)
+	g.cache.Set(key, versions, g.ttl)
 	return versions, nil
 }
 
@@ -88,8 +62,15 @@ func (g *candidatesGetter) latestCandidate(client Client, major, minor uint64) (
 	return versions[len(versions)-1], nil
 }
 
-func getVersions(client Client, major, minor uint64) ([]semver.Version, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://raw.githubusercontent.com/openshift/cincinnati-graph-data/refs/heads/master/channels/candidate-%d.%d.yaml", major, minor), nil)
+type gitHubCandidatesSource struct{}
+
+// NewGitHubCandidatesSource reads candidate channels from the master branch of openshift/cincinnati-graph-data on GitHub
+func NewGitHubCandidatesSource() CandidatesSource {
+	return &gitHubCandidatesSource{}
+}
+
+func (s *gitHubCandidatesSource) Candidates(client Client, major, minor uint64) ([]semver.Version, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://raw.githubusercontent.com/openshift/cincinnati-graph-data/refs/heads/master/%s", candidatesPath(major, minor)), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +90,98 @@ func getVersions(client Client, major, minor uint64) ([]semver.Version, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseCandidates(data)
+}
+
+type graphDataDirCandidatesSource struct {
+	dir string
+}
+
+// NewGraphDataDirCandidatesSource reads candidate channels from a local checkout of the cincinnati-graph-data repository
+func NewGraphDataDirCandidatesSource(dir string) CandidatesSource {
+	return &graphDataDirCandidatesSource{dir: dir}
+}
+
+func (s *graphDataDirCandidatesSource) Candidates(_ Client, major, minor uint64) ([]semver.Version, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(candidatesPath(major, minor))))
+	if err != nil {
+		return nil, err
+	}
+	return parseCandidates(data)
+}
+
+type graphDataTarballCandidatesSource struct {
+	channels map[string][]byte
+}
+
+// NewGraphDataTarballCandidatesSource reads candidate channels from a (optionally gzipped) tarball of the
+// cincinnati-graph-data repository. The channels directory may be at the root of the tarball or in a single
+// top-level directory, like in the tarballs GitHub serves for a commit.
+func NewGraphDataTarballCandidatesSource(tarball string) (CandidatesSource, error) {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", tarball, err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		r = gz
+	}
+
+	source := &graphDataTarballCandidatesSource{channels: map[string][]byte{}}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", tarball, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		dir := path.Dir(name)
+		if dir != "channels" && (path.Base(dir) != "channels" || strings.Contains(path.Dir(dir), "/")) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from %s: %w", name, tarball, err)
+		}
+		source.channels[path.Base(name)] = data
+	}
+	if len(source.channels) == 0 {
+		return nil, fmt.Errorf("no channels found in %s", tarball)
+	}
+	return source, nil
+}
+
+func (s *graphDataTarballCandidatesSource) Candidates(_ Client, major, minor uint64) ([]semver.Version, error) {
+	name := path.Base(candidatesPath(major, minor))
+	data, ok := s.channels[name]
+	if !ok {
+		return nil, fmt.Errorf("channels/%s not found in the graph-data tarball", name)
+	}
+	return parseCandidates(data)
+}
+
+func candidatesPath(major, minor uint64) string {
+	return fmt.Sprintf("channels/candidate-%d.%d.yaml", major, minor)
+}
+
+func parseCandidates(data []byte) ([]semver.Version, error) {
 	var candidatesData CandidatesData
 	if err := yaml.Unmarshal(data, &candidatesData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal data (%s): %w", string(data), err)
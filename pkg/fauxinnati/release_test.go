@@ -0,0 +1,139 @@
+package fauxinnati
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func writeGraphDataTarball(t *testing.T, gzipped bool, files map[string]string) string {
+	t.Helper()
+	tarball := filepath.Join(t.TempDir(), "graph-data.tar")
+	f, err := os.Create(tarball)
+	if err != nil {
+		t.Fatalf("failed to create tarball: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var w io.Writer = f
+	if gzipped {
+		gz := gzip.NewWriter(f)
+		defer func() {
+			_ = gz.Close()
+		}()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer func() {
+		_ = tw.Close()
+	}()
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("failed to write tarball: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write tarball: %v", err)
+		}
+	}
+	return tarball
+}
+
+func TestCandidatesSources(t *testing.T) {
+	candidates, err := os.ReadFile("testdata/graph-data/channels/candidate-4.22.yaml")
+	if err != nil {
+		t.Fatalf("failed to read candidates: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		source      func(t *testing.T) CandidatesSource
+		minor       uint64
+		expected    []semver.Version
+		expectedErr error
+	}{
+		{
+			name: "graph-data directory",
+			source: func(t *testing.T) CandidatesSource {
+				return NewGraphDataDirCandidatesSource("testdata/graph-data")
+			},
+			minor: 22,
+			expected: []semver.Version{
+				semver.MustParse("4.22.0-ec.0"),
+				semver.MustParse("4.22.0-ec.1"),
+				semver.MustParse("4.22.0-ec.2"),
+				semver.MustParse("4.22.0-ec.3"),
+			},
+		},
+		{
+			name: "graph-data directory without the channel",
+			source: func(t *testing.T) CandidatesSource {
+				return NewGraphDataDirCandidatesSource("testdata/graph-data")
+			},
+			minor:       21,
+			expectedErr: errors.New("open testdata/graph-data/channels/candidate-4.21.yaml: no such file or directory"),
+		},
+		{
+			name: "gzipped tarball with a top-level directory",
+			source: func(t *testing.T) CandidatesSource {
+				source, err := NewGraphDataTarballCandidatesSource(writeGraphDataTarball(t, true, map[string]string{
+					"cincinnati-graph-data-0123abc/channels/candidate-4.22.yaml": string(candidates),
+					"cincinnati-graph-data-0123abc/channels/stable-4.22.yaml":    "versions: []",
+				}))
+				if err != nil {
+					t.Fatalf("failed to create source: %v", err)
+				}
+				return source
+			},
+			minor: 22,
+			expected: []semver.Version{
+				semver.MustParse("4.22.0-ec.0"),
+				semver.MustParse("4.22.0-ec.1"),
+				semver.MustParse("4.22.0-ec.2"),
+				semver.MustParse("4.22.0-ec.3"),
+			},
+		},
+		{
+			name: "plain tarball without the channel",
+			source: func(t *testing.T) CandidatesSource {
+				source, err := NewGraphDataTarballCandidatesSource(writeGraphDataTarball(t, false, map[string]string{
+					"channels/candidate-4.22.yaml": string(candidates),
+				}))
+				if err != nil {
+					t.Fatalf("failed to create source: %v", err)
+				}
+				return source
+			},
+			minor:       21,
+			expectedErr: errors.New("channels/candidate-4.21.yaml not found in the graph-data tarball"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, err := tt.source(t).Candidates(nil, 4, tt.minor)
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expected, versions); diff != "" {
+				t.Errorf("versions mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewGraphDataTarballCandidatesSource_NoChannels(t *testing.T) {
+	tarball := writeGraphDataTarball(t, true, map[string]string{"README.md": "# graph data"})
+	if _, err := NewGraphDataTarballCandidatesSource(tarball); err == nil {
+		t.Errorf("expected a tarball without channels to be rejected")
+	}
+}
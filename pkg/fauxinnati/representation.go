@@ -0,0 +1,193 @@
+package fauxinnati
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Media types of the graph representations served by the graph endpoint
+const (
+	// MediaTypeJSON is the Cincinnati graph, requested by the CVO and served by default
+	MediaTypeJSON = "application/json"
+	// MediaTypeYAML is the Cincinnati graph converted to YAML
+	MediaTypeYAML = "application/yaml"
+	// MediaTypeText is the graph rendered as text, like on the landing page
+	MediaTypeText = "text/plain"
+	// MediaTypeGraphviz is the graph in the Graphviz DOT language, e.g. for `dot -Tsvg`
+	MediaTypeGraphviz = "text/vnd.graphviz"
+)
+
+// graphMediaTypes are the served graph media types, in the order of preference when the client accepts several
+// equally
+var graphMediaTypes = []string{MediaTypeJSON, MediaTypeYAML, MediaTypeText, MediaTypeGraphviz}
+
+// negotiateMediaType picks the graph media type to serve for the Accept header value. Requests without an Accept
+// header get JSON. It returns false when none of the served media types is acceptable.
+func negotiateMediaType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeJSON, true
+	}
+
+	type mediaRange struct {
+		typ, subtype string
+		quality      float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, quality: quality})
+	}
+
+	best, bestQuality := "", 0.0
+	for _, offered := range graphMediaTypes {
+		typ, subtype, _ := strings.Cut(offered, "/")
+		// The most specific range matching the offered type decides its quality
+		quality, specificity := 0.0, -1
+		for _, r := range ranges {
+			var matched int
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				matched = 2
+			case r.typ == typ && r.subtype == "*":
+				matched = 1
+			case r.typ == "*" && r.subtype == "*":
+				matched = 0
+			default:
+				continue
+			}
+			if matched > specificity {
+				quality, specificity = r.quality, matched
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = offered, quality
+		}
+	}
+	return best, best != ""
+}
+
+// encodeGraph renders the graph served for channel in the media type
+func (s *Server) encodeGraph(graph Graph, mediaType, channel string) ([]byte, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetIndent("", "  ") // Pretty print the JSON response
+	if err := encoder.Encode(graph); err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case MediaTypeYAML:
+		return jsonToYAML(body.Bytes())
+	case MediaTypeText:
+		text := plainTextReplacer.Replace(s.graphToASCII(graph))
+		if graph.Error != "" {
+			text = fmt.Sprintf("Error: %s\n\n%s", graph.Error, text)
+		}
+		return []byte(strings.TrimSuffix(text, "\n") + "\n"), nil
+	case MediaTypeGraphviz:
+		return graphToDOT(graph, channel), nil
+	}
+	return body.Bytes(), nil
+}
+
+// plainTextReplacer removes the markup the landing page uses to highlight versions in graphToASCII renderings
+var plainTextReplacer = strings.NewReplacer("<strong>", "", "</strong>", "")
+
+// jsonToYAML converts JSON to block-style YAML, keeping the order of object keys. Sequences of scalars, like
+// edges, stay in the flow style.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	var restyle func(node *yaml.Node)
+	restyle = func(node *yaml.Node) {
+		node.Style = 0
+		scalars := len(node.Content) > 0
+		for _, child := range node.Content {
+			restyle(child)
+			scalars = scalars && child.Kind == yaml.ScalarNode
+		}
+		if node.Kind == yaml.SequenceNode && scalars {
+			node.Style = yaml.FlowStyle
+		}
+	}
+	restyle(&document)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DecodeGraph decodes a graph in the JSON representation, or in the YAML representation served for MediaTypeYAML
+func DecodeGraph(data []byte) (Graph, error) {
+	// YAML is a superset of JSON, so both are decoded as YAML and converted to JSON to use the JSON field names
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return Graph{}, fmt.Errorf("failed to decode graph: %w", err)
+	}
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return Graph{}, fmt.Errorf("failed to decode graph: %w", err)
+	}
+	var graph Graph
+	if err := json.Unmarshal(converted, &graph); err != nil {
+		return Graph{}, fmt.Errorf("failed to decode graph: %w", err)
+	}
+	return graph, nil
+}
+
+// graphToDOT renders the graph in the Graphviz DOT language. Conditional edges are dashed and labeled with the
+// names of their risks.
+func graphToDOT(graph Graph, channel string) []byte {
+	var out strings.Builder
+	fmt.Fprintf(&out, "digraph %q {\n", channel)
+	out.WriteString("  node [shape=box];\n")
+	if graph.Error != "" {
+		fmt.Fprintf(&out, "  label=%q;\n", "Error: "+graph.Error)
+	}
+	indices := make(map[string]int, len(graph.Nodes))
+	for i, node := range graph.Nodes {
+		indices[node.Version.String()] = i
+		fmt.Fprintf(&out, "  n%d [label=%q];\n", i, node.Version.String())
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&out, "  n%d -> n%d;\n", edge[0], edge[1])
+	}
+	for _, conditionalEdge := range graph.ConditionalEdges {
+		risks := make([]string, 0, len(conditionalEdge.Risks))
+		for _, risk := range conditionalEdge.Risks {
+			risks = append(risks, risk.Name)
+		}
+		for _, edge := range conditionalEdge.Edges {
+			from, fromOK := indices[edge.From]
+			to, toOK := indices[edge.To]
+			if !fromOK || !toOK {
+				continue
+			}
+			fmt.Fprintf(&out, "  n%d -> n%d [style=dashed, label=%q];\n", from, to, strings.Join(risks, ", "))
+		}
+	}
+	out.WriteString("}\n")
+	return []byte(out.String())
+}
@@ -0,0 +1,198 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		name       string
+		accept     string
+		expected   string
+		acceptable bool
+	}{
+		{
+			name:       "no Accept header gets JSON",
+			expected:   MediaTypeJSON,
+			acceptable: true,
+		},
+		{
+			name:       "CVO gets JSON",
+			accept:     "application/json",
+			expected:   MediaTypeJSON,
+			acceptable: true,
+		},
+		{
+			name:       "any media type gets JSON",
+			accept:     "*/*",
+			expected:   MediaTypeJSON,
+			acceptable: true,
+		},
+		{
+			name:       "exact media type is preferred over wildcards",
+			accept:     "*/*;q=0.8, text/vnd.graphviz",
+			expected:   MediaTypeGraphviz,
+			acceptable: true,
+		},
+		{
+			name:       "text wildcard gets plain text",
+			accept:     "text/*",
+			expected:   MediaTypeText,
+			acceptable: true,
+		},
+		{
+			name:       "highest quality wins",
+			accept:     "application/json;q=0.5, application/yaml;q=0.9",
+			expected:   MediaTypeYAML,
+			acceptable: true,
+		},
+		{
+			name:       "excluded media types are not served",
+			accept:     "application/json;q=0, */*",
+			expected:   MediaTypeYAML,
+			acceptable: true,
+		},
+		{
+			name:   "unsupported media type is not acceptable",
+			accept: "application/xml",
+		},
+		{
+			name:   "malformed Accept header is not acceptable",
+			accept: "json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaType, acceptable := negotiateMediaType(tt.accept)
+			if mediaType != tt.expected || acceptable != tt.acceptable {
+				t.Errorf("expected (%q, %t), got (%q, %t)", tt.expected, tt.acceptable, mediaType, acceptable)
+			}
+		})
+	}
+}
+
+func TestServer_handleGraph_Representations(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    string
+		mediaType string
+	}{
+		{
+			name:      "yaml",
+			accept:    "application/yaml",
+			mediaType: MediaTypeYAML,
+		},
+		{
+			name:      "text",
+			accept:    "text/plain",
+			mediaType: MediaTypeText,
+		},
+		{
+			name:      "dot",
+			accept:    "text/vnd.graphviz",
+			mediaType: MediaTypeGraphviz,
+		},
+	}
+
+	server := NewServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?channel=risks-matching&version=4.17.5", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			server.mux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.mediaType {
+				t.Errorf("expected Content-Type %q, got %q", tt.mediaType, contentType)
+			}
+			testhelper.CompareWithFixture(t, w.Body.String())
+		})
+	}
+}
+
+func TestServer_handleGraph_YAMLMatchesJSON(t *testing.T) {
+	server := NewServer()
+	serve := func(accept string) []byte {
+		req := httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?channel=smoke-test&version=4.17.5", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		server.mux.ServeHTTP(w, req)
+		return w.Body.Bytes()
+	}
+
+	var fromJSON, fromYAML any
+	if err := json.Unmarshal(serve(MediaTypeJSON), &fromJSON); err != nil {
+		t.Fatalf("failed to unmarshal JSON graph: %v", err)
+	}
+	if err := yaml.Unmarshal(serve(MediaTypeYAML), &fromYAML); err != nil {
+		t.Fatalf("failed to unmarshal YAML graph: %v", err)
+	}
+	// YAML decodes integers as int, JSON as float64
+	normalized, err := json.Marshal(fromYAML)
+	if err != nil {
+		t.Fatalf("failed to marshal YAML graph: %v", err)
+	}
+	fromYAML = nil
+	if err := json.Unmarshal(normalized, &fromYAML); err != nil {
+		t.Fatalf("failed to unmarshal normalized YAML graph: %v", err)
+	}
+	if diff := cmp.Diff(fromJSON, fromYAML); diff != "" {
+		t.Errorf("YAML graph differs from the JSON graph (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_handleGraph_NotAcceptable(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?channel=simple&version=4.17.5", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+	NewServer().mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected status %d, got %d", http.StatusNotAcceptable, w.Code)
+	}
+	var actual ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	expected := ErrorResponse{
+		Kind:  ErrorKindInvalidContentType,
+		Value: "invalid content type requested, supported: application/json, application/yaml, text/plain, text/vnd.graphviz",
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected error response (-want +got):\n%s", diff)
+	}
+}
+
+func TestDecodeGraph(t *testing.T) {
+	server := NewServer()
+	for _, mediaType := range []string{MediaTypeJSON, MediaTypeYAML} {
+		t.Run(mediaType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?channel=smoke-test&version=4.17.5", nil)
+			req.Header.Set("Accept", mediaType)
+			w := httptest.NewRecorder()
+			server.mux.ServeHTTP(w, req)
+
+			graph, err := DecodeGraph(w.Body.Bytes())
+			if err != nil {
+				t.Fatalf("failed to decode graph: %v", err)
+			}
+			expected := generateChannelGraph(t, server, "smoke-test", semver.MustParse("4.17.5"), "")
+			if diff := cmp.Diff(expected, graph); diff != "" {
+				t.Errorf("decoded graph differs from the served graph (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultRequestHistory is how many graph requests servers keep by default
+const DefaultRequestHistory = 1000
+
+// RecordedRequest is a graph request received by the server, together with a summary of the response
+type RecordedRequest struct {
+	Time       time.Time `json:"time"`
+	Channel    string    `json:"channel"`
+	Version    string    `json:"version"`
+	Arch       string    `json:"arch,omitempty"`
+	ID         string    `json:"id,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	RemoteAddr string    `json:"remoteAddr"`
+	// Scenario is the channel served in place of the requested one by the session of the cluster, if any
+	Scenario string `json:"scenario,omitempty"`
+
+	// Status is the response status code, StatusNoResponse when the connection was closed without a response
+	Status int `json:"status"`
+	// Fault is the fault injected into the response, if any; it tells why requests have no response status
+	Fault FaultType `json:"fault,omitempty"`
+	// Nodes, Edges and ConditionalEdges are the sizes of the served graph
+	Nodes            int `json:"nodes"`
+	Edges            int `json:"edges"`
+	ConditionalEdges int `json:"conditionalEdges"`
+	// Error is the error reported in the served graph, or the error response
+	Error string `json:"error,omitempty"`
+}
+
+// StatusNoResponse is the status of graph requests closed without a response, like the ones failed by the
+// FaultReset and FaultTimeout faults or given up by the client during a FaultLatency delay
+const StatusNoResponse = 0
+
+// RequestFilter selects recorded requests. Empty fields match all requests.
+type RequestFilter struct {
+	ID      string
+	Channel string
+}
+
+func (f RequestFilter) matches(request RecordedRequest) bool {
+	return (f.ID == "" || f.ID == request.ID) && (f.Channel == "" || f.Channel == request.Channel)
+}
+
+// RequestRecorder is a bounded ring buffer of recorded graph requests, safe for concurrent use
+type RequestRecorder struct {
+	lock     sync.Mutex
+	requests []RecordedRequest
+	// next is the position of the next recorded request once the buffer is full
+	next int
+}
+
+// NewRequestRecorder creates a recorder keeping the last capacity requests. A recorder with zero or negative
+// capacity records nothing.
+func NewRequestRecorder(capacity int) *RequestRecorder {
+	return &RequestRecorder{requests: make([]RecordedRequest, 0, max(capacity, 0))}
+}
+
+// Record adds a request, dropping the oldest one when the recorder is full
+func (r *RequestRecorder) Record(request RecordedRequest) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	switch {
+	case cap(r.requests) == 0:
+	case len(r.requests) < cap(r.requests):
+		r.requests = append(r.requests, request)
+	default:
+		r.requests[r.next] = request
+		r.next = (r.next + 1) % len(r.requests)
+	}
+}
+
+// Requests returns the recorded requests matching filter, oldest first
+func (r *RequestRecorder) Requests(filter RequestFilter) []RecordedRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	requests := []RecordedRequest{}
+	for i := range r.requests {
+		request := r.requests[(r.next+i)%len(r.requests)]
+		if filter.matches(request) {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// Reset forgets all recorded requests
+func (r *RequestRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requests = r.requests[:0]
+	r.next = 0
+}
+
+// RecordedRequests returns the graph requests received by the server matching filter, oldest first
+func (s *Server) RecordedRequests(filter RequestFilter) []RecordedRequest {
+	return s.requests.Requests(filter)
+}
+
+// ResetRecordedRequests forgets the graph requests received so far
+func (s *Server) ResetRecordedRequests() {
+	s.requests.Reset()
+}
+
+type recordedRequestsResponse struct {
+	Requests []RecordedRequest `json:"requests"`
+}
+
+func (s *Server) handleDebugRequests(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		filter := RequestFilter{ID: query.Get("id"), Channel: query.Get("channel")}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(recordedRequestsResponse{Requests: s.RecordedRequests(filter)})
+	case http.MethodDelete:
+		s.ResetRecordedRequests()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// statusRecorder remembers the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to hijack the connection
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
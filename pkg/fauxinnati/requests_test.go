@@ -0,0 +1,128 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestRequestRecorder(t *testing.T) {
+	request := func(id, channel string) RecordedRequest {
+		return RecordedRequest{ID: id, Channel: channel}
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		recorded []RecordedRequest
+		filter   RequestFilter
+		expected []RecordedRequest
+	}{
+		{
+			name:     "keeps requests in order",
+			capacity: 3,
+			recorded: []RecordedRequest{request("a", "simple"), request("b", "simple")},
+			expected: []RecordedRequest{request("a", "simple"), request("b", "simple")},
+		},
+		{
+			name:     "drops the oldest requests when full",
+			capacity: 2,
+			recorded: []RecordedRequest{request("a", "simple"), request("b", "simple"), request("c", "simple"), request("d", "simple"), request("e", "simple")},
+			expected: []RecordedRequest{request("d", "simple"), request("e", "simple")},
+		},
+		{
+			name:     "filters by id and channel",
+			capacity: 5,
+			recorded: []RecordedRequest{request("a", "simple"), request("b", "simple"), request("a", "smoke-test"), request("a", "simple")},
+			filter:   RequestFilter{ID: "a", Channel: "simple"},
+			expected: []RecordedRequest{request("a", "simple"), request("a", "simple")},
+		},
+		{
+			name:     "zero capacity disables recording",
+			recorded: []RecordedRequest{request("a", "simple")},
+			expected: []RecordedRequest{},
+		},
+		{
+			name:     "negative capacity disables recording",
+			capacity: -1,
+			recorded: []RecordedRequest{request("a", "simple")},
+			expected: []RecordedRequest{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := NewRequestRecorder(tt.capacity)
+			for _, request := range tt.recorded {
+				recorder.Record(request)
+			}
+			if diff := cmp.Diff(tt.expected, recorder.Requests(tt.filter)); diff != "" {
+				t.Errorf("unexpected requests (-want +got):\n%s", diff)
+			}
+			recorder.Reset()
+			if requests := recorder.Requests(RequestFilter{}); len(requests) != 0 {
+				t.Errorf("expected no requests after reset, got %v", requests)
+			}
+		})
+	}
+}
+
+func TestServer_handleDebugRequests(t *testing.T) {
+	server := NewServer()
+	serve := func(method, target string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		server.mux.ServeHTTP(w, req)
+		return w
+	}
+	listRequests := func(query string) []RecordedRequest {
+		t.Helper()
+		w := serve(http.MethodGet, "/api/debug/requests?"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var response recordedRequestsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response.Requests
+	}
+
+	header := http.Header{"User-Agent": {"ClusterVersionOperator/4.17.5"}}
+	serve(http.MethodGet, "/api/upgrades_info/graph?channel=simple&version=4.17.5&arch=amd64&id=cluster-a", header)
+	serve(http.MethodGet, "/api/upgrades_info/graph?channel=simple&version=4.17.5&id=cluster-b&fault=status", header)
+	serve(http.MethodGet, "/api/upgrades_info/graph?channel=simple&id=cluster-a", header)
+
+	ignoreVolatile := cmpopts.IgnoreFields(RecordedRequest{}, "Time", "RemoteAddr")
+	expected := []RecordedRequest{
+		{Channel: "simple", Version: "4.17.5", Arch: "amd64", ID: "cluster-a", UserAgent: "ClusterVersionOperator/4.17.5", Status: http.StatusOK, Nodes: 3, Edges: 2},
+		{Channel: "simple", ID: "cluster-a", UserAgent: "ClusterVersionOperator/4.17.5", Status: http.StatusBadRequest, Error: "mandatory client parameters missing: version"},
+	}
+	if diff := cmp.Diff(expected, listRequests("id=cluster-a&channel=simple"), ignoreVolatile); diff != "" {
+		t.Errorf("unexpected requests (-want +got):\n%s", diff)
+	}
+	expected = []RecordedRequest{
+		{Channel: "simple", Version: "4.17.5", ID: "cluster-b", UserAgent: "ClusterVersionOperator/4.17.5", Status: http.StatusServiceUnavailable, Fault: FaultStatus, Nodes: 3, Edges: 2},
+	}
+	if diff := cmp.Diff(expected, listRequests("id=cluster-b"), ignoreVolatile); diff != "" {
+		t.Errorf("unexpected requests (-want +got):\n%s", diff)
+	}
+	if requests := listRequests("channel=smoke-test"); len(requests) != 0 {
+		t.Errorf("expected no requests for an unqueried channel, got %v", requests)
+	}
+
+	if w := serve(http.MethodPost, "/api/debug/requests", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to respond 405, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/api/debug/requests", nil); w.Code != http.StatusNoContent {
+		t.Errorf("expected delete to respond 204, got %d", w.Code)
+	}
+	if requests := server.RecordedRequests(RequestFilter{}); len(requests) != 0 {
+		t.Errorf("expected no requests after delete, got %v", requests)
+	}
+}
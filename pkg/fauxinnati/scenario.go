@@ -0,0 +1,430 @@
+package fauxinnati
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// AIDEV-NOTE: Built-in channels are declared as scenario files embedded from scenarios/*.yaml
+// Files loaded from --scenarios-dir use the same format and override built-in scenarios with the same name
+//
+//go:embed scenarios/*.yaml
+var defaultScenariosFS embed.FS
+
+// Scenario declares a graph relative to the version queried by the client
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// OmitArchitecture disables adding the architecture metadata to nodes when the client queries with arch=multi
+	OmitArchitecture bool                      `yaml:"omitArchitecture,omitempty"`
+	Nodes            []ScenarioNode            `yaml:"nodes"`
+	Edges            []ScenarioEdge            `yaml:"edges,omitempty"`
+	ConditionalEdges []ScenarioConditionalEdge `yaml:"conditionalEdges,omitempty"`
+	// Fault makes graph requests for the scenario channel fail
+	Fault *FaultProfile `yaml:"fault,omitempty"`
+	// Timeline changes the graph over time; the nodes and edges above are its initial state
+	Timeline []TimelineStep `yaml:"timeline,omitempty"`
+}
+
+// ScenarioNode is a node whose version is a VersionExpression evaluated against the queried version
+type ScenarioNode struct {
+	ID      string            `yaml:"id"`
+	Version VersionExpression `yaml:"version"`
+	// AllChannels lists all channels containing the queried version in the node metadata instead of just the served channel
+	AllChannels bool `yaml:"allChannels,omitempty"`
+	// Resolve builds the node with a NodeBuilder, resolving the real payload digest and latest candidate version
+	Resolve bool `yaml:"resolve,omitempty"`
+	// Arches limits the node to graphs queried for these architectures (amd64 when not queried), all by default.
+	// Edges from and to nodes left out of a graph are left out too.
+	Arches []string `yaml:"arches,omitempty"`
+}
+
+// servedFor reports whether the node is in graphs queried for arch
+func (n ScenarioNode) servedFor(arch string) bool {
+	if arch == "" {
+		arch = "amd64"
+	}
+	return len(n.Arches) == 0 || slices.Contains(n.Arches, arch)
+}
+
+// ScenarioEdge is a pair of node IDs: [from, to]
+type ScenarioEdge [2]string
+
+type ScenarioConditionalEdge struct {
+	Edges []ScenarioEdge `yaml:"edges"`
+	Risks []ScenarioRisk `yaml:"risks"`
+}
+
+type ScenarioRisk struct {
+	URL           string                 `yaml:"url"`
+	Name          string                 `yaml:"name"`
+	Message       string                 `yaml:"message"`
+	MatchingRules []ScenarioMatchingRule `yaml:"matchingRules"`
+}
+
+type ScenarioMatchingRule struct {
+	Type   string `yaml:"type"`
+	PromQL string `yaml:"promql,omitempty"`
+}
+
+// VersionExpression is a semver template with {{...}} placeholders evaluated against the queried version:
+// {{major}}, {{minor}} and {{patch}} optionally followed by +N or -N, {{pre}} (the "-prerelease" suffix
+// or nothing) and {{version}} (the queried version itself). For example, "{{major}}.{{minor+1}}.0"
+// evaluates to 4.18.0 for the queried version 4.17.5.
+type VersionExpression string
+
+var versionPlaceholder = regexp.MustCompile(`{{\s*([a-z]+)\s*(?:([+-])\s*(\d+))?\s*}}`)
+
+// Evaluate expands the expression placeholders for the queried version and parses the result
+func (e VersionExpression) Evaluate(queried semver.Version) (semver.Version, error) {
+	var errs []string
+	expanded := versionPlaceholder.ReplaceAllStringFunc(string(e), func(placeholder string) string {
+		value, err := expandPlaceholder(versionPlaceholder.FindStringSubmatch(placeholder), queried)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		return value
+	})
+	if len(errs) > 0 {
+		return semver.Version{}, fmt.Errorf("invalid version expression %q: %s", e, strings.Join(errs, "; "))
+	}
+	if strings.Contains(expanded, "{{") || strings.Contains(expanded, "}}") {
+		return semver.Version{}, fmt.Errorf("invalid version expression %q: malformed placeholder", e)
+	}
+	version, err := semver.Parse(expanded)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("version expression %q evaluated to invalid version %q: %w", e, expanded, err)
+	}
+	return version, nil
+}
+
+func expandPlaceholder(match []string, queried semver.Version) (string, error) {
+	name, sign, operand := match[1], match[2], match[3]
+
+	var base uint64
+	switch name {
+	case "major":
+		base = queried.Major
+	case "minor":
+		base = queried.Minor
+	case "patch":
+		base = queried.Patch
+	case "version", "pre":
+		if sign != "" {
+			return "", fmt.Errorf("arithmetic is not supported on {{%s}}", name)
+		}
+		if name == "version" {
+			return queried.String(), nil
+		}
+		if len(queried.Pre) == 0 {
+			return "", nil
+		}
+		pre := make([]string, 0, len(queried.Pre))
+		for _, p := range queried.Pre {
+			pre = append(pre, p.String())
+		}
+		return "-" + strings.Join(pre, "."), nil
+	default:
+		return "", fmt.Errorf("unknown placeholder {{%s}}", name)
+	}
+
+	if sign == "" {
+		return strconv.FormatUint(base, 10), nil
+	}
+	delta, err := strconv.ParseUint(operand, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid operand in {{%s%s%s}}: %w", name, sign, operand, err)
+	}
+	if sign == "-" {
+		if delta > base {
+			return "", fmt.Errorf("{{%s-%d}} is negative for %d", name, delta, base)
+		}
+		return strconv.FormatUint(base-delta, 10), nil
+	}
+	return strconv.FormatUint(base+delta, 10), nil
+}
+
+// probeVersion is used to check that version expressions evaluate to a valid version when loading scenarios
+var probeVersion = semver.MustParse("4.17.5")
+
+// Validate checks that the scenario is internally consistent
+func (s *Scenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario has no name")
+	}
+	if len(s.Nodes) == 0 {
+		return fmt.Errorf("scenario %s has no nodes", s.Name)
+	}
+	ids := map[string]bool{}
+	for i, node := range s.Nodes {
+		if node.ID == "" {
+			return fmt.Errorf("scenario %s: node %d has no id", s.Name, i)
+		}
+		if ids[node.ID] {
+			return fmt.Errorf("scenario %s: duplicate node id %s", s.Name, node.ID)
+		}
+		ids[node.ID] = true
+		if _, err := node.Version.Evaluate(probeVersion); err != nil {
+			return fmt.Errorf("scenario %s: node %s: %w", s.Name, node.ID, err)
+		}
+		for _, arch := range node.Arches {
+			if err := validateArch(arch); err != nil {
+				return fmt.Errorf("scenario %s: node %s: %w", s.Name, node.ID, err)
+			}
+		}
+	}
+	checkEdge := func(edge ScenarioEdge) error {
+		for _, id := range edge {
+			if !ids[id] {
+				return fmt.Errorf("scenario %s: edge %s->%s references unknown node %s", s.Name, edge[0], edge[1], id)
+			}
+		}
+		return nil
+	}
+	for _, edge := range s.Edges {
+		if err := checkEdge(edge); err != nil {
+			return err
+		}
+	}
+	for i, conditionalEdge := range s.ConditionalEdges {
+		if len(conditionalEdge.Edges) == 0 {
+			return fmt.Errorf("scenario %s: conditional edge %d has no edges", s.Name, i)
+		}
+		if len(conditionalEdge.Risks) == 0 {
+			return fmt.Errorf("scenario %s: conditional edge %d has no risks", s.Name, i)
+		}
+		for _, edge := range conditionalEdge.Edges {
+			if err := checkEdge(edge); err != nil {
+				return err
+			}
+		}
+		for _, risk := range conditionalEdge.Risks {
+			if risk.Name == "" {
+				return fmt.Errorf("scenario %s: conditional edge %d has a risk without a name", s.Name, i)
+			}
+			if err := risk.validate(); err != nil {
+				return fmt.Errorf("scenario %s: %w", s.Name, err)
+			}
+		}
+	}
+	if s.Fault != nil {
+		if err := s.Fault.Validate(); err != nil {
+			return fmt.Errorf("scenario %s: %w", s.Name, err)
+		}
+	}
+	if len(s.Timeline) > 0 {
+		if _, err := s.timelineStates(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContainsQueriedVersion reports whether any of the scenario nodes evaluates to the queried version
+func (s *Scenario) ContainsQueriedVersion() bool {
+	for _, node := range s.Nodes {
+		if version, err := node.Version.Evaluate(probeVersion); err == nil && version.EQ(probeVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScenario parses a single YAML (or JSON) scenario definition
+func ParseScenario(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scenario: %w", err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+// LoadScenarios loads all *.yaml, *.yml and *.json scenario files from the top level of fsys.
+// Scenarios without a name are named after their file.
+func LoadScenarios(fsys fs.FS) (map[string]*Scenario, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	scenarios := map[string]*Scenario{}
+	for _, entry := range entries {
+		if !isScenarioFile(entry) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		var scenario Scenario
+		if err := yaml.Unmarshal(data, &scenario); err != nil {
+			return nil, fmt.Errorf("%s: failed to unmarshal scenario: %w", entry.Name(), err)
+		}
+		if scenario.Name == "" {
+			scenario.Name = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		if err := scenario.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if _, ok := scenarios[scenario.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate scenario %s", entry.Name(), scenario.Name)
+		}
+		scenarios[scenario.Name] = &scenario
+	}
+	return scenarios, nil
+}
+
+func isScenarioFile(entry fs.DirEntry) bool {
+	ext := filepath.Ext(entry.Name())
+	return !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && (ext == ".yaml" || ext == ".yml" || ext == ".json")
+}
+
+// LoadScenariosDir loads scenarios from a directory, see LoadScenarios
+func LoadScenariosDir(dir string) (map[string]*Scenario, error) {
+	return LoadScenarios(os.DirFS(dir))
+}
+
+// DefaultScenarios returns the scenarios of the built-in channels. The scenarios are shared with every server and
+// must not be modified.
+func DefaultScenarios() map[string]*Scenario {
+	return maps.Clone(defaultScenarios())
+}
+
+// defaultScenarios parses the embedded scenarios once, the channel registry is rebuilt from them on every change
+var defaultScenarios = sync.OnceValue(func() map[string]*Scenario {
+	sub, err := fs.Sub(defaultScenariosFS, "scenarios")
+	if err != nil {
+		panic(fmt.Sprintf("embedded scenarios are broken: %v", err))
+	}
+	scenarios, err := LoadScenarios(sub)
+	if err != nil {
+		panic(fmt.Sprintf("embedded scenarios are broken: %v", err))
+	}
+	return scenarios
+})
+
+func (s *Server) generateScenarioGraph(scenario *Scenario, queriedVersion semver.Version, arch string, channel string) Graph {
+	nodes := make([]Node, 0, len(scenario.Nodes))
+	indices := make(map[string]int, len(scenario.Nodes))
+	for _, scenarioNode := range scenario.Nodes {
+		if !scenarioNode.servedFor(arch) {
+			continue
+		}
+		version, err := scenarioNode.Version.Evaluate(queriedVersion)
+		if err != nil {
+			s.logger.WithError(err).WithField("scenario", scenario.Name).WithField("node", scenarioNode.ID).Warning("Failed to evaluate node version")
+			return s.generateEmptyGraph(fmt.Sprintf("failed to evaluate version of node %s for %s: %v", scenarioNode.ID, queriedVersion.String(), err))
+		}
+
+		var node Node
+		switch {
+		case scenarioNode.Resolve:
+			channels := []string{channel}
+			if scenarioNode.AllChannels {
+				channels = s.channelRegistry().ChannelsContainingVersion()
+			}
+			node = s.newNode(queriedVersion, version, channels, arch)
+		case scenarioNode.AllChannels:
+			node = NewNodeWithChannelsMetadata(version, s.formatChannelsForMetadata())
+		default:
+			node = NewNode(version, channel)
+		}
+		if !scenarioNode.Resolve {
+			digest := generateArchImageSHA256(version, arch)
+			node.Image = fmt.Sprintf("%s@sha256:%s", s.digestResolver.Repository(), digest)
+			node.Metadata["io.openshift.upgrades.graph.release.manifestref"] = "sha256:" + digest
+		}
+		if !scenario.OmitArchitecture {
+			node.SetArchitecture(arch)
+		}
+
+		indices[scenarioNode.ID] = len(nodes)
+		nodes = append(nodes, node)
+	}
+	served := func(edge ScenarioEdge) bool {
+		_, fromServed := indices[edge[0]]
+		_, toServed := indices[edge[1]]
+		return fromServed && toServed
+	}
+
+	edges := make([]Edge, 0, len(scenario.Edges))
+	for _, edge := range scenario.Edges {
+		if served(edge) {
+			edges = append(edges, Edge{indices[edge[0]], indices[edge[1]]})
+		}
+	}
+
+	conditionalEdges := make([]ConditionalEdge, 0, len(scenario.ConditionalEdges))
+	for _, scenarioEdge := range scenario.ConditionalEdges {
+		conditionalEdge := ConditionalEdge{
+			Edges: make([]ConditionalUpdate, 0, len(scenarioEdge.Edges)),
+			Risks: make([]ConditionalUpdateRisk, 0, len(scenarioEdge.Risks)),
+		}
+		for _, edge := range scenarioEdge.Edges {
+			if !served(edge) {
+				continue
+			}
+			conditionalEdge.Edges = append(conditionalEdge.Edges, ConditionalUpdate{
+				From: nodes[indices[edge[0]]].Version.String(),
+				To:   nodes[indices[edge[1]]].Version.String(),
+			})
+		}
+		if len(conditionalEdge.Edges) == 0 {
+			continue
+		}
+		for _, risk := range scenarioEdge.Risks {
+			conditionalEdge.Risks = append(conditionalEdge.Risks, risk.toRisk())
+		}
+		conditionalEdges = append(conditionalEdges, conditionalEdge)
+	}
+
+	return Graph{
+		Nodes:            nodes,
+		Edges:            edges,
+		ConditionalEdges: conditionalEdges,
+	}
+}
+
+// validate checks the matching rules of a named risk
+func (r ScenarioRisk) validate() error {
+	if len(r.MatchingRules) == 0 {
+		return fmt.Errorf("risk %s has no matching rules", r.Name)
+	}
+	for _, rule := range r.MatchingRules {
+		if rule.Type == "PromQL" && rule.PromQL == "" {
+			return fmt.Errorf("risk %s has a PromQL matching rule without a query", r.Name)
+		}
+	}
+	return nil
+}
+
+func (r ScenarioRisk) toRisk() ConditionalUpdateRisk {
+	risk := ConditionalUpdateRisk{
+		URL:           r.URL,
+		Name:          r.Name,
+		Message:       r.Message,
+		MatchingRules: make([]MatchingRule, 0, len(r.MatchingRules)),
+	}
+	for _, rule := range r.MatchingRules {
+		matchingRule := MatchingRule{Type: rule.Type}
+		if rule.PromQL != "" {
+			matchingRule.PromQL = &PromQLQuery{PromQL: rule.PromQL}
+		}
+		risk.MatchingRules = append(risk.MatchingRules, matchingRule)
+	}
+	return risk
+}
@@ -0,0 +1,119 @@
+package fauxinnati
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// channelSet is an immutable snapshot of the served channels. Reloads build a new set and swap it atomically
+// so that in-flight requests keep using a consistent set.
+type channelSet struct {
+	registry *ChannelRegistry
+	// loaded are the scenarios loaded from a scenarios directory
+	loaded     map[string]*Scenario
+	generation int64
+	// fingerprint identifies the content of the directory the scenarios were loaded from
+	fingerprint string
+}
+
+// ScenarioGeneration returns the generation of the currently served scenarios. The built-in scenarios
+// are generation 1 and every successful (re)load increments it.
+func (s *Server) ScenarioGeneration() int64 {
+	return s.channelSet.Load().generation
+}
+
+// LoadScenariosDir loads scenarios from dir and serves them together with the built-in channels. Scenarios
+// with the same name as a built-in channel replace it. Scenarios loaded by a previous call that are no longer
+// present in dir stop being served.
+func (s *Server) LoadScenariosDir(dir string) error {
+	fingerprint, err := fingerprintScenariosDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load scenarios from %s: %w", dir, err)
+	}
+	loaded, err := LoadScenariosDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load scenarios from %s: %w", dir, err)
+	}
+
+	s.channelsLock.Lock()
+	defer s.channelsLock.Unlock()
+	next := &channelSet{
+		registry:    s.buildChannelRegistry(loaded),
+		loaded:      loaded,
+		generation:  s.channelSet.Load().generation + 1,
+		fingerprint: fingerprint,
+	}
+	s.channelSet.Store(next)
+	s.logger.WithFields(logrus.Fields{
+		"dir":        dir,
+		"scenarios":  slices.Sorted(maps.Keys(loaded)),
+		"generation": next.generation,
+	}).Info("Loaded scenarios")
+	return nil
+}
+
+// AIDEV-NOTE: Scenario directories are polled rather than watched with inotify: ConfigMap volumes update
+// files by swapping a ..data symlink, which is easy to miss with file events but trivial to detect by content
+
+// WatchScenariosDir polls dir every interval and reloads the scenarios when the content of the scenario files
+// differs from the content the currently served scenarios were loaded from. Invalid scenarios are logged and
+// rejected, and the last good set keeps being served. WatchScenariosDir blocks until ctx is cancelled.
+func (s *Server) WatchScenariosDir(ctx context.Context, dir string, interval time.Duration) {
+	var rejected string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := fingerprintScenariosDir(dir)
+		if err != nil {
+			s.logger.WithError(err).WithField("dir", dir).Warning("Failed to read scenarios directory")
+			continue
+		}
+		if current == s.channelSet.Load().fingerprint || current == rejected {
+			continue
+		}
+
+		if err := s.LoadScenariosDir(dir); err != nil {
+			rejected = current
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"dir":        dir,
+				"generation": s.ScenarioGeneration(),
+			}).Error("Rejected invalid scenarios, keeping the last good set")
+		}
+	}
+}
+
+// fingerprintScenariosDir hashes the names and content of all scenario files in dir
+func fingerprintScenariosDir(dir string) (string, error) {
+	fsys := os.DirFS(dir)
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, entry := range entries {
+		if !isScenarioFile(entry) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(hash, "%s\x00%d\x00", entry.Name(), len(data))
+		_, _ = hash.Write(data)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
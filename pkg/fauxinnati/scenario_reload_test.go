@@ -0,0 +1,122 @@
+package fauxinnati
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const reloadScenario = `nodes:
+- id: A
+  version: '{{version}}'
+- id: B
+  version: '{{major}}.{{minor+1}}.0'
+edges:
+- [A, B]
+`
+
+func writeScenarioFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	tmp := filepath.Join(dir, ".tmp-"+name)
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write scenario: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		t.Fatalf("failed to rename scenario: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_WatchScenariosDir(t *testing.T) {
+	dir := t.TempDir()
+	writeScenarioFile(t, dir, "first.yaml", reloadScenario)
+
+	server := NewServer()
+	if err := server.LoadScenariosDir(dir); err != nil {
+		t.Fatalf("failed to load scenarios: %v", err)
+	}
+	if generation := server.ScenarioGeneration(); generation != 2 {
+		t.Fatalf("expected generation 2 after initial load, got %d", generation)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.WatchScenariosDir(ctx, dir, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	writeScenarioFile(t, dir, "second.yaml", reloadScenario)
+	waitFor(t, "second scenario to be loaded", func() bool {
+		_, ok := server.channelRegistry().Lookup("second")
+		return ok
+	})
+	if generation := server.ScenarioGeneration(); generation != 3 {
+		t.Errorf("expected generation 3 after reload, got %d", generation)
+	}
+
+	writeScenarioFile(t, dir, "third.yaml", "nodes:\n- id: A\n  version: '{{version}}'\nedges:\n- [A, missing]\n")
+	// Give the watcher a few polls to notice and reject the invalid scenario
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := server.channelRegistry().Lookup("third"); ok {
+		t.Errorf("invalid scenario was loaded")
+	}
+	if _, ok := server.channelRegistry().Lookup("second"); !ok {
+		t.Errorf("last good scenarios were dropped after an invalid reload")
+	}
+	if generation := server.ScenarioGeneration(); generation != 3 {
+		t.Errorf("expected generation to stay 3 after an invalid reload, got %d", generation)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "third.yaml")); err != nil {
+		t.Fatalf("failed to remove scenario: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "first.yaml")); err != nil {
+		t.Fatalf("failed to remove scenario: %v", err)
+	}
+	waitFor(t, "first scenario to be unloaded", func() bool {
+		_, ok := server.channelRegistry().Lookup("first")
+		return !ok
+	})
+	if _, ok := server.channelRegistry().Lookup("simple"); !ok {
+		t.Errorf("built-in scenarios were dropped on reload")
+	}
+}
+
+func TestServer_handleReadyz_ReportsScenarioGeneration(t *testing.T) {
+	dir := t.TempDir()
+	writeScenarioFile(t, dir, "first.yaml", reloadScenario)
+
+	server := NewServer()
+	if err := server.LoadScenariosDir(dir); err != nil {
+		t.Fatalf("failed to load scenarios: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	server.mux.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	body, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	if expected := "OK\nscenario generation: 2\n"; string(body) != expected {
+		t.Errorf("expected body %q, got %q", expected, string(body))
+	}
+}
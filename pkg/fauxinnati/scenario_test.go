@@ -0,0 +1,241 @@
+package fauxinnati
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestVersionExpression_Evaluate(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  VersionExpression
+		queried     string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:       "queried version",
+			expression: "{{version}}",
+			queried:    "4.20.0-ec.2",
+			expected:   "4.20.0-ec.2",
+		},
+		{
+			name:       "next minor",
+			expression: "{{major}}.{{minor+1}}.0",
+			queried:    "4.17.5",
+			expected:   "4.18.0",
+		},
+		{
+			name:       "previous minor drops prerelease",
+			expression: "{{major}}.{{minor-1}}.1",
+			queried:    "4.20.0-ec.2",
+			expected:   "4.19.1",
+		},
+		{
+			name:       "prerelease is kept with pre placeholder",
+			expression: "{{major}}.{{minor+1}}.2{{pre}}",
+			queried:    "4.15.0-ec.1",
+			expected:   "4.16.2-ec.1",
+		},
+		{
+			name:       "pre placeholder is empty for GA versions",
+			expression: "{{major}}.{{minor}}.{{patch + 2}}{{pre}}",
+			queried:    "4.17.5",
+			expected:   "4.17.7",
+		},
+		{
+			name:        "negative component",
+			expression:  "{{major}}.{{minor}}.{{patch-1}}",
+			queried:     "4.17.0",
+			expectedErr: errors.New(`invalid version expression "{{major}}.{{minor}}.{{patch-1}}": {{patch-1}} is negative for 0`),
+		},
+		{
+			name:        "unknown placeholder",
+			expression:  "{{major}}.{{minor}}.{{build}}",
+			queried:     "4.17.0",
+			expectedErr: errors.New(`invalid version expression "{{major}}.{{minor}}.{{build}}": unknown placeholder {{build}}`),
+		},
+		{
+			name:        "arithmetic on version",
+			expression:  "{{version+1}}",
+			queried:     "4.17.0",
+			expectedErr: errors.New(`invalid version expression "{{version+1}}": arithmetic is not supported on {{version}}`),
+		},
+		{
+			name:        "invalid result",
+			expression:  "{{major}}.{{minor}}",
+			queried:     "4.17.0",
+			expectedErr: errors.New(`version expression "{{major}}.{{minor}}" evaluated to invalid version "4.17": No Major.Minor.Patch elements found`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.expression.Evaluate(semver.MustParse(tt.queried))
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("error mismatch (-want +got):\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, result.String()); diff != "" {
+				t.Errorf("version mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         fstest.MapFS
+		expectedNames []string
+		expectedErr   error
+	}{
+		{
+			name: "scenarios are named after their files unless named explicitly",
+			files: fstest.MapFS{
+				"unnamed.yaml":  {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\n")},
+				"named.yml":     {Data: []byte("name: explicit\nnodes:\n- id: A\n  version: '{{version}}'\n")},
+				"json.json":     {Data: []byte(`{"nodes": [{"id": "A", "version": "{{version}}"}]}`)},
+				"README.md":     {Data: []byte("not a scenario")},
+				".hidden.yaml":  {Data: []byte("not a scenario")},
+				"dir/sub.yaml":  {Data: []byte("not a scenario")},
+				"..data/x.yaml": {Data: []byte("not a scenario")},
+			},
+			expectedNames: []string{"explicit", "json", "unnamed"},
+		},
+		{
+			name: "edge referencing an unknown node is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\nedges:\n- [A, B]\n")},
+			},
+			expectedErr: errors.New("broken.yaml: scenario broken: edge A->B references unknown node B"),
+		},
+		{
+			name: "duplicate node ids are rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\n- id: A\n  version: '{{major}}.{{minor+1}}.0'\n")},
+			},
+			expectedErr: errors.New("broken.yaml: scenario broken: duplicate node id A"),
+		},
+		{
+			name: "invalid version expression is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{major}}.{{minor}}.{{z}}'\n")},
+			},
+			expectedErr: errors.New(`broken.yaml: scenario broken: node A: invalid version expression "{{major}}.{{minor}}.{{z}}": unknown placeholder {{z}}`),
+		},
+		{
+			name: "risk without matching rules is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\n- id: B\n  version: '{{major}}.{{minor+1}}.0'\nconditionalEdges:\n- edges: [[A, B]]\n  risks:\n  - name: Risk\n")},
+			},
+			expectedErr: errors.New("broken.yaml: scenario broken: risk Risk has no matching rules"),
+		},
+		{
+			name: "node limited to an unknown arch is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\n  arches: [amd64, x86_64]\n")},
+			},
+			expectedErr: errors.New(`broken.yaml: scenario broken: node A: unknown architecture "x86_64" (one of amd64, arm64, ppc64le, s390x, multi)`),
+		},
+		{
+			name: "timeline step without a trigger is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\ntimeline:\n- addNodes:\n  - id: B\n    version: '{{major}}.{{minor}}.{{patch+1}}'\n")},
+			},
+			expectedErr: errors.New("broken.yaml: scenario broken: timeline step 0: step has no trigger (after or afterRequests)"),
+		},
+		{
+			name: "timeline step removing an unknown edge is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\ntimeline:\n- after: 10m\n  removeEdges: [[A, B]]\n")},
+			},
+			expectedErr: errors.New("broken.yaml: scenario broken: timeline step 0: cannot remove unknown edge A->B"),
+		},
+		{
+			name: "timeline step adding an edge to an unknown node is rejected",
+			files: fstest.MapFS{
+				"broken.yaml": {Data: []byte("nodes:\n- id: A\n  version: '{{version}}'\ntimeline:\n- afterRequests: 3\n  addEdges: [[A, B]]\n")},
+			},
+			expectedErr: errors.New("broken.yaml: scenario broken timeline step 0: edge A->B references unknown node B"),
+		},
+		{
+			name: "duplicate scenario names are rejected",
+			files: fstest.MapFS{
+				"a.yaml": {Data: []byte("name: same\nnodes:\n- id: A\n  version: '{{version}}'\n")},
+				"b.yaml": {Data: []byte("name: same\nnodes:\n- id: A\n  version: '{{version}}'\n")},
+			},
+			expectedErr: errors.New("b.yaml: duplicate scenario same"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scenarios, err := LoadScenarios(tt.files)
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedNames, slices.Sorted(maps.Keys(scenarios))); diff != "" {
+				t.Errorf("scenario names mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDefaultScenarios(t *testing.T) {
+	expected := []string{
+		"OTA-1813",
+		"channel-head",
+		"heterogeneous-migration",
+		"risks-always",
+		"risks-cannot-evaluate",
+		"risks-matching",
+		"risks-nonmatching",
+		"simple",
+		"smoke-test",
+		"version-not-found",
+	}
+	if diff := cmp.Diff(expected, slices.Sorted(maps.Keys(DefaultScenarios()))); diff != "" {
+		t.Errorf("default scenarios mismatch (-want +got):\n%s", diff)
+	}
+	if DefaultScenarios()["simple"] != DefaultScenarios()["simple"] {
+		t.Errorf("expected the embedded scenarios to be parsed once")
+	}
+}
+
+func TestServer_LoadScenariosDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseVersion semver.Version
+		arch        string
+		channel     string
+	}{
+		{
+			name:        "serves a scenario loaded from a directory",
+			baseVersion: semver.MustParse("4.19.3"),
+			arch:        "multi",
+			channel:     "OTA-0000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			if err := server.LoadScenariosDir("testdata/scenarios"); err != nil {
+				t.Fatalf("failed to load scenarios: %v", err)
+			}
+			result := generateChannelGraph(t, server, tt.channel, tt.baseVersion, tt.arch)
+			testhelper.CompareWithFixture(t, result)
+		})
+	}
+}
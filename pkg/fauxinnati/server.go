@@ -1,16 +1,17 @@
 package fauxinnati
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"html/template"
 	"net/http"
-	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/blang/semver/v4"
-	"github.com/hashicorp/go-retryablehttp"
 	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
 
@@ -24,9 +25,42 @@ type Client interface {
 type Server struct {
 	mux              *http.ServeMux
 	digestResolver   DigestResolver
+	digestCache      Cache
 	candidatesGetter *candidatesGetter
 	candidates       func(client Client, major, minor uint64) ([]semver.Version, error)
 	client           Client
+	channelSet       atomic.Pointer[channelSet]
+
+	channelsLock       sync.Mutex
+	registeredChannels []Channel
+	// staticGraphs are the graphs set over the admin API by channel
+	staticGraphs map[string]Graph
+	// adminToken authenticates requests of the admin API, which is disabled without a token
+	adminToken string
+
+	timelinesLock sync.Mutex
+	timelines     map[timelineKey]*timelineState
+
+	sessionsLock       sync.Mutex
+	sessions           map[string]*session
+	sessionIdleTimeout time.Duration
+	// maxImplicitSessions caps the sessions started by graph requests rather than created over the API
+	maxImplicitSessions int
+
+	prometheusEnabled bool
+	prometheusSeries  []Sample
+
+	requests *RequestRecorder
+	metrics  *metrics
+
+	tlsConfig *tls.Config
+	caBundle  []byte
+
+	shutdownTimeout time.Duration
+	clock           Clock
+	logger          logrus.FieldLogger
+	// strict makes graph requests for unknown channels fail instead of returning an empty graph
+	strict bool
 }
 
 type PullSpecResolver interface {
@@ -39,33 +73,57 @@ type Cache interface {
 	Set(k string, x interface{}, d time.Duration)
 }
 
-func NewServer() *Server {
-	client := retryablehttp.NewClient()
-	client.HTTPClient.Timeout = 30 * time.Second
-	client.RetryMax = 3
-	client.RetryWaitMin = 1 * time.Second
-	client.RetryWaitMax = 5 * time.Second
-	c := cache.New(5*time.Minute, 10*time.Minute)
-	candidateGetter := candidatesGetter{cache: c}
+// NewServer creates a server configured by opts. Without options, upstream requests are made with a retrying
+// client, candidate channels are read from GitHub and payload digests are resolved from quay.io.
+func NewServer(opts ...Option) *Server {
 	s := &Server{
-		mux:              http.NewServeMux(),
-		client:           client.StandardClient(),
-		digestResolver:   &prereleaseDigestResolver{cache: c},
-		candidatesGetter: &candidateGetter,
-	}
+		mux:    http.NewServeMux(),
+		client: NewRetryingClient(DefaultHTTPTimeout, DefaultHTTPRetries),
+		candidatesGetter: &candidatesGetter{
+			cache:  cache.New(5*time.Minute, 10*time.Minute),
+			source: NewGitHubCandidatesSource(),
+			ttl:    DefaultCandidatesTTL,
+			logger: logrus.StandardLogger(),
+		},
+		digestResolver:     NewRegistryDigestResolver(DefaultPayloadRepository, "", false),
+		requests:           NewRequestRecorder(DefaultRequestHistory),
+		metrics:            newMetrics(),
+		shutdownTimeout:    DefaultShutdownTimeout,
+		clock:              realClock{},
+		logger:             logrus.StandardLogger(),
+		timelines:          map[timelineKey]*timelineState{},
+		sessions:           map[string]*session{},
+		sessionIdleTimeout: DefaultSessionIdleTimeout,
+		staticGraphs:       map[string]Graph{},
+
+		maxImplicitSessions: DefaultMaxImplicitSessions,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.client = &instrumentedClient{client: withClientLogger(s.client, s.logger), metrics: s.metrics}
+	s.candidatesGetter.cache = &instrumentedCache{Cache: s.candidatesGetter.cache, name: "candidates", metrics: s.metrics}
+	s.instrumentDigestResolver()
+	s.registeredChannels = s.builtinChannels()
+	s.channelSet.Store(&channelSet{registry: s.buildChannelRegistry(nil), generation: 1})
 	s.setupRoutes()
 	return s
 }
 
-func WithLogging(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logrus.WithFields(logrus.Fields{
-			"address": r.RemoteAddr,
-			"method":  r.Method,
-			"url":     r.URL,
-		}).Debug("Access log.")
-		h.ServeHTTP(w, r)
-	})
+// instrumentDigestResolver makes a registry digest resolver use the digest cache, counted in the metrics, and the
+// server logger. The resolver is copied so that servers sharing it do not affect each other.
+func (s *Server) instrumentDigestResolver() {
+	registry, ok := s.digestResolver.(*registryDigestResolver)
+	if !ok {
+		return
+	}
+	instrumented := *registry
+	if s.digestCache != nil {
+		instrumented.cache = s.digestCache
+	}
+	instrumented.cache = &instrumentedCache{Cache: instrumented.cache, name: "digests", metrics: s.metrics}
+	instrumented.logger = s.logger
+	s.digestResolver = &instrumented
 }
 
 func (s *Server) setupRoutes() {
@@ -74,726 +132,161 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/healthz", s.handleHealthz)
 	s.mux.HandleFunc("/readyz", s.handleReadyz)
 	s.mux.HandleFunc("/version", s.handleVersion)
-}
-
+	s.mux.HandleFunc("/api/v1/query", s.handlePrometheusQuery)
+	s.mux.HandleFunc("/api/debug/requests", s.handleDebugRequests)
+	s.mux.HandleFunc("/api/debug/timelines", s.handleDebugTimelines)
+	s.mux.HandleFunc("/api/debug/sessions", s.handleDebugSessions)
+	s.mux.HandleFunc("/api/debug/sessions/{id}", s.handleDebugSession)
+	s.mux.HandleFunc("/api/admin/channels/{name}", s.handleAdminChannel)
+	s.mux.HandleFunc("/api/admin/channels/{name}/edits", s.handleAdminChannelEdits)
+	s.mux.HandleFunc("/api/admin/sessions", s.handleAdminSessions)
+	s.mux.HandleFunc("/api/admin/sessions/{id}", s.handleAdminSession)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	s.mux.HandleFunc(CABundlePath, s.handleCABundle)
+}
+
+// Start serves requests on port until the process exits. Use Run or Listen to control the server lifetime.
 func (s *Server) Start(port int) error {
-	addr := fmt.Sprintf(":%d", port)
-	logrus.WithField("address", addr).Info("Starting fauxinnati server")
-	return http.ListenAndServe(addr, WithLogging(s.mux))
+	return s.Run(context.Background(), fmt.Sprintf(":%d", port))
 }
 
 func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	query := r.URL.Query()
 	channel := query.Get("channel")
 	version := query.Get("version")
 	arch := query.Get("arch")
 
-	if channel == "" || version == "" {
-		http.Error(w, "Missing required parameters: channel and version", http.StatusBadRequest)
-		return
+	start := s.clock.Now()
+	var known bool
+	recorded := RecordedRequest{
+		Time:       start.UTC(),
+		Channel:    channel,
+		Version:    version,
+		Arch:       arch,
+		ID:         query.Get("id"),
+		UserAgent:  r.UserAgent(),
+		RemoteAddr: r.RemoteAddr,
+	}
+	w.Header().Set("Vary", "Accept")
+	statusWriter := &statusRecorder{ResponseWriter: w}
+	w = statusWriter
+	defer func() {
+		recorded.Status = statusWriter.status
+		s.requests.Record(recorded)
+		s.metrics.observeGraphRequest(channel, known, arch, statusWriter.status, recorded.Fault, s.clock.Now().Sub(start))
+	}()
+	fail := func(code int, kind, message string) {
+		recorded.Error = message
+		writeErrorResponse(w, code, kind, message)
 	}
 
-	parsedVersion, err := semver.Parse(version)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid version format: %v", err), http.StatusBadRequest)
+	if r.Method != http.MethodGet {
+		recorded.Error = "Method not allowed"
+		http.Error(w, recorded.Error, http.StatusMethodNotAllowed)
 		return
 	}
 
-	var graph Graph
-	switch channel {
-	case "version-not-found":
-		graph = s.generateVersionNotFoundGraph(parsedVersion, arch, channel)
-	case "channel-head":
-		graph = s.generateChannelHeadGraph(parsedVersion, arch, channel)
-	case "simple":
-		graph = s.generateSimpleGraph(parsedVersion, arch, channel)
-	case "risks-always":
-		graph = s.generateRisksAlwaysGraph(parsedVersion, arch, channel)
-	case "risks-matching":
-		graph = s.generateRisksMatchingGraph(parsedVersion, arch, channel)
-	case "risks-nonmatching":
-		graph = s.generateRisksNonmatchingGraph(parsedVersion, arch, channel)
-	case "risks-cannot-evaluate":
-		graph = s.generateRisksCannotEvaluateGraph(parsedVersion, arch, channel)
-	case "smoke-test":
-		graph = s.generateSmokeTestGraph(parsedVersion, arch, channel)
-	case "OCP-88175":
-		graph = s.generateOCP88175Graph(parsedVersion, arch, channel, false)
-	case "OCP-88175-PromQL":
-		graph = s.generateOCP88175Graph(parsedVersion, arch, channel, true)
-	case "OTA-1813":
-		graph = s.generateOTA1813Graph(parsedVersion, arch, channel)
-	default:
-		graph = s.generateEmptyGraph("")
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ") // Pretty print the JSON response
-	if err := encoder.Encode(graph); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+	mediaType, ok := negotiateMediaType(r.Header.Get("Accept"))
+	if !ok {
+		fail(http.StatusNotAcceptable, ErrorKindInvalidContentType, fmt.Sprintf("invalid content type requested, supported: %s", strings.Join(graphMediaTypes, ", ")))
 		return
 	}
-}
-
-func (s *Server) generateVersionNotFoundGraph(baseVersion semver.Version, arch string, channel string) Graph {
-	versionA := baseVersion
-	versionA.Minor++
-	versionA.Patch = 0
-
-	versionB := versionA
-	versionB.Patch = 1
-
-	versionC := versionA
-	versionC.Patch = 2
-
-	nodeA := NewNode(versionA, channel)
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNode(versionC, channel)
-
-	nodeA.SetArchitecture(arch)
-	nodeB.SetArchitecture(arch)
-	nodeC.SetArchitecture(arch)
-
-	return Graph{
-		Nodes: []Node{nodeA, nodeB, nodeC},
-		Edges: []Edge{
-			{0, 1}, // A -> B
-			{1, 2}, // B -> C
-		},
-		ConditionalEdges: []ConditionalEdge{},
-	}
-}
-
-func (s *Server) generateChannelHeadGraph(clientVersion semver.Version, arch string, channel string) Graph {
-	// Client version is the head (node C)
-	versionC := clientVersion
-
-	// Node A: Previous minor version with patch 0
-	versionA := clientVersion
-	versionA.Minor--
-	versionA.Patch = 0
-	versionA.Pre = nil // Clear prerelease
-
-	// Node B: Previous minor version with patch 1
-	versionB := versionA
-	versionB.Patch = 1
-
-	nodeA := NewNode(versionA, channel)
-
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNodeWithChannelsMetadata(versionC, s.formatChannelsForMetadata(versionC))
-
-	nodeA.SetArchitecture(arch)
-	nodeB.SetArchitecture(arch)
-	nodeC.SetArchitecture(arch)
-
-	return Graph{
-		Nodes: []Node{nodeA, nodeB, nodeC},
-		Edges: []Edge{
-			{0, 1}, // A -> B
-			{1, 2}, // B -> C
-		},
-		ConditionalEdges: []ConditionalEdge{},
-	}
-}
-
-func (s *Server) generateSimpleGraph(queriedVersion semver.Version, arch string, channel string) Graph {
-	// A is the queried version
-	versionA := queriedVersion
-
-	// B: Same minor, patch bumped by one, drop prerelease
-	versionB := queriedVersion
-	versionB.Patch++
-	versionB.Pre = nil
-
-	// C: Minor bumped by one, patch set to zero, drop prerelease
-	versionC := queriedVersion
-	versionC.Minor++
-	versionC.Patch = 0
-	versionC.Pre = nil
-
-	nodeA := NewNodeWithChannelsMetadata(versionA, s.formatChannelsForMetadata(versionA))
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNode(versionC, channel)
-
-	nodeA.SetArchitecture(arch)
-	nodeB.SetArchitecture(arch)
-	nodeC.SetArchitecture(arch)
-
-	return Graph{
-		Nodes: []Node{nodeA, nodeB, nodeC},
-		Edges: []Edge{
-			{0, 1}, // A -> B
-			{0, 2}, // A -> C
-		},
-		ConditionalEdges: []ConditionalEdge{},
-	}
-}
-
-func (s *Server) generateRisksAlwaysGraph(queriedVersion semver.Version, arch string, channel string) Graph {
-	// A is the queried version
-	versionA := queriedVersion
-
-	// B: Same minor, patch bumped by one, drop prerelease
-	versionB := queriedVersion
-	versionB.Patch++
-	versionB.Pre = nil
-
-	// C: Minor bumped by one, patch set to zero, drop prerelease
-	versionC := queriedVersion
-	versionC.Minor++
-	versionC.Patch = 0
-	versionC.Pre = nil
-
-	nodeA := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versionA, s.getChannelsContainingVersion(versionA), arch)
-	nodeB := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versionB, []string{channel}, arch)
-	nodeC := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versionC, []string{channel}, arch)
-
-	// Create conditional edges with SyntheticRisk that applies always
-	conditionalEdges := []ConditionalEdge{
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: nodeA.Version.String(),
-					To:   nodeB.Version.String(),
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-a",
-					Name:    "SyntheticRiskA",
-					Message: "This is a synthetic risk A that always applies for testing purposes",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "Always",
-						},
-					},
-				},
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-b",
-					Name:    "SyntheticRiskB",
-					Message: "This is a synthetic risk B that always applies for testing purposes",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "Always",
-						},
-					},
-				},
-			},
-		},
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: nodeA.Version.String(),
-					To:   nodeC.Version.String(),
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-a",
-					Name:    "SyntheticRiskA",
-					Message: "This is a synthetic risk A that always applies for testing purposes",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "Always",
-						},
-					},
-				},
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-c",
-					Name:    "SyntheticRiskC",
-					Message: "This is a synthetic risk C that always applies for testing purposes",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "Always",
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return Graph{
-		Nodes:            []Node{nodeA, nodeB, nodeC},
-		Edges:            []Edge{}, // No unconditional edges, only conditional
-		ConditionalEdges: conditionalEdges,
-	}
-}
-
-func NewNodeWithNodeBuilder(resolver DigestResolver, latestCandidate func(client Client, major, minor uint64) (semver.Version, error), client Client, queriedVersion, v semver.Version, channels []string, arch string) Node {
-	b := &NodeBuilder{}
-	b.WithArchitecture(arch).WithChannels(channels).WithVersion(v).withQueriedVersion(queriedVersion).
-		WithDigestResolver(resolver).WithClient(client).withGetLatest(latestCandidate)
-	return b.Build()
-}
-
-func (s *Server) generateRisksMatchingGraph(queriedVersion semver.Version, arch string, channel string) Graph {
-	// A is the queried version
-	versionA := queriedVersion
-
-	// B: Same minor, patch bumped by one, drop prerelease
-	versionB := queriedVersion
-	versionB.Patch++
-	versionB.Pre = nil
-
-	// C: Minor bumped by one, patch set to zero, drop prerelease
-	versionC := queriedVersion
-	versionC.Minor++
-	versionC.Patch = 0
-	versionC.Pre = nil
-
-	nodeA := NewNodeWithChannelsMetadata(versionA, s.formatChannelsForMetadata(versionA))
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNode(versionC, channel)
-
-	nodeA.SetArchitecture(arch)
-	nodeB.SetArchitecture(arch)
-	nodeC.SetArchitecture(arch)
-
-	// Create conditional edges with SyntheticRisk using PromQL that always evaluates to 1
-	conditionalEdges := []ConditionalEdge{
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionA.String(),
-					To:   versionB.String(),
-				},
-				{
-					From: versionA.String(),
-					To:   versionC.String(),
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-promql",
-					Name:    "SyntheticRisk",
-					Message: "This is a synthetic risk with PromQL that always matches in OpenShift clusters",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "vector(1)",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return Graph{
-		Nodes:            []Node{nodeA, nodeB, nodeC},
-		Edges:            []Edge{}, // No unconditional edges, only conditional
-		ConditionalEdges: conditionalEdges,
-	}
-}
-
-func (s *Server) generateRisksCannotEvaluateGraph(queriedVersion semver.Version, arch string, channel string) Graph {
-	// A is the queried version
-	versionA := queriedVersion
-
-	// B: Same minor, patch bumped by one, drop prerelease
-	versionB := queriedVersion
-	versionB.Patch++
-	versionB.Pre = nil
-
-	// C: Minor bumped by one, patch set to zero, drop prerelease
-	versionC := queriedVersion
-	versionC.Minor++
-	versionC.Patch = 0
-	versionC.Pre = nil
-
-	nodeA := NewNodeWithChannelsMetadata(versionA, s.formatChannelsForMetadata(versionA))
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNode(versionC, channel)
-
-	nodeA.SetArchitecture(arch)
-	nodeB.SetArchitecture(arch)
-	nodeC.SetArchitecture(arch)
-
-	// Create conditional edges with SyntheticRisk using PromQL that always evaluates to 1
-	conditionalEdges := []ConditionalEdge{
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionA.String(),
-					To:   versionB.String(),
-				},
-				{
-					From: versionA.String(),
-					To:   versionC.String(),
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-promql",
-					Name:    "SyntheticRisk",
-					Message: "This is a synthetic risk with PromQL that cannot be evaluated in OpenShift clusters",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "this will fail; muahaha",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return Graph{
-		Nodes:            []Node{nodeA, nodeB, nodeC},
-		Edges:            []Edge{}, // No unconditional edges, only conditional
-		ConditionalEdges: conditionalEdges,
-	}
-}
-
-func (s *Server) generateRisksNonmatchingGraph(queriedVersion semver.Version, arch string, channel string) Graph {
-	// A is the queried version
-	versionA := queriedVersion
-
-	// B: Same minor, patch bumped by one, drop prerelease
-	versionB := queriedVersion
-	versionB.Patch++
-	versionB.Pre = nil
-
-	// C: Minor bumped by one, patch set to zero, drop prerelease
-	versionC := queriedVersion
-	versionC.Minor++
-	versionC.Patch = 0
-	versionC.Pre = nil
-
-	nodeA := NewNodeWithChannelsMetadata(versionA, s.formatChannelsForMetadata(versionA))
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNode(versionC, channel)
-
-	nodeA.SetArchitecture(arch)
-	nodeB.SetArchitecture(arch)
-	nodeC.SetArchitecture(arch)
-
-	// Create conditional edges with SyntheticRisk using PromQL that never evaluates to true
-	conditionalEdges := []ConditionalEdge{
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionA.String(),
-					To:   versionB.String(),
-				},
-				{
-					From: versionA.String(),
-					To:   versionC.String(),
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-promql-nonmatching",
-					Name:    "SyntheticRisk",
-					Message: "This is a synthetic risk with PromQL that never matches in OpenShift clusters",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "vector(0)",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
 
-	return Graph{
-		Nodes:            []Node{nodeA, nodeB, nodeC},
-		Edges:            []Edge{}, // No unconditional edges, only conditional
-		ConditionalEdges: conditionalEdges,
+	var missing []string
+	if channel == "" {
+		missing = append(missing, "channel")
 	}
-}
-
-func (s *Server) generateSmokeTestGraph(queriedVersion semver.Version, arch string, channel string) Graph {
-	// E is the queried version
-	versionE := queriedVersion
-
-	nodeE := NewNodeWithChannelsMetadata(versionE, s.formatChannelsForMetadata(versionE))
-	nodeE.SetArchitecture(arch)
-
-	// D is one version back (decrement minor, reset patch to 0, drop prerelease)
-	versionD := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor - 1,
-		Patch: 0,
+	if version == "" {
+		missing = append(missing, "version")
 	}
-
-	// F is one patch ahead of D (so D=4.16.0, F=4.16.1)
-	versionF := semver.Version{
-		Major: versionD.Major,
-		Minor: versionD.Minor,
-		Patch: versionD.Patch + 1,
+	if len(missing) > 0 {
+		fail(http.StatusBadRequest, ErrorKindMissingParams, fmt.Sprintf("mandatory client parameters missing: %s", strings.Join(missing, ", ")))
+		return
 	}
 
-	// G is one patch ahead of E (so E=4.17.5, G=4.17.6)
-	versionG := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor,
-		Patch: versionE.Patch + 1,
+	parsedVersion, err := semver.Parse(version)
+	if err != nil {
+		fail(http.StatusBadRequest, ErrorKindInvalidParams, fmt.Sprintf("invalid version %q: %v", version, err))
+		return
 	}
 
-	// H is one minor ahead of E (so E=4.17.5, H=4.18.0)
-	versionH := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor + 1,
-		Patch: 0,
+	if arch != "" {
+		if err := validateArch(arch); err != nil {
+			fail(http.StatusBadRequest, ErrorKindInvalidParams, err.Error())
+			return
+		}
 	}
 
-	// I is 4.17.7 (for conditional edge with RiskA:Always)
-	versionI := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor,
-		Patch: 7,
+	served, timelineSession := channel, ""
+	if recorded.ID != "" {
+		served, timelineSession = s.sessionChannel(recorded.ID, channel)
+		if served != channel {
+			recorded.Scenario = served
+		}
 	}
-
-	// J is 4.18.1 (for conditional edge with RiskA:Always)
-	versionJ := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor + 1,
-		Patch: 1,
+	registered, ok := s.channelRegistry().Lookup(served)
+	known = ok
+	if !ok && s.strict {
+		fail(http.StatusBadRequest, ErrorKindInvalidParams, fmt.Sprintf("unknown channel %q", channel))
+		return
 	}
-
-	// K is 4.17.8 (for conditional edge with RiskBMatches:PromQL)
-	versionK := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor,
-		Patch: 8,
+	fault, err := faultProfileFromQuery(query, registered.Fault)
+	if err != nil {
+		fail(http.StatusBadRequest, ErrorKindInvalidParams, fmt.Sprintf("invalid fault parameters: %v", err))
+		return
 	}
-
-	// L is 4.18.2 (for conditional edge with RiskBMatches:PromQL)
-	versionL := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor + 1,
-		Patch: 2,
+	if fault != nil && !fault.triggered() {
+		fault = nil
 	}
-
-	// M is 4.17.9 (for conditional edge with RiskCNoMatch:PromQL)
-	versionM := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor,
-		Patch: 9,
+	if fault != nil {
+		recorded.Fault = fault.Type
+		if !fault.delay(r) {
+			return
+		}
 	}
 
-	// N is 4.18.3 (for conditional edge with RiskCNoMatch:PromQL)
-	versionN := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor + 1,
-		Patch: 3,
+	var graph Graph
+	if ok {
+		if len(registered.states) > 0 {
+			graph = s.generateTimelineGraph(registered, timelineSession, parsedVersion, arch, channel)
+		} else {
+			graph = registered.Generate(parsedVersion, arch, channel)
+		}
+		s.countTimelineRequest(registered, timelineSession)
+	} else {
+		graph = s.generateEmptyGraph("")
 	}
+	recorded.Nodes, recorded.Edges, recorded.ConditionalEdges = len(graph.Nodes), len(graph.Edges), len(graph.ConditionalEdges)
+	recorded.Error = graph.Error
 
-	// O is 4.17.10 (for conditional edge with combined risks)
-	versionO := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor,
-		Patch: 10,
+	body, err := s.encodeGraph(graph, mediaType, channel)
+	if err != nil {
+		fail(http.StatusInternalServerError, ErrorKindFailedJSONOut, fmt.Sprintf("failed to encode the graph: %v", err))
+		return
 	}
-
-	// P is 4.18.4 (for conditional edge with combined risks)
-	versionP := semver.Version{
-		Major: versionE.Major,
-		Minor: versionE.Minor + 1,
-		Patch: 4,
+	if fault != nil {
+		fault.write(s.logger, w, r, body, mediaType)
+		return
 	}
+	w.Header().Set("Content-Type", mediaType)
+	_, _ = w.Write(body)
+}
 
-	nodeD := NewNode(versionD, "smoke-test")
-
-	nodeF := NewNode(versionF, "smoke-test")
-
-	nodeG := NewNode(versionG, "smoke-test")
-
-	nodeH := NewNode(versionH, "smoke-test")
-
-	nodeI := NewNode(versionI, "smoke-test")
-
-	nodeJ := NewNode(versionJ, "smoke-test")
-
-	nodeK := NewNode(versionK, "smoke-test")
-
-	nodeL := NewNode(versionL, "smoke-test")
-
-	nodeM := NewNode(versionM, "smoke-test")
-
-	nodeN := NewNode(versionN, "smoke-test")
-
-	nodeO := NewNode(versionO, "smoke-test")
-
-	nodeP := NewNode(versionP, "smoke-test")
-
-	nodeD.SetArchitecture(arch)
-	nodeF.SetArchitecture(arch)
-	nodeG.SetArchitecture(arch)
-	nodeH.SetArchitecture(arch)
-	nodeI.SetArchitecture(arch)
-	nodeJ.SetArchitecture(arch)
-	nodeK.SetArchitecture(arch)
-	nodeL.SetArchitecture(arch)
-	nodeM.SetArchitecture(arch)
-	nodeN.SetArchitecture(arch)
-	nodeO.SetArchitecture(arch)
-	nodeP.SetArchitecture(arch)
-
-	// Create conditional edges with mixed risk types
-	conditionalEdges := []ConditionalEdge{
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionE.String(),
-					To:   versionI.String(), // E -> I (4.17.5 -> 4.17.7)
-				},
-				{
-					From: versionE.String(),
-					To:   versionJ.String(), // E -> J (4.17.5 -> 4.18.1)
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke",
-					Name:    "RiskA",
-					Message: "This is a synthetic risk with Always type for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "Always",
-						},
-					},
-				},
-			},
-		},
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionE.String(),
-					To:   versionK.String(), // E -> K (4.17.5 -> 4.17.8)
-				},
-				{
-					From: versionE.String(),
-					To:   versionL.String(), // E -> L (4.17.5 -> 4.18.2)
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke-promql",
-					Name:    "RiskBMatches",
-					Message: "This is a synthetic risk with PromQL that matches for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "vector(1)",
-							},
-						},
-					},
-				},
-			},
-		},
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionE.String(),
-					To:   versionM.String(), // E -> M (4.17.5 -> 4.17.9)
-				},
-				{
-					From: versionE.String(),
-					To:   versionN.String(), // E -> N (4.17.5 -> 4.18.3)
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke-promql-nomatch",
-					Name:    "RiskCNoMatch",
-					Message: "This is a synthetic risk with PromQL that never matches for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "vector(0)",
-							},
-						},
-					},
-				},
-			},
-		},
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: versionE.String(),
-					To:   versionO.String(), // E -> O (4.17.5 -> 4.17.10)
-				},
-				{
-					From: versionE.String(),
-					To:   versionP.String(), // E -> P (4.17.5 -> 4.18.4)
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke-combined-a",
-					Name:    "RiskA",
-					Message: "This is RiskA part of combined risks for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "Always",
-						},
-					},
-				},
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke-combined-b",
-					Name:    "RiskBMatches",
-					Message: "This is RiskBMatches part of combined risks for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "vector(1)",
-							},
-						},
-					},
-				},
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke-combined-c",
-					Name:    "RiskCNoMatch",
-					Message: "This is RiskCNoMatch part of combined risks for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "vector(0)",
-							},
-						},
-					},
-				},
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-smoke-combined-d",
-					Name:    "RiskDCannotEvaluate",
-					Message: "This is RiskDCannotEvaluate part of combined risks for smoke testing",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "this will fail; muahaha",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
+func NewNodeWithNodeBuilder(resolver DigestResolver, latestCandidate func(client Client, major, minor uint64) (semver.Version, error), client Client, queriedVersion, v semver.Version, channels []string, arch string) Node {
+	b := &NodeBuilder{}
+	b.WithArchitecture(arch).WithChannels(channels).WithVersion(v).withQueriedVersion(queriedVersion).
+		WithDigestResolver(resolver).WithClient(client).withGetLatest(latestCandidate)
+	return b.Build()
+}
 
-	return Graph{
-		Nodes:            []Node{nodeD, nodeE, nodeF, nodeG, nodeH, nodeI, nodeJ, nodeK, nodeL, nodeM, nodeN, nodeO, nodeP},
-		Edges:            []Edge{{0, 1}, {0, 2}, {1, 3}, {1, 4}}, // D -> E, D -> F, E -> G, E -> H
-		ConditionalEdges: conditionalEdges,
-	}
+// newNode builds the node of version v for a client querying queriedVersion, resolving its payload with the server
+// digest resolver
+func (s *Server) newNode(queriedVersion, v semver.Version, channels []string, arch string) Node {
+	b := &NodeBuilder{}
+	b.WithArchitecture(arch).WithChannels(channels).WithVersion(v).withQueriedVersion(queriedVersion).
+		WithDigestResolver(s.digestResolver).WithClient(s.client).withGetLatest(s.candidatesGetter.latestCandidate).
+		WithLogger(s.logger)
+	return b.Build()
 }
 
 func (s *Server) generateEmptyGraph(error string) Graph {
@@ -805,31 +298,14 @@ func (s *Server) generateEmptyGraph(error string) Graph {
 	}
 }
 
-// AIDEV-NOTE: Helper to determine which channels contain the queried version
-// Currently channel-head, simple, risks-always, risks-matching, risks-nonmatching, and smoke-test contain the queried version, but this will expand
-// as more channels are added that include the queried version in their graphs
-func (s *Server) getChannelsContainingVersion(version semver.Version) []string {
-	var channels []string
-
-	// Channels that contain the queried version
-	channels = append(channels, "channel-head")
-	channels = append(channels, "risks-always")
-	channels = append(channels, "risks-matching")
-	channels = append(channels, "risks-nonmatching")
-	channels = append(channels, "simple")
-	channels = append(channels, "smoke-test")
+// AIDEV-NOTE: Channels metadata of nodes listed in all channels
+// The list does not depend on the node version: channels declare once, when they are registered in the
+// ChannelRegistry, whether their graphs contain the queried version, and all those channels are listed
 
-	// Future channels that contain the queried version will be added here
-
-	return channels
-}
-
-// AIDEV-NOTE: Format channel list for metadata field
-// Returns comma-separated sorted list of channels containing the version
-func (s *Server) formatChannelsForMetadata(version semver.Version) string {
-	channels := s.getChannelsContainingVersion(version)
-	sort.Strings(channels) // Ensure consistent ordering
-	return strings.Join(channels, ",")
+// formatChannelsForMetadata returns the comma-separated sorted list of channels whose graphs contain the queried
+// version
+func (s *Server) formatChannelsForMetadata() string {
+	return strings.Join(s.channelRegistry().ChannelsContainingVersion(), ",")
 }
 
 func (s *Server) generateRootHTML(host string) string {
@@ -841,26 +317,18 @@ func (s *Server) generateRootHTML(host string) string {
 	apiURL := fmt.Sprintf("%s/api/upgrades_info/graph", baseURL)
 	exampleVersion := "4.18.42"
 
-	// Generate live examples for each channel
-	channelNames := []string{"version-not-found", "channel-head", "simple", "risks-always", "risks-matching", "risks-nonmatching", "risks-cannot-evaluate", "smoke-test"}
-	channelDescriptions := []string{
-		"Three-node graph excluding the requested version. Creates a forward progression path.",
-		"Three-node graph where the client's version is the head. Shows upgrade history.",
-		"Three-node linear progression from the client's version. Basic upgrade path.",
-		"Three-node graph with conditional edges that always block updates (Always matching rule).",
-		"Three-node graph with PromQL conditional edges that match (PromQL: vector(1)).",
-		"Three-node graph with PromQL conditional edges that don't match (PromQL: vector(0)).",
-		"Three-node graph with PromQL conditional edges that cannot be evaluated (PromQL: this will fail; muahaha).",
-		"Comprehensive 13-node graph with mixed conditional edges for testing all Cincinnati features.",
-	}
-
+	// Generate live examples for each channel; graphs of remote channels need upstream requests
 	var channels []ChannelInfo
-	for i, name := range channelNames {
-		curlCmd := fmt.Sprintf(`curl "%s?channel=%s&version=%s&arch=amd64"`, apiURL, name, exampleVersion)
+	for _, channel := range s.channelRegistry().Channels() {
+		curlCmd := fmt.Sprintf(`curl "%s?channel=%s&version=%s&arch=amd64"`, apiURL, channel.Name, exampleVersion)
+		example := "Built from upstream data, query the channel to see the graph"
+		if !channel.Remote {
+			example = s.generateChannelExample(channel, exampleVersion)
+		}
 		channels = append(channels, ChannelInfo{
-			Name:        name,
-			Description: channelDescriptions[i],
-			Example:     s.generateChannelExample(name, exampleVersion),
+			Name:        channel.Name,
+			Description: channel.Description,
+			Example:     example,
 			CurlCommand: curlCmd,
 		})
 	}
@@ -904,6 +372,7 @@ func (s *Server) generateRootHTML(host string) string {
     <ul>
         <li><code>arch</code> - Architecture (e.g., <code>amd64</code>)</li>
     </ul>
+    <p><strong>Representations:</strong> JSON by default; send <code>Accept: application/yaml</code>, <code>text/plain</code> or <code>text/vnd.graphviz</code> for YAML, text or Graphviz DOT</p>
 
     <h2>📋 Available Channels</h2>
     <p>All examples below use version <strong>{{.ExampleVersion}}</strong> to show live graph structures:</p>
@@ -954,32 +423,13 @@ func (s *Server) generateRootHTML(host string) string {
 	return buf.String()
 }
 
-func (s *Server) generateChannelExample(channel, version string) string {
+func (s *Server) generateChannelExample(channel Channel, version string) string {
 	parsedVersion, err := semver.Parse(version)
 	if err != nil {
 		return fmt.Sprintf("Error parsing version %s: %v", version, err)
 	}
 
-	var graph Graph
-	switch channel {
-	case "version-not-found":
-		graph = s.generateVersionNotFoundGraph(parsedVersion, "amd64", channel)
-	case "channel-head":
-		graph = s.generateChannelHeadGraph(parsedVersion, "amd64", channel)
-	case "simple":
-		graph = s.generateSimpleGraph(parsedVersion, "amd64", channel)
-	case "risks-always":
-		graph = s.generateRisksAlwaysGraph(parsedVersion, "amd64", channel)
-	case "risks-matching":
-		graph = s.generateRisksMatchingGraph(parsedVersion, "amd64", channel)
-	case "risks-nonmatching":
-		graph = s.generateRisksNonmatchingGraph(parsedVersion, "amd64", channel)
-	case "smoke-test":
-		graph = s.generateSmokeTestGraph(parsedVersion, "amd64", channel)
-	default:
-		return "Unknown channel"
-	}
-
+	graph := channel.Generate(parsedVersion, "amd64", channel.Name)
 	return s.graphToASCII(graph)
 }
 
@@ -1343,38 +793,30 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
-	s.healthCheck(w, r)
+	s.healthCheck(w, r, "OK")
 }
 
 func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
-	s.healthCheck(w, r)
+	s.healthCheck(w, r, fmt.Sprintf("OK\nscenario generation: %d\n", s.ScenarioGeneration()))
 }
 
-func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+// AIDEV-NOTE: Health checks render a graph without routing it through handleGraph
+// Probes must not depend on which channels are served (strict mode rejects unknown channels), must not make upstream
+// requests and must stay out of the recorded requests and the graph request metrics.
+func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request, message string) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	req := r.Clone(r.Context())
-	req.URL.Path = "/api/upgrades_info/graph"
-	req.URL.RawQuery = "channel=stable-4.17&version=4.17.0"
-
-	rec := &healthResponseRecorder{
-		statusCode: http.StatusOK,
-		headers:    make(http.Header),
-	}
-
-	s.handleGraph(rec, req)
-
-	if rec.statusCode != http.StatusOK {
+	if _, err := s.encodeGraph(s.generateEmptyGraph(""), MediaTypeJSON, ""); err != nil {
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	w.Write([]byte(message))
 }
 
 func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
@@ -1388,44 +830,27 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-type healthResponseRecorder struct {
-	statusCode int
-	headers    http.Header
-}
-
-func (h *healthResponseRecorder) Header() http.Header {
-	return h.headers
-}
-
-func (h *healthResponseRecorder) Write([]byte) (int, error) {
-	return 0, nil
-}
-
-func (h *healthResponseRecorder) WriteHeader(statusCode int) {
-	h.statusCode = statusCode
-}
-
 func (s *Server) generateOCP88175Graph(queriedVersion semver.Version, arch string, channel string, promQL bool) Graph {
 	versions, err := s.candidatesGetter.candidates(s.client, queriedVersion.Major, queriedVersion.Minor)
 	if err != nil {
-		logrus.WithError(err).Warning("Failed to get candidate")
+		s.logger.WithError(err).Warning("Failed to get candidate")
 		return s.generateEmptyGraph(fmt.Sprintf("failed to get candidates for %s", queriedVersion.String()))
 	}
 	if l := len(versions); l < 4 {
-		logrus.WithField("queriedVersion", queriedVersion.String()).Warning("Failed to get 4 candidates")
+		s.logger.WithField("queriedVersion", queriedVersion.String()).Warning("Failed to get 4 candidates")
 		return s.generateEmptyGraph(fmt.Sprintf("failed to find enough (4) candidates for %s: %d", queriedVersion.String(), l))
 	}
 	latest4 := versions[len(versions)-4]
 	if latest4.LTE(queriedVersion) {
-		logrus.WithField("latest4", latest4.String()).WithField("queriedVersion", queriedVersion.String()).Warning("Failed to get 4 update paths")
+		s.logger.WithField("latest4", latest4.String()).WithField("queriedVersion", queriedVersion.String()).Warning("Failed to get 4 update paths")
 		return s.generateEmptyGraph(fmt.Sprintf("failed to find enough (4) update paths for %s", queriedVersion.String()))
 	}
 
-	nodeA := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, queriedVersion, []string{channel}, arch)
-	nodeB := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versions[len(versions)-4], []string{channel}, arch)
-	nodeC := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versions[len(versions)-3], []string{channel}, arch)
-	nodeD := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versions[len(versions)-2], []string{channel}, arch)
-	nodeE := NewNodeWithNodeBuilder(s.digestResolver, s.candidatesGetter.latestCandidate, s.client, queriedVersion, versions[len(versions)-1], []string{channel}, arch)
+	nodeA := s.newNode(queriedVersion, queriedVersion, []string{channel}, arch)
+	nodeB := s.newNode(queriedVersion, versions[len(versions)-4], []string{channel}, arch)
+	nodeC := s.newNode(queriedVersion, versions[len(versions)-3], []string{channel}, arch)
+	nodeD := s.newNode(queriedVersion, versions[len(versions)-2], []string{channel}, arch)
+	nodeE := s.newNode(queriedVersion, versions[len(versions)-1], []string{channel}, arch)
 
 	rule := MatchingRule{Type: "Always"}
 	if promQL {
@@ -1501,52 +926,3 @@ func (s *Server) generateOCP88175Graph(queriedVersion semver.Version, arch strin
 		ConditionalEdges: conditionalEdges,
 	}
 }
-
-func (s *Server) generateOTA1813Graph(queriedVersion semver.Version, arch string, channel string) Graph {
-
-	versionA := queriedVersion
-
-	versionB := queriedVersion
-	versionB.Patch++
-	versionB.Pre = nil
-
-	versionC := versionB
-	versionC.Patch++
-	versionC.Pre = nil
-
-	nodeA := NewNode(versionA, channel)
-	nodeB := NewNode(versionB, channel)
-	nodeC := NewNode(versionC, channel)
-
-	conditionalEdges := []ConditionalEdge{
-		{
-			Edges: []ConditionalUpdate{
-				{
-					From: nodeA.Version.String(),
-					To:   nodeC.Version.String(),
-				},
-			},
-			Risks: []ConditionalUpdateRisk{
-				{
-					URL:     "https://docs.openshift.com/synthetic-risk-a",
-					Name:    "SomeInvokerThing",
-					Message: "This is SomeInvokerThing that cannot be evaluated for testing purposes",
-					MatchingRules: []MatchingRule{
-						{
-							Type: "PromQL",
-							PromQL: &PromQLQuery{
-								PromQL: "this will fail; muahaha",
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return Graph{
-		Nodes:            []Node{nodeA, nodeB, nodeC},
-		Edges:            []Edge{{0, 1}},
-		ConditionalEdges: conditionalEdges,
-	}
-}
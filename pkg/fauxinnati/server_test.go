@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"net/http"
 	"net/http/httptest"
 	"slices"
 	"sort"
@@ -116,6 +117,19 @@ func toStrings(targetSemVers []semver.Version) []string {
 	return edges
 }
 
+func generateChannelGraph(t *testing.T, server *Server, name string, version semver.Version, arch string) Graph {
+	t.Helper()
+	channel, ok := server.channelRegistry().Lookup(name)
+	if !ok {
+		t.Fatalf("channel %s is not registered", name)
+	}
+	graph := channel.Generate(version, arch, name)
+	for _, problem := range Validate(graph) {
+		t.Errorf("channel %s generated an invalid graph: %s", name, problem)
+	}
+	return graph
+}
+
 func TestServer_handleGraph(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -405,7 +419,7 @@ func TestServer_generateVersionNotFoundGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateVersionNotFoundGraph(tt.baseVersion, tt.arch, tt.channel)
+			result := generateChannelGraph(t, server, tt.channel, tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -450,7 +464,39 @@ func TestServer_generateChannelHeadGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateChannelHeadGraph(tt.baseVersion, tt.arch, "channel-head")
+			result := generateChannelGraph(t, server, "channel-head", tt.baseVersion, tt.arch)
+			testhelper.CompareWithFixture(t, result)
+		})
+	}
+}
+
+func TestServer_generateHeterogeneousMigrationGraph(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseVersion semver.Version
+		arch        string
+	}{
+		{
+			name:        "generates A->B->C graph for single-arch clusters",
+			baseVersion: semver.MustParse("4.17.5"),
+			arch:        "amd64",
+		},
+		{
+			name:        "generates A->B->C graph with arch-specific payloads",
+			baseVersion: semver.MustParse("4.17.5"),
+			arch:        "arm64",
+		},
+		{
+			name:        "generates A->B graph for clusters migrating to multi-arch",
+			baseVersion: semver.MustParse("4.17.5"),
+			arch:        "multi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			result := generateChannelGraph(t, server, "heterogeneous-migration", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -472,7 +518,7 @@ func TestServer_generateSimpleGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateSimpleGraph(tt.baseVersion, tt.arch, "simple")
+			result := generateChannelGraph(t, server, "simple", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -494,7 +540,7 @@ func TestServer_generateRisksAlwaysGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateRisksAlwaysGraph(tt.baseVersion, tt.arch, "risks-always")
+			result := generateChannelGraph(t, server, "risks-always", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -516,7 +562,7 @@ func TestServer_generateRisksMatchingGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateRisksMatchingGraph(tt.baseVersion, tt.arch, "risks-matching")
+			result := generateChannelGraph(t, server, "risks-matching", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -538,7 +584,7 @@ func TestServer_generateRisksCannotEvaluateGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateRisksCannotEvaluateGraph(tt.baseVersion, tt.arch, "risks-cannot-evaluate")
+			result := generateChannelGraph(t, server, "risks-cannot-evaluate", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -560,7 +606,7 @@ func TestServer_generateRisksNonmatchingGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateRisksNonmatchingGraph(tt.baseVersion, tt.arch, "risks-nonmatching")
+			result := generateChannelGraph(t, server, "risks-nonmatching", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -582,7 +628,7 @@ func TestServer_generateSmokeTestGraph(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server := NewServer()
-			result := server.generateSmokeTestGraph(tt.baseVersion, tt.arch, "smoke-test")
+			result := generateChannelGraph(t, server, "smoke-test", tt.baseVersion, tt.arch)
 			testhelper.CompareWithFixture(t, result)
 		})
 	}
@@ -631,6 +677,72 @@ func TestServer_setupRoutes(t *testing.T) {
 	}
 }
 
+func TestServer_healthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{name: "default server"},
+		{name: "strict channels", options: []Option{WithStrictChannels()}},
+	}
+
+	for _, tt := range tests {
+		for _, path := range []string{"/healthz", "/readyz"} {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				server := NewServer(tt.options...)
+				w := httptest.NewRecorder()
+				server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+				}
+				if requests := server.RecordedRequests(RequestFilter{}); len(requests) != 0 {
+					t.Errorf("expected health checks not to be recorded, got %+v", requests)
+				}
+			})
+		}
+	}
+}
+
+// fakeDigestResolver resolves digests from a map of tags to digests and fails for unknown tags
+type fakeDigestResolver map[string]string
+
+func (r fakeDigestResolver) Digest(_ Client, tag string) (string, error) {
+	digest, ok := r[tag]
+	if !ok {
+		return "", fmt.Errorf("unknown tag %s", tag)
+	}
+	return digest, nil
+}
+
+func (r fakeDigestResolver) Repository() string {
+	return "quay.io/openshift-release-dev/ocp-release"
+}
+
+var testDigestResolver = fakeDigestResolver{
+	"4.22.0-ec.0-x86_64": "sha256:fa50ad76be25a54235058782d1844a236f64a9a0e9764eedb885ccf1565286c4",
+	"4.22.0-ec.1-x86_64": "sha256:eea721e62d3a06a742adc3d10d9c430af061694d558da9a8d9a17c52a342ddd4",
+	"4.22.0-ec.2-x86_64": "sha256:fc88d0bf145c81989a4116bbb0e3d2724d9ab937efb7d217a10e7d7ff3031c50",
+	"4.22.0-ec.3-x86_64": "sha256:58b98da1492b3f4af6129c4684b8e8cde4f2dc197e4b483bb6025971d59f92a5",
+}
+
+func TestServer_generateRootHTML_Offline(t *testing.T) {
+	client := &fakeClient{graph: proxyTestGraph()}
+	server := NewServer(WithHTTPClient(client))
+	if err := server.RegisterProxyChannel(&ProxyChannel{Name: "stable-4.17", Upstream: "https://upstream.example.com/graph"}); err != nil {
+		t.Fatalf("failed to register proxy channel: %v", err)
+	}
+
+	html := server.generateRootHTML("localhost:8080")
+	if len(client.requests) != 0 {
+		t.Errorf("expected the landing page to make no upstream requests, got %v", client.requests)
+	}
+	for _, channel := range []string{"OCP-88175", "risks-always", "stable-4.17"} {
+		if !strings.Contains(html, "<h3>"+channel+"</h3>") {
+			t.Errorf("expected the landing page to list the remote channel %s", channel)
+		}
+	}
+}
+
 func TestServer_generateOCP88175Graph(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -654,7 +766,7 @@ func TestServer_generateOCP88175Graph(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			server := NewServer()
+			server := NewServer(WithCandidatesSource(NewGraphDataDirCandidatesSource("testdata/graph-data")), WithDigestResolver(testDigestResolver))
 			result := server.generateOCP88175Graph(tt.baseVersion, tt.arch, tt.channel, tt.promQL)
 			testhelper.CompareWithFixture(t, result)
 		})
@@ -0,0 +1,258 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// DefaultSessionIdleTimeout is how long servers keep sessions of clusters that stopped polling
+const DefaultSessionIdleTimeout = 30 * time.Minute
+
+// DefaultMaxImplicitSessions is how many sessions started by graph requests servers keep; the least recently seen
+// ones are forgotten first
+const DefaultMaxImplicitSessions = 1000
+
+// AIDEV-NOTE: Sessions are keyed by the cluster UUID the CVO sends as the id parameter
+// Any graph request with an id starts an implicit session that only tracks the polling of the cluster; it shares
+// timelines with requests without an id, so /api/debug/timelines observes and resets what clusters see. Only
+// sessions created over the admin API (/api/admin/sessions) get their own timeline positions and serve other channels (usually
+// scenarios) in place of the requested ones.
+
+// session is the state kept for one cluster
+type session struct {
+	id string
+	// scenarios maps requested channels to the channels served in their place
+	scenarios map[string]string
+	createdAt time.Time
+	lastSeen  time.Time
+	requests  int
+	// explicit sessions were created over the API and have their own timelines
+	explicit bool
+}
+
+// SessionStatus describes the session of a cluster
+type SessionStatus struct {
+	ID string `json:"id"`
+	// Scenarios maps requested channels to the channels (usually scenarios) served in their place
+	Scenarios map[string]string `json:"scenarios,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	LastSeen  time.Time         `json:"lastSeen"`
+	// ExpiresAt is when the session is forgotten unless the cluster polls again
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Requests is the number of graph requests of the session
+	Requests  int              `json:"requests"`
+	Timelines []TimelineStatus `json:"timelines"`
+}
+
+// CreateSession creates the session of cluster id, serving the mapped channels in place of the requested ones.
+// An existing session of the cluster is replaced, restarting its timelines.
+func (s *Server) CreateSession(id string, scenarios map[string]string) (SessionStatus, error) {
+	if id == "" {
+		return SessionStatus{}, fmt.Errorf("session has no id")
+	}
+	registry := s.channelRegistry()
+	for _, requested := range slices.Sorted(maps.Keys(scenarios)) {
+		if requested == "" {
+			return SessionStatus{}, fmt.Errorf("session %s: scenario mapped from an empty channel", id)
+		}
+		if _, ok := registry.Lookup(scenarios[requested]); !ok {
+			return SessionStatus{}, fmt.Errorf("session %s: channel %s is mapped to unknown scenario %q", id, requested, scenarios[requested])
+		}
+	}
+
+	now := s.clock.Now()
+	created := &session{id: id, scenarios: maps.Clone(scenarios), createdAt: now, lastSeen: now, explicit: true}
+	s.sessionsLock.Lock()
+	s.expireSessions(now)
+	s.sessions[id] = created
+	s.forgetTimelines(id)
+	status := s.sessionStatus(created)
+	s.sessionsLock.Unlock()
+	return status, nil
+}
+
+// Sessions returns all sessions sorted by id
+func (s *Server) Sessions() []SessionStatus {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	s.expireSessions(s.clock.Now())
+	statuses := make([]SessionStatus, 0, len(s.sessions))
+	for _, id := range slices.Sorted(maps.Keys(s.sessions)) {
+		statuses = append(statuses, s.sessionStatus(s.sessions[id]))
+	}
+	return statuses
+}
+
+// Session returns the session of cluster id
+func (s *Server) Session(id string) (SessionStatus, bool) {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	s.expireSessions(s.clock.Now())
+	found, ok := s.sessions[id]
+	if !ok {
+		return SessionStatus{}, false
+	}
+	return s.sessionStatus(found), true
+}
+
+// DeleteSession forgets the session of cluster id, and reports whether it existed
+func (s *Server) DeleteSession(id string) bool {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	s.expireSessions(s.clock.Now())
+	if _, ok := s.sessions[id]; !ok {
+		return false
+	}
+	delete(s.sessions, id)
+	s.forgetTimelines(id)
+	return true
+}
+
+// sessionChannel records a graph request of cluster id for the channel, starting an implicit session if the cluster
+// has none. It returns the channel served in its place and the session whose timelines are served, empty for the
+// shared timelines.
+func (s *Server) sessionChannel(id, channel string) (string, string) {
+	now := s.clock.Now()
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	s.expireSessions(now)
+	current, ok := s.sessions[id]
+	if !ok {
+		s.limitImplicitSessions(s.maxImplicitSessions - 1)
+		current = &session{id: id, createdAt: now}
+		s.sessions[id] = current
+	}
+	current.lastSeen = now
+	current.requests++
+	timelineSession := ""
+	if current.explicit {
+		timelineSession = id
+	}
+	if served, ok := current.scenarios[channel]; ok {
+		return served, timelineSession
+	}
+	return channel, timelineSession
+}
+
+// limitImplicitSessions forgets the least recently seen implicit sessions until at most limit are left;
+// sessionsLock must be held
+func (s *Server) limitImplicitSessions(limit int) {
+	var implicit []*session
+	for _, current := range s.sessions {
+		if !current.explicit {
+			implicit = append(implicit, current)
+		}
+	}
+	if len(implicit) <= limit {
+		return
+	}
+	slices.SortFunc(implicit, func(a, b *session) int { return a.lastSeen.Compare(b.lastSeen) })
+	for _, current := range implicit[:len(implicit)-max(limit, 0)] {
+		delete(s.sessions, current.id)
+	}
+}
+
+// expireSessions forgets sessions idle for longer than the idle timeout; sessionsLock must be held
+func (s *Server) expireSessions(now time.Time) {
+	for id, idle := range s.sessions {
+		if now.Sub(idle.lastSeen) > s.sessionIdleTimeout {
+			delete(s.sessions, id)
+			s.forgetTimelines(id)
+		}
+	}
+}
+
+// sessionStatus describes the session; sessionsLock must be held
+func (s *Server) sessionStatus(current *session) SessionStatus {
+	return SessionStatus{
+		ID:        current.id,
+		Scenarios: maps.Clone(current.scenarios),
+		CreatedAt: current.createdAt.UTC(),
+		LastSeen:  current.lastSeen.UTC(),
+		ExpiresAt: current.lastSeen.Add(s.sessionIdleTimeout).UTC(),
+		Requests:  current.requests,
+		Timelines: s.timelineStatuses(current.id),
+	}
+}
+
+type sessionsResponse struct {
+	Sessions []SessionStatus `json:"sessions"`
+}
+
+// sessionRequest is the body of requests creating sessions
+type sessionRequest struct {
+	ID        string            `json:"id"`
+	Scenarios map[string]string `json:"scenarios"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+func (s *Server) handleDebugSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionsResponse{Sessions: s.Sessions()})
+}
+
+func (s *Server) handleDebugSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	status, ok := s.Session(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("session %s not found", id), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleAdminSessions creates sessions; changing which channels a cluster is served needs the admin token
+func (s *Server) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request sessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid session: %v", err), http.StatusBadRequest)
+		return
+	}
+	status, err := s.CreateSession(request.ID, request.Scenarios)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, status)
+}
+
+func (s *Server) handleAdminSession(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	if !s.DeleteSession(id) {
+		http.Error(w, fmt.Sprintf("session %s not found", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
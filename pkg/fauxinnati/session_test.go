@@ -0,0 +1,262 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func TestServer_CreateSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		scenarios   map[string]string
+		expectedErr error
+	}{
+		{
+			name:      "maps channels to scenarios",
+			id:        "cluster-a",
+			scenarios: map[string]string{"stable-4.17": "release-train", "fast-4.17": "simple"},
+		},
+		{
+			name: "session without scenarios",
+			id:   "cluster-a",
+		},
+		{
+			name:        "session without id is rejected",
+			scenarios:   map[string]string{"stable-4.17": "simple"},
+			expectedErr: errors.New("session has no id"),
+		},
+		{
+			name:        "unknown scenario is rejected",
+			id:          "cluster-a",
+			scenarios:   map[string]string{"stable-4.17": "simple", "fast-4.17": "nonexistent"},
+			expectedErr: errors.New(`session cluster-a: channel fast-4.17 is mapped to unknown scenario "nonexistent"`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+			server := newTimelineServer(t, &testClock{now: now})
+			status, err := server.CreateSession(tt.id, tt.scenarios)
+			if diff := cmp.Diff(tt.expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+				t.Fatalf("error mismatch (-want +got):\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			expected := SessionStatus{
+				ID:        tt.id,
+				Scenarios: tt.scenarios,
+				CreatedAt: now,
+				LastSeen:  now,
+				ExpiresAt: now.Add(DefaultSessionIdleTimeout),
+				Timelines: []TimelineStatus{},
+			}
+			if diff := cmp.Diff(expected, status); diff != "" {
+				t.Errorf("unexpected session (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_Sessions(t *testing.T) {
+	start := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+	server := newTimelineServer(t, clock)
+	if _, err := server.CreateSession("cluster-a", map[string]string{"stable-4.17": "release-train"}); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := server.CreateSession("cluster-b", map[string]string{"stable-4.17": "release-train"}); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	graph := queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-a")
+	if len(graph.Nodes) != 2 || graph.Nodes[0].Metadata["io.openshift.upgrades.graph.release.channels"] != "stable-4.17" {
+		t.Errorf("expected the release-train graph served as stable-4.17, got %+v", graph)
+	}
+	if graph := queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-c"); len(graph.Nodes) != 0 {
+		t.Errorf("expected an empty graph for a cluster without a mapping, got %+v", graph)
+	}
+	if requests := server.RecordedRequests(RequestFilter{ID: "cluster-a"}); len(requests) != 1 || requests[0].Scenario != "release-train" {
+		t.Errorf("expected the served scenario to be recorded, got %+v", requests)
+	}
+
+	clock.advance(10 * time.Minute)
+	for range 3 {
+		queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-a")
+	}
+	queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-b")
+	if graph := queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-a"); len(graph.ConditionalEdges) != 1 {
+		t.Errorf("expected cluster-a to reach the end of its timeline, got %+v", graph)
+	}
+	if graph := queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-b"); len(graph.Nodes) != 2 || len(graph.ConditionalEdges) != 0 {
+		t.Errorf("expected cluster-b to be at the start of its own timeline, got %+v", graph)
+	}
+	if graph := queryGraph(t, server, "channel=release-train&version=4.17.5"); len(graph.Nodes) != 3 || len(graph.ConditionalEdges) != 0 {
+		t.Errorf("expected the shared timeline not to advance with session requests, got %+v", graph)
+	}
+
+	expected := []SessionStatus{
+		{
+			ID:        "cluster-a",
+			Scenarios: map[string]string{"stable-4.17": "release-train"},
+			CreatedAt: start,
+			LastSeen:  start.Add(10 * time.Minute),
+			ExpiresAt: start.Add(10*time.Minute + DefaultSessionIdleTimeout),
+			Requests:  5,
+			Timelines: []TimelineStatus{{Channel: "release-train", Position: 2, Steps: 2, StartedAt: start, Requests: 5}},
+		},
+		{
+			ID:        "cluster-b",
+			Scenarios: map[string]string{"stable-4.17": "release-train"},
+			CreatedAt: start,
+			LastSeen:  start.Add(10 * time.Minute),
+			ExpiresAt: start.Add(10*time.Minute + DefaultSessionIdleTimeout),
+			Requests:  2,
+			Timelines: []TimelineStatus{{Channel: "release-train", Position: 0, Steps: 2, StartedAt: start.Add(10 * time.Minute), Requests: 2}},
+		},
+		{
+			ID:        "cluster-c",
+			CreatedAt: start,
+			LastSeen:  start,
+			ExpiresAt: start.Add(DefaultSessionIdleTimeout),
+			Requests:  1,
+			Timelines: []TimelineStatus{},
+		},
+	}
+	if diff := cmp.Diff(expected, server.Sessions()); diff != "" {
+		t.Errorf("unexpected sessions (-want +got):\n%s", diff)
+	}
+
+	clock.advance(DefaultSessionIdleTimeout)
+	if _, ok := server.Session("cluster-c"); ok {
+		t.Errorf("expected the idle session to expire")
+	}
+	if _, ok := server.Session("cluster-a"); !ok {
+		t.Errorf("expected the session polled recently to be kept")
+	}
+	clock.advance(time.Second)
+	if sessions := server.Sessions(); len(sessions) != 0 {
+		t.Errorf("expected all sessions to expire, got %+v", sessions)
+	}
+	if graph := queryGraph(t, server, "channel=stable-4.17&version=4.17.5&id=cluster-a"); len(graph.Nodes) != 0 {
+		t.Errorf("expected the mapping to be forgotten with the expired session, got %+v", graph)
+	}
+}
+
+func TestServer_ImplicitSessions(t *testing.T) {
+	start := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+	server := newTimelineServer(t, clock)
+	server.maxImplicitSessions = 2
+	if _, err := server.CreateSession("cluster-a", nil); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	for _, id := range []string{"cluster-a", "cluster-b", "cluster-c", "cluster-b", "cluster-d"} {
+		queryGraph(t, server, "channel=release-train&version=4.17.5&id="+id)
+		clock.advance(time.Minute)
+	}
+
+	var ids []string
+	for _, session := range server.Sessions() {
+		ids = append(ids, session.ID)
+	}
+	if diff := cmp.Diff([]string{"cluster-a", "cluster-b", "cluster-d"}, ids); diff != "" {
+		t.Errorf("expected the least recently seen implicit session to be forgotten (-want +got):\n%s", diff)
+	}
+	if status, _ := server.Session("cluster-b"); len(status.Timelines) != 0 {
+		t.Errorf("expected implicit sessions not to have own timelines, got %+v", status.Timelines)
+	}
+	if status, _ := server.Session("cluster-a"); len(status.Timelines) != 1 || status.Timelines[0].Requests != 1 {
+		t.Errorf("expected the created session to have its own timeline, got %+v", status.Timelines)
+	}
+	expected := []TimelineStatus{{Channel: "release-train", Position: 0, Steps: 2, StartedAt: start, Requests: 4}}
+	if diff := cmp.Diff(expected, server.Timelines()); diff != "" {
+		t.Errorf("expected implicit sessions to advance the shared timeline (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_handleDebugSessions(t *testing.T) {
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	server := NewServer(WithClock(&testClock{now: now}), WithSessionIdleTimeout(time.Hour), WithAdminToken("secret"))
+	serve := func(method, target, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		server.mux.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder, into any) {
+		t.Helper()
+		if err := json.Unmarshal(w.Body.Bytes(), into); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "create without the admin token", method: http.MethodPost, target: "/api/admin/sessions", body: `{"id": "cluster-a", "scenarios": {"stable-4.17": "simple"}}`, expectedStatus: http.StatusUnauthorized},
+		{name: "create over the debug API", method: http.MethodPost, target: "/api/debug/sessions", body: `{"id": "cluster-a", "scenarios": {"stable-4.17": "simple"}}`, expectedStatus: http.StatusMethodNotAllowed},
+		{name: "create", method: http.MethodPost, target: "/api/admin/sessions", token: "secret", body: `{"id": "cluster-a", "scenarios": {"stable-4.17": "simple"}}`, expectedStatus: http.StatusCreated},
+		{name: "create with an unknown scenario", method: http.MethodPost, target: "/api/admin/sessions", token: "secret", body: `{"id": "cluster-b", "scenarios": {"stable-4.17": "nonexistent"}}`, expectedStatus: http.StatusBadRequest},
+		{name: "create with a malformed body", method: http.MethodPost, target: "/api/admin/sessions", token: "secret", body: `{"id": `, expectedStatus: http.StatusBadRequest},
+		{name: "list", method: http.MethodGet, target: "/api/debug/sessions", expectedStatus: http.StatusOK},
+		{name: "get", method: http.MethodGet, target: "/api/debug/sessions/cluster-a", expectedStatus: http.StatusOK},
+		{name: "get unknown", method: http.MethodGet, target: "/api/debug/sessions/cluster-b", expectedStatus: http.StatusNotFound},
+		{name: "delete without the admin token", method: http.MethodDelete, target: "/api/admin/sessions/cluster-a", token: "guess", expectedStatus: http.StatusUnauthorized},
+		{name: "delete over the debug API", method: http.MethodDelete, target: "/api/debug/sessions/cluster-a", expectedStatus: http.StatusMethodNotAllowed},
+		{name: "delete", method: http.MethodDelete, target: "/api/admin/sessions/cluster-a", token: "secret", expectedStatus: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, target: "/api/admin/sessions/cluster-a", token: "secret", expectedStatus: http.StatusNotFound},
+		{name: "unsupported method", method: http.MethodPut, target: "/api/admin/sessions", token: "secret", expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	expected := SessionStatus{
+		ID:        "cluster-a",
+		Scenarios: map[string]string{"stable-4.17": "simple"},
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(time.Hour),
+		Timelines: []TimelineStatus{},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.method, tt.target, tt.token, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			switch tt.name {
+			case "create", "get":
+				var status SessionStatus
+				decode(w, &status)
+				if diff := cmp.Diff(expected, status); diff != "" {
+					t.Errorf("unexpected session (-want +got):\n%s", diff)
+				}
+			case "list":
+				var response sessionsResponse
+				decode(w, &response)
+				if diff := cmp.Diff([]SessionStatus{expected}, response.Sessions); diff != "" {
+					t.Errorf("unexpected sessions (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
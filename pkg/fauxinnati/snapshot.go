@@ -0,0 +1,210 @@
+package fauxinnati
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+)
+
+// Snapshot is a set of graphs recorded from an upstream Cincinnati for a single channel
+type Snapshot struct {
+	// Channel is the name of the channel the snapshot is served as
+	Channel string `json:"channel"`
+	// Source is the URL the graphs were captured from
+	Source string `json:"source,omitempty"`
+	// CapturedAt is when the graphs were captured
+	CapturedAt time.Time `json:"capturedAt,omitzero"`
+	// Graphs are the recorded graphs by the arch they were requested with. The graph for the empty arch
+	// is served for architectures without a graph of their own.
+	Graphs map[string]Graph `json:"graphs"`
+	// RewriteRepository makes served payload pullspecs point to the repository of the server's DigestResolver
+	// (e.g. a mirror registry) instead of the recorded repository
+	RewriteRepository bool `json:"rewriteRepository,omitempty"`
+}
+
+// LoadSnapshot reads a snapshot file written by CaptureSnapshot. Plain graph JSON files, e.g. saved with
+// curl from an upstream Cincinnati, are also accepted; they are served for all architectures in a channel
+// named after the file.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	snapshot := &Snapshot{}
+	if _, ok := probe["nodes"]; ok {
+		var graph Graph
+		if err := json.Unmarshal(data, &graph); err != nil {
+			return nil, fmt.Errorf("failed to parse graph %s: %w", path, err)
+		}
+		snapshot.Channel = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		snapshot.Graphs = map[string]Graph{"": graph}
+	} else if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+
+	if err := snapshot.validate(); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func (s *Snapshot) validate() error {
+	if s.Channel == "" {
+		return fmt.Errorf("snapshot has no channel")
+	}
+	if len(s.Graphs) == 0 {
+		return fmt.Errorf("snapshot has no graphs")
+	}
+	for arch, graph := range s.Graphs {
+		for i, edge := range graph.Edges {
+			for _, index := range edge {
+				if index < 0 || index >= len(graph.Nodes) {
+					return fmt.Errorf("edge %d of the %q graph points to nonexistent node %d", i, arch, index)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// graph returns the recorded graph for arch. Queries without an arch get the amd64 graph, like from Cincinnati.
+func (s *Snapshot) graph(arch string) (Graph, bool) {
+	if arch == "" {
+		arch = "amd64"
+	}
+	if graph, ok := s.Graphs[arch]; ok {
+		return graph, true
+	}
+	graph, ok := s.Graphs[""]
+	return graph, ok
+}
+
+// RegisterSnapshot serves the snapshot as a channel. Graphs are served as recorded regardless of the queried
+// version, with payload pullspecs rewritten to the repository of the server's DigestResolver when the snapshot
+// sets RewriteRepository.
+func (s *Server) RegisterSnapshot(snapshot *Snapshot) error {
+	arches := slices.Sorted(maps.Keys(snapshot.Graphs))
+	return s.RegisterChannel(Channel{
+		Name:        snapshot.Channel,
+		Description: fmt.Sprintf("Graph recorded from %s (arch: %s).", snapshotSource(snapshot), strings.Join(arches, ", ")),
+		Generate: func(_ semver.Version, arch string, _ string) Graph {
+			graph, ok := snapshot.graph(arch)
+			if !ok {
+				return s.generateEmptyGraph(fmt.Sprintf("snapshot %s has no graph for arch %s", snapshot.Channel, arch))
+			}
+			if snapshot.RewriteRepository {
+				graph = s.rewritePayloadRepository(graph)
+			}
+			return servedSnapshotGraph(graph)
+		},
+	})
+}
+
+func snapshotSource(snapshot *Snapshot) string {
+	if snapshot.Source == "" {
+		return "an upstream Cincinnati"
+	}
+	return snapshot.Source
+}
+
+// rewritePayloadRepository returns a copy of graph with payload pullspecs pointing to the configured repository
+func (s *Server) rewritePayloadRepository(graph Graph) Graph {
+	repository := s.digestResolver.Repository()
+	nodes := make([]Node, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		if _, digest, ok := strings.Cut(node.Image, "@"); ok {
+			node.Image = repository + "@" + digest
+		}
+		nodes = append(nodes, node)
+	}
+	rewritten := graph
+	rewritten.Nodes = nodes
+	return rewritten
+}
+
+// servedSnapshotGraph returns the recorded graph with empty rather than missing edge lists, like Cincinnati serves
+func servedSnapshotGraph(graph Graph) Graph {
+	if graph.Edges == nil {
+		graph.Edges = []Edge{}
+	}
+	if graph.ConditionalEdges == nil {
+		graph.ConditionalEdges = []ConditionalEdge{}
+	}
+	return graph
+}
+
+// CaptureSnapshot records the graphs served by the upstream graph API (e.g.
+// https://api.openshift.com/api/upgrades_info/graph) for channel and each of the arches
+func CaptureSnapshot(client Client, upstream, channel string, arches []string) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Channel:    channel,
+		Source:     upstream,
+		CapturedAt: time.Now().UTC().Truncate(time.Second),
+		Graphs:     map[string]Graph{},
+	}
+	for _, arch := range arches {
+		params := url.Values{"channel": {channel}}
+		if arch != "" {
+			params.Set("arch", arch)
+		}
+		graph, err := fetchGraph(client, upstream, params)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Graphs[arch] = graph
+	}
+	return snapshot, snapshot.validate()
+}
+
+// fetchGraph gets the graph served by the upstream graph API for the given query parameters
+func fetchGraph(client Client, upstream string, params url.Values) (Graph, error) {
+	uri, err := url.Parse(upstream)
+	if err != nil {
+		return Graph{}, err
+	}
+	query := uri.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	uri.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, uri.String(), nil)
+	if err != nil {
+		return Graph{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return Graph{}, err
+	}
+	data, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return Graph{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return Graph{}, fmt.Errorf("unexpected status code %d for url %s: %s", res.StatusCode, req.URL, bytes.TrimSpace(data))
+	}
+
+	var graph Graph
+	if err := json.Unmarshal(data, &graph); err != nil {
+		return Graph{}, fmt.Errorf("failed to parse graph from %s: %w", req.URL, err)
+	}
+	return graph, nil
+}
@@ -0,0 +1,158 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func queryGraph(t *testing.T, server *Server, query string) Graph {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/upgrades_info/graph?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var graph Graph
+	if err := json.Unmarshal(w.Body.Bytes(), &graph); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v", err)
+	}
+	return graph
+}
+
+func TestServer_RegisterSnapshot_PlainGraph(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("testdata/zz_fixture_TestGraph_JSONcandidate_4.20.json.input")
+	if err != nil {
+		t.Fatalf("failed to read graph: %v", err)
+	}
+	path := filepath.Join(dir, "candidate-4.20.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write graph: %v", err)
+	}
+	var recorded Graph
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		t.Fatalf("failed to unmarshal graph: %v", err)
+	}
+
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	server := NewServer()
+	if err := server.RegisterSnapshot(snapshot); err != nil {
+		t.Fatalf("failed to register snapshot: %v", err)
+	}
+
+	for _, arch := range []string{"", "amd64", "multi"} {
+		if diff := cmp.Diff(recorded, queryGraph(t, server, "channel=candidate-4.20&version=4.19.0&arch="+arch)); diff != "" {
+			t.Errorf("arch %q: served graph differs from the recorded one (-want +got):\n%s", arch, diff)
+		}
+	}
+
+}
+
+func TestServer_RegisterSnapshot_RewriteRepository(t *testing.T) {
+	recorded := proxyTestGraph()
+	recorded.Nodes[0].Image = "quay.io/openshift/okd@sha256:0123"
+	tests := []struct {
+		name     string
+		rewrite  bool
+		expected string
+	}{
+		{
+			name:     "serves recorded pullspecs by default",
+			expected: "quay.io/openshift/okd@sha256:0123",
+		},
+		{
+			name:     "rewrites pullspecs to the repository of the digest resolver",
+			rewrite:  true,
+			expected: "mirror.local/ocp/release@sha256:0123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(WithDigestResolver(NewStaticDigestResolver("mirror.local/ocp/release", nil)))
+			snapshot := &Snapshot{Channel: "stable-4.17", Graphs: map[string]Graph{"": recorded}, RewriteRepository: tt.rewrite}
+			if err := server.RegisterSnapshot(snapshot); err != nil {
+				t.Fatalf("failed to register snapshot: %v", err)
+			}
+			served := queryGraph(t, server, "channel=stable-4.17&version=4.17.0")
+			if served.Nodes[0].Image != tt.expected {
+				t.Errorf("expected payload %s, got %s", tt.expected, served.Nodes[0].Image)
+			}
+			if recorded.Nodes[0].Image != "quay.io/openshift/okd@sha256:0123" {
+				t.Errorf("expected the recorded graph not to be modified, got %s", recorded.Nodes[0].Image)
+			}
+		})
+	}
+}
+
+func TestCaptureSnapshot(t *testing.T) {
+	upstream := NewServer()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Upstream Cincinnati does not require the version parameter
+		query := r.URL.Query()
+		query.Set("version", "4.17.5")
+		r.URL.RawQuery = query.Encode()
+		upstream.mux.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	snapshot, err := CaptureSnapshot(http.DefaultClient, httpServer.URL+"/api/upgrades_info/graph", "simple", []string{"amd64", "multi"})
+	if err != nil {
+		t.Fatalf("failed to capture snapshot: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("failed to marshal snapshot: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if diff := cmp.Diff(snapshot, loaded); diff != "" {
+		t.Errorf("loaded snapshot differs from the captured one (-want +got):\n%s", diff)
+	}
+
+	server := NewServer()
+	loaded.Channel = "recorded"
+	if err := server.RegisterSnapshot(loaded); err != nil {
+		t.Fatalf("failed to register snapshot: %v", err)
+	}
+	if diff := cmp.Diff(snapshot.Graphs["multi"], queryGraph(t, server, "channel=recorded&version=4.16.0&arch=multi")); diff != "" {
+		t.Errorf("multi graph mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(snapshot.Graphs["amd64"], queryGraph(t, server, "channel=recorded&version=4.16.0")); diff != "" {
+		t.Errorf("amd64 graph mismatch (-want +got):\n%s", diff)
+	}
+	expected := Graph{Nodes: []Node{}, Edges: []Edge{}, ConditionalEdges: []ConditionalEdge{}, Error: "snapshot recorded has no graph for arch arm64"}
+	if diff := cmp.Diff(expected, queryGraph(t, server, "channel=recorded&version=4.16.0&arch=arm64")); diff != "" {
+		t.Errorf("arm64 graph mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadSnapshot_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(path, []byte(`{"nodes": [], "edges": [[0, 1]]}`), 0644); err != nil {
+		t.Fatalf("failed to write graph: %v", err)
+	}
+	_, err := LoadSnapshot(path)
+	expectedErr := errors.New("invalid snapshot " + path + `: edge 0 of the "" graph points to nonexistent node 0`)
+	if diff := cmp.Diff(expectedErr, err, testhelper.EquateErrorMessage); diff != "" {
+		t.Errorf("error mismatch (-want +got):\n%s", diff)
+	}
+}
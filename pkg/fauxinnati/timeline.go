@@ -0,0 +1,301 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/blang/semver/v4"
+)
+
+// AIDEV-NOTE: Timelines make scenario graphs change over time, simulating a release train
+// Every step is applied on top of the previous graph state; all states are computed and validated when the scenario
+// is loaded. The position in a timeline is kept by the server per channel and survives scenario reloads.
+
+// TimelineStep changes the graph of a scenario once its triggers fire. Steps fire in order: a step is applied when
+// both its triggers and the triggers of all previous steps are satisfied.
+type TimelineStep struct {
+	// After fires the step this long after the timeline started (when the scenario was first loaded, or reset)
+	After time.Duration `yaml:"after,omitempty"`
+	// AfterRequests fires the step after this many graph requests for the channel were served
+	AfterRequests int `yaml:"afterRequests,omitempty"`
+
+	// RemoveNodes removes nodes by ID together with their edges
+	RemoveNodes []string `yaml:"removeNodes,omitempty"`
+	// AddNodes adds nodes, e.g. a new z-stream release
+	AddNodes []ScenarioNode `yaml:"addNodes,omitempty"`
+	// RemoveEdges removes unconditional edges
+	RemoveEdges []ScenarioEdge `yaml:"removeEdges,omitempty"`
+	// AddEdges adds unconditional edges
+	AddEdges []ScenarioEdge `yaml:"addEdges,omitempty"`
+	// BlockEdges turns unconditional edges into conditional edges with the given risks
+	BlockEdges []ScenarioConditionalEdge `yaml:"blockEdges,omitempty"`
+	// AddConditionalEdges adds conditional edges
+	AddConditionalEdges []ScenarioConditionalEdge `yaml:"addConditionalEdges,omitempty"`
+}
+
+func (t TimelineStep) validateTriggers() error {
+	if t.After < 0 || t.AfterRequests < 0 {
+		return fmt.Errorf("triggers must not be negative")
+	}
+	if t.After == 0 && t.AfterRequests == 0 {
+		return fmt.Errorf("step has no trigger (after or afterRequests)")
+	}
+	return nil
+}
+
+// fired reports whether the step triggers are satisfied after elapsed time and served requests
+func (t TimelineStep) fired(elapsed time.Duration, requests int) bool {
+	return elapsed >= t.After && requests >= t.AfterRequests
+}
+
+// apply returns the state of the scenario after the step; the scenario itself is not modified
+func (t TimelineStep) apply(scenario *Scenario) (*Scenario, error) {
+	next := &Scenario{
+		Name:             scenario.Name,
+		Description:      scenario.Description,
+		OmitArchitecture: scenario.OmitArchitecture,
+		Fault:            scenario.Fault,
+	}
+
+	for _, id := range t.RemoveNodes {
+		if !slices.ContainsFunc(scenario.Nodes, func(node ScenarioNode) bool { return node.ID == id }) {
+			return nil, fmt.Errorf("cannot remove unknown node %s", id)
+		}
+	}
+	removed := func(edge ScenarioEdge) bool {
+		return slices.Contains(t.RemoveNodes, edge[0]) || slices.Contains(t.RemoveNodes, edge[1])
+	}
+	for _, node := range scenario.Nodes {
+		if !slices.Contains(t.RemoveNodes, node.ID) {
+			next.Nodes = append(next.Nodes, node)
+		}
+	}
+	next.Nodes = append(next.Nodes, t.AddNodes...)
+
+	unblocked := slices.Clone(t.RemoveEdges)
+	for _, block := range t.BlockEdges {
+		unblocked = append(unblocked, block.Edges...)
+	}
+	for _, edge := range unblocked {
+		if !slices.Contains(scenario.Edges, edge) {
+			return nil, fmt.Errorf("cannot remove unknown edge %s->%s", edge[0], edge[1])
+		}
+	}
+	for _, edge := range scenario.Edges {
+		if !removed(edge) && !slices.Contains(unblocked, edge) {
+			next.Edges = append(next.Edges, edge)
+		}
+	}
+	next.Edges = append(next.Edges, t.AddEdges...)
+
+	for _, conditionalEdge := range scenario.ConditionalEdges {
+		kept := ScenarioConditionalEdge{Risks: conditionalEdge.Risks}
+		for _, edge := range conditionalEdge.Edges {
+			if !removed(edge) {
+				kept.Edges = append(kept.Edges, edge)
+			}
+		}
+		if len(kept.Edges) > 0 {
+			next.ConditionalEdges = append(next.ConditionalEdges, kept)
+		}
+	}
+	next.ConditionalEdges = append(next.ConditionalEdges, t.BlockEdges...)
+	next.ConditionalEdges = append(next.ConditionalEdges, t.AddConditionalEdges...)
+	return next, nil
+}
+
+// timelineStates returns the graph states of the scenario: the scenario itself followed by the state after each
+// timeline step
+func (s *Scenario) timelineStates() ([]*Scenario, error) {
+	base := *s
+	base.Timeline = nil
+	states := []*Scenario{&base}
+	for i, step := range s.Timeline {
+		if err := step.validateTriggers(); err != nil {
+			return nil, fmt.Errorf("scenario %s: timeline step %d: %w", s.Name, i, err)
+		}
+		state, err := step.apply(states[i])
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: timeline step %d: %w", s.Name, i, err)
+		}
+		named := *state
+		named.Name = fmt.Sprintf("%s timeline step %d", s.Name, i)
+		if err := named.Validate(); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// timelineState is the position of a channel in its timeline
+type timelineState struct {
+	lock     sync.Mutex
+	start    time.Time
+	requests int
+}
+
+// position returns how many steps have fired
+func (t *timelineState) position(steps []TimelineStep, now time.Time) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	elapsed := now.Sub(t.start)
+	for i, step := range steps {
+		if !step.fired(elapsed, t.requests) {
+			return i
+		}
+	}
+	return len(steps)
+}
+
+func (t *timelineState) countRequest() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.requests++
+}
+
+func (t *timelineState) reset(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.start = now
+	t.requests = 0
+}
+
+// timelineKey identifies a timeline position: the channel of a scenario, either shared by requests outside of
+// sessions or kept for a session
+type timelineKey struct {
+	session string
+	channel string
+}
+
+// timeline returns the timeline state of the key, starting the timeline when it does not exist yet
+func (s *Server) timeline(key timelineKey) *timelineState {
+	s.timelinesLock.Lock()
+	defer s.timelinesLock.Unlock()
+	state, ok := s.timelines[key]
+	if !ok {
+		state = &timelineState{start: s.clock.Now()}
+		s.timelines[key] = state
+	}
+	return state
+}
+
+// timelineStates returns the states of the scenario timeline served by the channel
+func (s *Server) timelineStates(scenario *Scenario) []*Scenario {
+	states, err := scenario.timelineStates()
+	if err != nil {
+		// Loaded scenarios are validated, so this only happens for scenarios registered without validation
+		s.logger.WithError(err).WithField("scenario", scenario.Name).Warning("Ignoring invalid scenario timeline")
+		return []*Scenario{scenario}
+	}
+	return states
+}
+
+// generateTimelineGraph generates the graph of the channel from the scenario state at the current position of the
+// session timeline
+func (s *Server) generateTimelineGraph(channel Channel, session string, queriedVersion semver.Version, arch string, requested string) Graph {
+	state := s.timeline(timelineKey{session: session, channel: channel.Name})
+	position := min(state.position(channel.scenario.Timeline, s.clock.Now()), len(channel.states)-1)
+	return s.generateScenarioGraph(channel.states[position], queriedVersion, arch, requested)
+}
+
+// countTimelineRequest advances the request count of the session timeline of the channel, if the channel has one
+func (s *Server) countTimelineRequest(channel Channel, session string) {
+	if len(channel.states) > 0 {
+		s.timeline(timelineKey{session: session, channel: channel.Name}).countRequest()
+	}
+}
+
+// TimelineStatus is the position of a channel in its timeline
+type TimelineStatus struct {
+	Channel string `json:"channel"`
+	// Position is the number of steps that have fired; 0 is the initial graph
+	Position int `json:"position"`
+	Steps    int `json:"steps"`
+	// StartedAt is when the timeline started or was last reset
+	StartedAt time.Time `json:"startedAt"`
+	// Requests is the number of graph requests served for the channel since StartedAt
+	Requests int `json:"requests"`
+}
+
+// Timelines returns the positions of all served channels with a timeline outside of sessions, sorted by channel
+func (s *Server) Timelines() []TimelineStatus {
+	return s.timelineStatuses("")
+}
+
+// timelineStatuses returns the positions of the session timelines; a session timeline starts when the session
+// first requests its channel, so only those are returned
+func (s *Server) timelineStatuses(session string) []TimelineStatus {
+	statuses := []TimelineStatus{}
+	now := s.clock.Now()
+	for _, channel := range s.channelRegistry().Channels() {
+		if len(channel.states) == 0 {
+			continue
+		}
+		key := timelineKey{session: session, channel: channel.Name}
+		s.timelinesLock.Lock()
+		state, ok := s.timelines[key]
+		s.timelinesLock.Unlock()
+		if !ok {
+			continue
+		}
+		position := state.position(channel.scenario.Timeline, now)
+		state.lock.Lock()
+		statuses = append(statuses, TimelineStatus{
+			Channel:   channel.Name,
+			Position:  position,
+			Steps:     len(channel.scenario.Timeline),
+			StartedAt: state.start.UTC(),
+			Requests:  state.requests,
+		})
+		state.lock.Unlock()
+	}
+	return statuses
+}
+
+// ResetTimeline restarts the timeline of the channel outside of sessions, or of all channels when channel is empty.
+// Session timelines restart when the session is created again.
+func (s *Server) ResetTimeline(channel string) {
+	now := s.clock.Now()
+	s.timelinesLock.Lock()
+	defer s.timelinesLock.Unlock()
+	for key, state := range s.timelines {
+		if key.session == "" && (channel == "" || channel == key.channel) {
+			state.reset(now)
+		}
+	}
+}
+
+// forgetTimelines drops the timeline positions of the session
+func (s *Server) forgetTimelines(session string) {
+	s.timelinesLock.Lock()
+	defer s.timelinesLock.Unlock()
+	for key := range s.timelines {
+		if key.session == session {
+			delete(s.timelines, key)
+		}
+	}
+}
+
+type timelinesResponse struct {
+	Timelines []TimelineStatus `json:"timelines"`
+}
+
+func (s *Server) handleDebugTimelines(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	switch r.Method {
+	case http.MethodGet:
+		timelines := slices.DeleteFunc(s.Timelines(), func(status TimelineStatus) bool {
+			return channel != "" && status.Channel != channel
+		})
+		writeJSON(w, http.StatusOK, timelinesResponse{Timelines: timelines})
+	case http.MethodDelete:
+		s.ResetTimeline(channel)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,198 @@
+package fauxinnati
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/petr-muller/vibes/pkg/testhelper"
+)
+
+func newTimelineServer(t *testing.T, clock Clock) *Server {
+	t.Helper()
+	server := NewServer(WithClock(clock))
+	if err := server.LoadScenariosDir("testdata/scenarios"); err != nil {
+		t.Fatalf("failed to load scenarios: %v", err)
+	}
+	return server
+}
+
+func TestServer_Timeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		requests int
+	}{
+		{
+			name: "serves the initial graph",
+		},
+		{
+			name:     "request trigger waits for the previous step",
+			requests: 3,
+		},
+		{
+			name:    "adds a patch release after ten minutes",
+			elapsed: 10 * time.Minute,
+		},
+		{
+			name:     "blocks the update after three polls",
+			elapsed:  10 * time.Minute,
+			requests: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)}
+			server := newTimelineServer(t, clock)
+			for range tt.requests {
+				queryGraph(t, server, "channel=release-train&version=4.17.5")
+			}
+			clock.advance(tt.elapsed)
+			testhelper.CompareWithFixture(t, queryGraph(t, server, "channel=release-train&version=4.17.5"))
+		})
+	}
+}
+
+func TestServer_handleDebugTimelines(t *testing.T) {
+	start := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+	server := newTimelineServer(t, clock)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.mux.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	listTimelines := func(query string) []TimelineStatus {
+		t.Helper()
+		w := serve(http.MethodGet, "/api/debug/timelines?"+query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var response timelinesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response.Timelines
+	}
+
+	expected := []TimelineStatus{{Channel: "release-train", Steps: 2, StartedAt: start}}
+	if diff := cmp.Diff(expected, listTimelines("")); diff != "" {
+		t.Errorf("unexpected timelines (-want +got):\n%s", diff)
+	}
+
+	for range 3 {
+		serve(http.MethodGet, "/api/upgrades_info/graph?channel=release-train&version=4.17.5")
+	}
+	serve(http.MethodGet, "/api/upgrades_info/graph?channel=simple&version=4.17.5")
+	clock.advance(15 * time.Minute)
+	expected = []TimelineStatus{{Channel: "release-train", Position: 2, Steps: 2, StartedAt: start, Requests: 3}}
+	if diff := cmp.Diff(expected, listTimelines("channel=release-train")); diff != "" {
+		t.Errorf("unexpected timelines (-want +got):\n%s", diff)
+	}
+	if timelines := listTimelines("channel=simple"); len(timelines) != 0 {
+		t.Errorf("expected no timeline for a channel without one, got %v", timelines)
+	}
+
+	if w := serve(http.MethodDelete, "/api/debug/timelines?channel=release-train"); w.Code != http.StatusNoContent {
+		t.Errorf("expected reset to respond 204, got %d", w.Code)
+	}
+	expected = []TimelineStatus{{Channel: "release-train", Steps: 2, StartedAt: clock.now}}
+	if diff := cmp.Diff(expected, listTimelines("")); diff != "" {
+		t.Errorf("unexpected timelines after reset (-want +got):\n%s", diff)
+	}
+	if graph := queryGraph(t, server, "channel=release-train&version=4.17.5"); len(graph.Nodes) != 2 {
+		t.Errorf("expected the initial graph after reset, got %+v", graph)
+	}
+
+	clock.advance(time.Hour)
+	if w := serve(http.MethodDelete, "/api/debug/timelines"); w.Code != http.StatusNoContent {
+		t.Errorf("expected delete to respond 204, got %d", w.Code)
+	}
+	if timelines := server.Timelines(); len(timelines) != 1 || timelines[0].Position != 0 || timelines[0].Requests != 0 {
+		t.Errorf("expected the timeline to restart after delete, got %+v", timelines)
+	}
+}
+
+func TestServer_handleDebugTimelines_Sessions(t *testing.T) {
+	start := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+	server := newTimelineServer(t, clock)
+	if _, err := server.CreateSession("cluster-a", nil); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.mux.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	listTimelines := func() []TimelineStatus {
+		t.Helper()
+		var response timelinesResponse
+		if err := json.Unmarshal(serve(http.MethodGet, "/api/debug/timelines").Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response.Timelines
+	}
+	sessionTimelines := func(id string) []TimelineStatus {
+		t.Helper()
+		status, ok := server.Session(id)
+		if !ok {
+			t.Fatalf("session %s not found", id)
+		}
+		return status.Timelines
+	}
+
+	for _, id := range []string{"cluster-a", "cluster-a", "cluster-a", "cluster-b", ""} {
+		queryGraph(t, server, "channel=release-train&version=4.17.5&id="+id)
+	}
+	clock.advance(15 * time.Minute)
+
+	// Clusters without a created session share the timeline of requests without an id
+	expected := []TimelineStatus{{Channel: "release-train", Position: 1, Steps: 2, StartedAt: start, Requests: 2}}
+	if diff := cmp.Diff(expected, listTimelines()); diff != "" {
+		t.Errorf("unexpected shared timelines (-want +got):\n%s", diff)
+	}
+	expected = []TimelineStatus{{Channel: "release-train", Position: 2, Steps: 2, StartedAt: start, Requests: 3}}
+	if diff := cmp.Diff(expected, sessionTimelines("cluster-a")); diff != "" {
+		t.Errorf("unexpected session timelines (-want +got):\n%s", diff)
+	}
+
+	// Resetting timelines only restarts the shared ones, session timelines restart when the session is created again
+	if w := serve(http.MethodDelete, "/api/debug/timelines"); w.Code != http.StatusNoContent {
+		t.Errorf("expected delete to respond 204, got %d", w.Code)
+	}
+	reset := clock.now
+	expected = []TimelineStatus{{Channel: "release-train", Steps: 2, StartedAt: reset}}
+	if diff := cmp.Diff(expected, listTimelines()); diff != "" {
+		t.Errorf("unexpected shared timelines after reset (-want +got):\n%s", diff)
+	}
+	expected = []TimelineStatus{{Channel: "release-train", Position: 2, Steps: 2, StartedAt: start, Requests: 3}}
+	if diff := cmp.Diff(expected, sessionTimelines("cluster-a")); diff != "" {
+		t.Errorf("expected the session timeline to survive the reset (-want +got):\n%s", diff)
+	}
+	if _, err := server.CreateSession("cluster-a", nil); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	queryGraph(t, server, "channel=release-train&version=4.17.5&id=cluster-a")
+	expected = []TimelineStatus{{Channel: "release-train", Steps: 2, StartedAt: reset, Requests: 1}}
+	if diff := cmp.Diff(expected, sessionTimelines("cluster-a")); diff != "" {
+		t.Errorf("expected the session timeline to restart with the session (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_Timeline_SurvivesReload(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)}
+	server := newTimelineServer(t, clock)
+	clock.advance(10 * time.Minute)
+	if err := server.LoadScenariosDir("testdata/scenarios"); err != nil {
+		t.Fatalf("failed to reload scenarios: %v", err)
+	}
+	if graph := queryGraph(t, server, "channel=release-train&version=4.17.5"); len(graph.Nodes) != 3 {
+		t.Errorf("expected the timeline position to be kept across reloads, got %+v", graph)
+	}
+}
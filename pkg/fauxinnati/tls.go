@@ -0,0 +1,142 @@
+package fauxinnati
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// CABundlePath is where servers with a self-signed certificate serve the PEM bundle of their CA
+const CABundlePath = "/ca.crt"
+
+// selfSignedValidity is how long generated certificates are valid
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// EnableTLS makes the server serve HTTPS with the PEM certificate (chain) and key from the given files. It must be
+// called before the server starts serving requests.
+func (s *Server) EnableTLS(certFile, keyFile string) error {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the serving certificate: %w", err)
+	}
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	return nil
+}
+
+// EnableSelfSignedTLS makes the server serve HTTPS with a serving certificate for hosts (DNS names or IP
+// addresses) signed by an ephemeral CA generated for this server. The PEM bundle of the CA is served at
+// CABundlePath and returned by CABundle, so that clients (e.g. clusters through their trustedCA) can trust it.
+// It must be called before the server starts serving requests.
+func (s *Server) EnableSelfSignedTLS(hosts []string) error {
+	caBundle, certificate, err := generateSelfSignedCertificates(hosts, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to generate a self-signed certificate: %w", err)
+	}
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	s.caBundle = caBundle
+	return nil
+}
+
+// CABundle returns the PEM bundle of the CA generated by EnableSelfSignedTLS, or nil
+func (s *Server) CABundle() []byte {
+	return s.caBundle
+}
+
+// DefaultTLSHosts are the hosts certificates generated for servers are valid for, when not specified otherwise:
+// localhost, loopback addresses and the hostname of the machine
+func DefaultTLSHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+		hosts = append(hosts, hostname)
+	}
+	return hosts
+}
+
+func (s *Server) handleCABundle(w http.ResponseWriter, r *http.Request) {
+	if s.caBundle == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	_, _ = w.Write(s.caBundle)
+}
+
+// generateSelfSignedCertificates generates a CA and a serving certificate for hosts signed by it, returning the
+// PEM encoded CA certificate and the serving certificate with its key
+func generateSelfSignedCertificates(hosts []string, now time.Time) ([]byte, tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, tls.Certificate{}, fmt.Errorf("no hosts to generate the serving certificate for")
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          randomSerialNumber(),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("fauxinnati-ca@%d", now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	servingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+	servingTemplate := &x509.Certificate{
+		SerialNumber: randomSerialNumber(),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			servingTemplate.IPAddresses = append(servingTemplate.IPAddresses, ip)
+		} else {
+			servingTemplate.DNSNames = append(servingTemplate.DNSNames, host)
+		}
+	}
+	servingDER, err := x509.CreateCertificate(rand.Reader, servingTemplate, ca, &servingKey.PublicKey, caKey)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	certificate := tls.Certificate{Certificate: [][]byte{servingDER, caDER}, PrivateKey: servingKey}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), certificate, nil
+}
+
+func randomSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return serial
+}
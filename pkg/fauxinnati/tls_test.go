@@ -0,0 +1,115 @@
+package fauxinnati
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serveTLS serves the server with its TLS configuration and returns a client trusting roots
+func serveTLS(t *testing.T, server *Server, roots []byte) (*httptest.Server, *http.Client) {
+	t.Helper()
+	httpServer := httptest.NewUnstartedServer(server.mux)
+	httpServer.TLS = server.tlsConfig
+	httpServer.StartTLS()
+	t.Cleanup(httpServer.Close)
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(roots) {
+		t.Fatalf("failed to parse the CA bundle")
+	}
+	return httpServer, &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+}
+
+func TestServer_EnableSelfSignedTLS(t *testing.T) {
+	server := NewServer()
+	if err := server.EnableSelfSignedTLS([]string{"127.0.0.1", "fauxinnati.fauxinnati.svc"}); err != nil {
+		t.Fatalf("failed to enable TLS: %v", err)
+	}
+	httpServer, client := serveTLS(t, server, server.CABundle())
+
+	res, err := client.Get(httpServer.URL + "/api/upgrades_info/graph?channel=simple&version=4.17.5")
+	if err != nil {
+		t.Fatalf("failed to query the graph over TLS: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", res.StatusCode)
+	}
+
+	res, err = client.Get(httpServer.URL + CABundlePath)
+	if err != nil {
+		t.Fatalf("failed to get the CA bundle: %v", err)
+	}
+	bundle, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to read the CA bundle: %v", err)
+	}
+	if string(bundle) != string(server.CABundle()) {
+		t.Errorf("served CA bundle differs from the generated one:\n%s", bundle)
+	}
+
+	leaf, err := x509.ParseCertificate(server.tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse the serving certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("fauxinnati.fauxinnati.svc"); err != nil {
+		t.Errorf("serving certificate is not valid for the service name: %v", err)
+	}
+}
+
+func TestServer_EnableTLS(t *testing.T) {
+	caBundle, certificate, err := generateSelfSignedCertificates([]string{"127.0.0.1"}, time.Now())
+	if err != nil {
+		t.Fatalf("failed to generate certificates: %v", err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	var chain []byte
+	for _, der := range certificate.Certificate {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	if err := os.WriteFile(certFile, chain, 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	server := NewServer()
+	if err := server.EnableTLS(certFile, keyFile); err != nil {
+		t.Fatalf("failed to enable TLS: %v", err)
+	}
+	httpServer, client := serveTLS(t, server, caBundle)
+
+	res, err := client.Get(httpServer.URL + "/api/upgrades_info/graph?channel=simple&version=4.17.5")
+	if err != nil {
+		t.Fatalf("failed to query the graph over TLS: %v", err)
+	}
+	_ = res.Body.Close()
+
+	res, err = client.Get(httpServer.URL + CABundlePath)
+	if err != nil {
+		t.Fatalf("failed to request the CA bundle: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected no CA bundle with provided certificates, got status %d", res.StatusCode)
+	}
+
+	if err := server.EnableTLS(filepath.Join(dir, "missing.crt"), keyFile); err == nil {
+		t.Errorf("expected an error for a missing certificate file")
+	}
+}
@@ -0,0 +1,247 @@
+package fauxinnati
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// Severity tells how serious a Problem of a graph is
+type Severity string
+
+const (
+	// SeverityError marks graphs that are internally inconsistent, clients cannot consume them reliably
+	SeverityError Severity = "error"
+	// SeverityWarning marks graphs that are consistent but that a real Cincinnati would not serve
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is a single finding of Validate
+type Problem struct {
+	Severity Severity `json:"severity"`
+	// Path locates the problem in the graph JSON, e.g. edges[3] or conditionalEdges[0].risks[1]; empty for problems
+	// of the whole graph
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Path, p.Message)
+}
+
+// knownMatchingRuleTypes are the matching rule types the CVO evaluates
+var knownMatchingRuleTypes = []string{"Always", "PromQL"}
+
+// AIDEV-NOTE: Validate checks structure first (errors) and Cincinnati conventions second (warnings)
+// Errors make the graph inconsistent: indices out of range, unknown versions, duplicates, cycles and risks the CVO
+// cannot evaluate. Warnings are graphs a real Cincinnati never serves: downgrades, conditional edges shadowed by
+// unconditional ones, risks without a usable URL or message.
+
+// Validate returns the problems of the graph, in the order of the graph JSON. A graph without problems is nil.
+func Validate(graph Graph) []Problem {
+	var problems []Problem
+	report := func(severity Severity, path, format string, args ...any) {
+		problems = append(problems, Problem{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	indices := map[string]int{}
+	for i, node := range graph.Nodes {
+		path := fmt.Sprintf("nodes[%d]", i)
+		version := node.Version.String()
+		if first, ok := indices[version]; ok {
+			report(SeverityError, path, "duplicate version %s (first in nodes[%d])", version, first)
+			continue
+		}
+		indices[version] = i
+		if node.Image == "" {
+			report(SeverityWarning, path, "node %s has no payload", version)
+		}
+	}
+
+	adjacency := make([][]int, len(graph.Nodes))
+	unconditional := map[[2]string]bool{}
+	for i, edge := range graph.Edges {
+		path := fmt.Sprintf("edges[%d]", i)
+		if edge[0] < 0 || edge[0] >= len(graph.Nodes) || edge[1] < 0 || edge[1] >= len(graph.Nodes) {
+			report(SeverityError, path, "edge [%d, %d] points to a nonexistent node (graph has %d nodes)", edge[0], edge[1], len(graph.Nodes))
+			continue
+		}
+		from, to := graph.Nodes[edge[0]].Version, graph.Nodes[edge[1]].Version
+		pair := [2]string{from.String(), to.String()}
+		switch {
+		case edge[0] == edge[1]:
+			report(SeverityError, path, "edge from %s to itself", from)
+			continue
+		case unconditional[pair]:
+			report(SeverityWarning, path, "duplicate edge %s -> %s", from, to)
+			continue
+		}
+		unconditional[pair] = true
+		adjacency[edge[0]] = append(adjacency[edge[0]], edge[1])
+		problems = append(problems, checkDirection(path, from, to)...)
+	}
+
+	conditional := map[[2]string]string{}
+	for i, conditionalEdge := range graph.ConditionalEdges {
+		path := fmt.Sprintf("conditionalEdges[%d]", i)
+		if len(conditionalEdge.Edges) == 0 {
+			report(SeverityError, path, "conditional edge has no edges")
+		}
+		for j, edge := range conditionalEdge.Edges {
+			edgePath := fmt.Sprintf("%s.edges[%d]", path, j)
+			from, fromOK := indices[edge.From]
+			to, toOK := indices[edge.To]
+			if !fromOK || !toOK {
+				for _, missing := range []struct {
+					version string
+					ok      bool
+				}{{edge.From, fromOK}, {edge.To, toOK}} {
+					if !missing.ok {
+						report(SeverityError, edgePath, "version %q is not in the graph", missing.version)
+					}
+				}
+				continue
+			}
+			pair := [2]string{edge.From, edge.To}
+			if from == to {
+				report(SeverityError, edgePath, "conditional edge from %s to itself", edge.From)
+				continue
+			}
+			if first, ok := conditional[pair]; ok {
+				report(SeverityWarning, edgePath, "conditional edge %s -> %s is already in %s", edge.From, edge.To, first)
+				continue
+			}
+			conditional[pair] = edgePath
+			if unconditional[pair] {
+				report(SeverityWarning, edgePath, "conditional edge %s -> %s is also an unconditional edge, clients ignore its risks", edge.From, edge.To)
+				continue
+			}
+			adjacency[from] = append(adjacency[from], to)
+			problems = append(problems, checkDirection(edgePath, graph.Nodes[from].Version, graph.Nodes[to].Version)...)
+		}
+
+		if len(conditionalEdge.Risks) == 0 {
+			report(SeverityError, path, "conditional edge has no risks")
+		}
+		names := map[string]bool{}
+		for j, risk := range conditionalEdge.Risks {
+			riskPath := fmt.Sprintf("%s.risks[%d]", path, j)
+			if risk.Name != "" && names[risk.Name] {
+				report(SeverityError, riskPath, "duplicate risk %s", risk.Name)
+			}
+			names[risk.Name] = true
+			problems = append(problems, validateRisk(riskPath, risk)...)
+		}
+	}
+
+	for _, cycle := range findCycles(adjacency) {
+		versions := make([]string, 0, len(cycle))
+		for _, index := range cycle {
+			versions = append(versions, graph.Nodes[index].Version.String())
+		}
+		report(SeverityError, "", "cycle %s", strings.Join(versions, " -> "))
+	}
+	return problems
+}
+
+// checkDirection reports edges that do not go to a newer version; Cincinnati never serves downgrades
+func checkDirection(path string, from, to semver.Version) []Problem {
+	if from.LT(to) {
+		return nil
+	}
+	return []Problem{{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf("edge goes backwards from %s to %s", from, to)}}
+}
+
+func validateRisk(path string, risk ConditionalUpdateRisk) []Problem {
+	var problems []Problem
+	report := func(severity Severity, path, format string, args ...any) {
+		problems = append(problems, Problem{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	name := risk.Name
+	if name == "" {
+		report(SeverityError, path, "risk has no name")
+		name = "(unnamed)"
+	}
+	if len(risk.MatchingRules) == 0 {
+		report(SeverityError, path, "risk %s has no matching rules", name)
+	}
+	for i, rule := range risk.MatchingRules {
+		rulePath := fmt.Sprintf("%s.matchingRules[%d]", path, i)
+		switch rule.Type {
+		case "Always":
+		case "PromQL":
+			if rule.PromQL == nil || rule.PromQL.PromQL == "" {
+				report(SeverityError, rulePath, "risk %s has a PromQL matching rule without a query", name)
+			}
+		default:
+			report(SeverityError, rulePath, "risk %s has a matching rule of unknown type %q (one of %s)", name, rule.Type, strings.Join(knownMatchingRuleTypes, ", "))
+		}
+	}
+	if risk.URL == "" {
+		report(SeverityWarning, path, "risk %s has no URL", name)
+	} else if parsed, err := url.Parse(risk.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		report(SeverityWarning, path, "risk %s has a malformed URL %q", name, risk.URL)
+	}
+	if risk.Message == "" {
+		report(SeverityWarning, path, "risk %s has no message", name)
+	}
+	return problems
+}
+
+// findCycles returns one cycle (as node indices, starting and ending with the same node) per back edge found by a
+// depth-first search of the graph
+func findCycles(adjacency [][]int) [][]int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(adjacency))
+	var stack []int
+	var cycles [][]int
+	var visit func(node int)
+	visit = func(node int) {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range adjacency[node] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == next {
+						cycles = append(cycles, append(append([]int{}, stack[i:]...), next))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+	}
+	for node := range adjacency {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return cycles
+}
+
+// ValidateGraph returns the first error-severity problem of the graph as an error
+func ValidateGraph(graph Graph) error {
+	for _, problem := range Validate(graph) {
+		if problem.Severity == SeverityError {
+			if problem.Path == "" {
+				return fmt.Errorf("%s", problem.Message)
+			}
+			return fmt.Errorf("%s: %s", problem.Path, problem.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package fauxinnati
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mutate   func(*Graph)
+		expected []Problem
+	}{
+		{
+			name:   "valid",
+			mutate: func(*Graph) {},
+		},
+		{
+			name:   "duplicate version",
+			mutate: func(g *Graph) { g.Nodes = append(g.Nodes, g.Nodes[0]) },
+			expected: []Problem{
+				{Severity: SeverityError, Path: "nodes[4]", Message: "duplicate version 4.17.0 (first in nodes[0])"},
+			},
+		},
+		{
+			name:   "node without payload",
+			mutate: func(g *Graph) { g.Nodes[1].Image = "" },
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "nodes[1]", Message: "node 4.17.1 has no payload"},
+			},
+		},
+		{
+			name:   "edge index out of range",
+			mutate: func(g *Graph) { g.Edges = append(g.Edges, Edge{2, 4}, Edge{-1, 0}) },
+			expected: []Problem{
+				{Severity: SeverityError, Path: "edges[3]", Message: "edge [2, 4] points to a nonexistent node (graph has 4 nodes)"},
+				{Severity: SeverityError, Path: "edges[4]", Message: "edge [-1, 0] points to a nonexistent node (graph has 4 nodes)"},
+			},
+		},
+		{
+			name:   "edge to itself",
+			mutate: func(g *Graph) { g.Edges = append(g.Edges, Edge{2, 2}) },
+			expected: []Problem{
+				{Severity: SeverityError, Path: "edges[3]", Message: "edge from 4.17.2 to itself"},
+			},
+		},
+		{
+			name:   "duplicate edge",
+			mutate: func(g *Graph) { g.Edges = append(g.Edges, Edge{0, 1}) },
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "edges[3]", Message: "duplicate edge 4.17.0 -> 4.17.1"},
+			},
+		},
+		{
+			name:   "edge going backwards closes a cycle",
+			mutate: func(g *Graph) { g.Edges = append(g.Edges, Edge{2, 0}) },
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "edges[3]", Message: "edge goes backwards from 4.17.2 to 4.17.0"},
+				{Severity: SeverityError, Message: "cycle 4.17.0 -> 4.17.1 -> 4.17.2 -> 4.17.0"},
+			},
+		},
+		{
+			name: "conditional edge going backwards closes a cycle",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges[0].Edges = append(g.ConditionalEdges[0].Edges, ConditionalUpdate{From: "4.17.3", To: "4.17.1"})
+			},
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "conditionalEdges[0].edges[2]", Message: "edge goes backwards from 4.17.3 to 4.17.1"},
+				{Severity: SeverityError, Message: "cycle 4.17.1 -> 4.17.3 -> 4.17.1"},
+			},
+		},
+		{
+			name: "conditional edge with versions not in the graph",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges[0].Edges[1] = ConditionalUpdate{From: "4.16.9", To: "4.17.4"}
+			},
+			expected: []Problem{
+				{Severity: SeverityError, Path: "conditionalEdges[0].edges[1]", Message: `version "4.16.9" is not in the graph`},
+				{Severity: SeverityError, Path: "conditionalEdges[0].edges[1]", Message: `version "4.17.4" is not in the graph`},
+			},
+		},
+		{
+			name: "conditional edge that is also unconditional",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges[0].Edges = append(g.ConditionalEdges[0].Edges, ConditionalUpdate{From: "4.17.0", To: "4.17.1"})
+			},
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "conditionalEdges[0].edges[2]", Message: "conditional edge 4.17.0 -> 4.17.1 is also an unconditional edge, clients ignore its risks"},
+			},
+		},
+		{
+			name: "conditional edge in two groups",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{
+					Edges: []ConditionalUpdate{{From: "4.17.1", To: "4.17.3"}},
+					Risks: g.ConditionalEdges[0].Risks[:1],
+				})
+			},
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "conditionalEdges[1].edges[0]", Message: "conditional edge 4.17.1 -> 4.17.3 is already in conditionalEdges[0].edges[1]"},
+			},
+		},
+		{
+			name: "conditional edge without edges and risks",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges = append(g.ConditionalEdges, ConditionalEdge{})
+			},
+			expected: []Problem{
+				{Severity: SeverityError, Path: "conditionalEdges[1]", Message: "conditional edge has no edges"},
+				{Severity: SeverityError, Path: "conditionalEdges[1]", Message: "conditional edge has no risks"},
+			},
+		},
+		{
+			name: "risks the CVO cannot evaluate",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges[0].Risks = []ConditionalUpdateRisk{
+					{URL: "https://example.com/a", Message: "A", MatchingRules: []MatchingRule{{Type: "Always"}}},
+					{Name: "RiskB", URL: "https://example.com/b", Message: "B"},
+					{Name: "RiskC", URL: "https://example.com/c", Message: "C", MatchingRules: []MatchingRule{{Type: "PromQL"}, {Type: "Sometimes"}}},
+					{Name: "RiskC", URL: "https://example.com/c", Message: "C", MatchingRules: []MatchingRule{{Type: "PromQL", PromQL: &PromQLQuery{PromQL: "vector(1)"}}}},
+				}
+			},
+			expected: []Problem{
+				{Severity: SeverityError, Path: "conditionalEdges[0].risks[0]", Message: "risk has no name"},
+				{Severity: SeverityError, Path: "conditionalEdges[0].risks[1]", Message: "risk RiskB has no matching rules"},
+				{Severity: SeverityError, Path: "conditionalEdges[0].risks[2].matchingRules[0]", Message: "risk RiskC has a PromQL matching rule without a query"},
+				{Severity: SeverityError, Path: "conditionalEdges[0].risks[2].matchingRules[1]", Message: `risk RiskC has a matching rule of unknown type "Sometimes" (one of Always, PromQL)`},
+				{Severity: SeverityError, Path: "conditionalEdges[0].risks[3]", Message: "duplicate risk RiskC"},
+			},
+		},
+		{
+			name: "risks without usable URLs and messages",
+			mutate: func(g *Graph) {
+				g.ConditionalEdges[0].Risks[0].URL = ""
+				g.ConditionalEdges[0].Risks[1].URL = "OCPBUGS-1234"
+				g.ConditionalEdges[0].Risks[1].Message = ""
+			},
+			expected: []Problem{
+				{Severity: SeverityWarning, Path: "conditionalEdges[0].risks[0]", Message: "risk RiskA has no URL"},
+				{Severity: SeverityWarning, Path: "conditionalEdges[0].risks[1]", Message: `risk RiskB has a malformed URL "OCPBUGS-1234"`},
+				{Severity: SeverityWarning, Path: "conditionalEdges[0].risks[1]", Message: "risk RiskB has no message"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			graph := proxyTestGraph()
+			tc.mutate(&graph)
+			if diff := cmp.Diff(tc.expected, Validate(graph)); diff != "" {
+				t.Errorf("unexpected problems (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidate_Channels(t *testing.T) {
+	server := NewServer(
+		WithHTTPClient(NewOfflineClient()),
+		WithCandidatesSource(NewGraphDataDirCandidatesSource("testdata/graph-data")),
+		WithDigestResolver(testDigestResolver),
+	)
+	versions := []string{"4.17.5", "4.20.0-ec.2", "4.22.0-0-2026-03-03-000541-test-ci-ln-1phllqb-latest"}
+	for _, channel := range server.channelRegistry().Channels() {
+		for _, version := range versions {
+			for _, arch := range []string{"", "arm64", "multi"} {
+				graph := channel.Generate(semver.MustParse(version), arch, channel.Name)
+				for _, problem := range Validate(graph) {
+					t.Errorf("channel %s generated an invalid graph for %s (arch %q): %s", channel.Name, version, arch, problem)
+				}
+			}
+		}
+	}
+}